@@ -0,0 +1,62 @@
+// analysis/analysis.go
+
+// Package analysis looks for likely mistakes in programs that parse and run,
+// but probably not as intended. Its findings are warnings, not errors.
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"renelle/ast"
+	"renelle/token"
+)
+
+type Warning struct {
+	Message  string
+	FileName string
+	Line     int
+	Column   int
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s:%d:%d: warning: %s", w.FileName, w.Line, w.Column, w.Message)
+}
+
+// Check returns the warnings for a program that parsed without errors,
+// ordered by position.
+func Check(program *ast.Program) []Warning {
+	warnings := []Warning{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.CaseExpression:
+			warnings = append(warnings, checkCase(node)...)
+		case *ast.FunctionStatement:
+			warnings = append(warnings, checkFunction(node)...)
+		}
+		return true
+	})
+
+	sort.SliceStable(warnings, func(i, j int) bool {
+		if warnings[i].Line != warnings[j].Line {
+			return warnings[i].Line < warnings[j].Line
+		}
+		return warnings[i].Column < warnings[j].Column
+	})
+	return warnings
+}
+
+// RenderWarnings renders warnings one per line.
+func RenderWarnings(warnings []Warning) string {
+	var out strings.Builder
+	for _, w := range warnings {
+		out.WriteString(w.String())
+		out.WriteString("\n")
+	}
+	return out.String()
+}
+
+func newWarning(tok token.Token, format string, a ...interface{}) Warning {
+	return Warning{Message: fmt.Sprintf(format, a...), FileName: tok.FileName, Line: tok.Line, Column: tok.Column}
+}
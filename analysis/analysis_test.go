@@ -0,0 +1,110 @@
+package analysis
+
+import (
+	"fmt"
+	"testing"
+
+	"renelle/lexer"
+	"renelle/parser"
+)
+
+func check(t *testing.T, input string) []Warning {
+	t.Helper()
+	p := parser.New(lexer.New(input, "test"))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %s", parser.RenderErrors(p.Errors()))
+	}
+	return Check(program)
+}
+
+func TestCaseExhaustiveness(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"case r { (:ok v) => v }", []string{"1:1: case is not exhaustive: no clause matches (:error _)"}},
+		{"case r { (:ok v) => v, (:error e) => e }", nil},
+		{"case o { (:some v) => v }", []string{"1:1: case is not exhaustive: no clause matches :none"}},
+		{"case o { (:some v) => v, :none => 0 }", nil},
+		// a refined or guarded clause does not match the whole member
+		{"case r { (:ok 1) => 1, (:error e) => e }", []string{"1:1: case is not exhaustive: no clause matches (:ok _)"}},
+		{"case o { (:some v) when v > 0 => v, :none => 0 }", []string{"1:1: case is not exhaustive: no clause matches (:some _)"}},
+		{"case r { (:ok v) => v, _ => 0 }", nil},
+		// values outside the known shapes are not checked
+		{"case c { :red => 1, :green => 2 }", nil},
+		{"case r { (:ok v) => v, :none => 0 }", nil},
+		{"case n { 1 => 1 }", nil},
+		{"fn f(r) {\n  case r {\n    (:error e) => e\n  }\n}", []string{"2:3: case is not exhaustive: no clause matches (:ok _)"}},
+	}
+
+	for _, tt := range tests {
+		assertWarnings(t, tt.input, check(t, tt.input), tt.expected)
+	}
+}
+
+func TestUnreachableClauses(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"case x {\n  _ => 0\n  1 => 1\n}", []string{"3:3: unreachable case clause: the clause at 2:3 always matches first"}},
+		{"case x {\n  n => n\n  (:ok v) => v\n}", []string{"3:3: unreachable case clause: the clause at 2:3 always matches first"}},
+		{"case x {\n  (:ok v) => v\n  (:ok 1) => 1\n  (:error e) => e\n}", []string{"3:3: unreachable case clause: the clause at 2:3 always matches first"}},
+		{"case x {\n  [h | t] => h\n  [1 2] => 1\n  [] => 0\n}", []string{"3:3: unreachable case clause: the clause at 2:3 always matches first"}},
+		{"case x {\n  :a => 1\n  :a => 2\n  _ => 3\n}", []string{"3:3: unreachable case clause: the clause at 2:3 always matches first"}},
+		// a guarded clause can fall through
+		{"case x {\n  n when n > 0 => n\n  n => 0\n}", nil},
+		{"case x {\n  (:ok 1) => 1\n  (:ok v) => v\n  _ => 0\n}", nil},
+		{"case x {\n  [] => 0\n  [h | t] => h\n}", nil},
+	}
+
+	for _, tt := range tests {
+		assertWarnings(t, tt.input, check(t, tt.input), tt.expected)
+	}
+}
+
+func TestUnreachableFunctionClauses(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"fn f(x) { 1 }\nfn f(:a) { 2 }", []string{"2:1: unreachable clause of f: the clause at 1:1 always matches first"}},
+		{"fn f(x) { 1 }\nfn f(x) { 2 }", nil},
+		{"fn f(_ y) { 1 }\nfn f(:a 2) { 2 }", []string{"2:1: unreachable clause of f: the clause at 1:1 always matches first"}},
+		{"fn f(:a) { 1 }\nfn f(:a) { 2 }\nfn f(_) { 3 }", []string{"2:1: unreachable clause of f: the clause at 1:1 always matches first"}},
+		{"fn sum([]) { 0 }\nfn sum([h | t]) { h + sum(t) }", nil},
+		{"fn f(0 y) { 1 }\nfn f(x 0) { 2 }\nfn f(x y) { 3 }", nil},
+		{"fn f(x) { 1 }\nlet y = 0\nfn f(x) { 2 }", nil},
+		{"module M\nfn g(x) {\n  fn h(a) { 1 }\n  fn h(:b) { 2 }\n}", []string{"4:3: unreachable clause of h: the clause at 3:3 always matches first"}},
+	}
+
+	for _, tt := range tests {
+		assertWarnings(t, tt.input, check(t, tt.input), tt.expected)
+	}
+}
+
+func TestWarningString(t *testing.T) {
+	warnings := check(t, "case r { (:ok v) => v }")
+	if len(warnings) != 1 {
+		t.Fatalf("expected 1 warning. got=%d", len(warnings))
+	}
+	expected := "test:1:1: warning: case is not exhaustive: no clause matches (:error _)"
+	if warnings[0].String() != expected {
+		t.Errorf("wrong string. want=%q, got=%q", expected, warnings[0].String())
+	}
+}
+
+func assertWarnings(t *testing.T, input string, warnings []Warning, expected []string) {
+	t.Helper()
+	if len(warnings) != len(expected) {
+		t.Errorf("%q: expected %d warnings. got=%v", input, len(expected), warnings)
+		return
+	}
+	for i, w := range warnings {
+		got := fmt.Sprintf("%d:%d: %s", w.Line, w.Column, w.Message)
+		if got != expected[i] {
+			t.Errorf("%q: wrong warning. want=%q, got=%q", input, expected[i], got)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// analysis/case.go
+
+package analysis
+
+import (
+	"strings"
+	"unicode"
+
+	"renelle/ast"
+)
+
+// member is one kind of value in a shape: the bare atom tag when size is 0,
+// otherwise a tuple of size elements whose first is the atom tag.
+type member struct {
+	tag  string
+	size int
+}
+
+func (m member) String() string {
+	if m.size == 0 {
+		return ":" + m.tag
+	}
+	return "(:" + m.tag + strings.Repeat(" _", m.size-1) + ")"
+}
+
+// shapes are the families of values a case commonly tells apart. A case
+// matching some members of a shape, and nothing else, is expected to match
+// them all.
+var shapes = [][]member{
+	{{"ok", 2}, {"error", 2}},  // results of functions that can fail
+	{{"some", 2}, {"none", 0}}, // Option
+}
+
+func checkCase(node *ast.CaseExpression) []Warning {
+	warnings := []Warning{}
+
+	for i, condition := range node.Conditions {
+		for j := 0; j < i; j++ {
+			if node.Guards[j] == nil && covers(node.Conditions[j], condition) {
+				earlier := node.Conditions[j].T()
+				warnings = append(warnings, newWarning(condition.T(),
+					"unreachable case clause: the clause at %d:%d always matches first", earlier.Line, earlier.Column))
+				break
+			}
+		}
+	}
+
+	if missing := missingMembers(node); len(missing) > 0 {
+		warnings = append(warnings, newWarning(node.Token,
+			"case is not exhaustive: no clause matches %s", strings.Join(missing, " or ")))
+	}
+
+	return warnings
+}
+
+// missingMembers returns the members of a shape no clause matches, when the
+// case matches nothing but members of that shape and has no catch-all.
+func missingMembers(node *ast.CaseExpression) []string {
+	shape := -1
+	matched := map[member]bool{}
+
+	for i, condition := range node.Conditions {
+		unguarded := node.Guards[i] == nil
+		if unguarded && irrefutable(condition) {
+			return nil
+		}
+
+		m, rest, ok := memberOf(condition)
+		if !ok {
+			return nil
+		}
+		s := shapeOf(m)
+		if s == -1 || shape != -1 && s != shape {
+			return nil
+		}
+		shape = s
+
+		if unguarded && allIrrefutable(rest) {
+			matched[m] = true
+		}
+	}
+
+	if shape == -1 {
+		return nil
+	}
+
+	missing := []string{}
+	for _, m := range shapes[shape] {
+		if !matched[m] {
+			missing = append(missing, m.String())
+		}
+	}
+	return missing
+}
+
+// memberOf returns the member a pattern is written as, with the patterns of
+// the tuple elements after the tag.
+func memberOf(pattern ast.Expression) (member, []ast.Expression, bool) {
+	switch pattern := pattern.(type) {
+	case *ast.AtomLiteral:
+		return member{tag: pattern.Value}, nil, true
+	case *ast.TupleLiteral:
+		if len(pattern.Elements) == 0 {
+			return member{}, nil, false
+		}
+		if tag, ok := pattern.Elements[0].(*ast.AtomLiteral); ok {
+			return member{tag: tag.Value, size: len(pattern.Elements)}, pattern.Elements[1:], true
+		}
+	}
+	return member{}, nil, false
+}
+
+func shapeOf(m member) int {
+	for i, shape := range shapes {
+		for _, sm := range shape {
+			if sm == m {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// irrefutable reports whether a pattern matches any value, as `_` and bare
+// names do.
+func irrefutable(pattern ast.Expression) bool {
+	ident, ok := pattern.(*ast.Identifier)
+	return ok && !unicode.IsUpper(rune(ident.Value[0]))
+}
+
+func allIrrefutable(patterns []ast.Expression) bool {
+	for _, p := range patterns {
+		if !irrefutable(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// covers reports whether pattern p matches every value pattern q matches, so
+// that a clause for q after an unguarded one for p is never reached. It errs
+// on the side of false.
+func covers(p, q ast.Expression) bool {
+	if irrefutable(p) {
+		return true
+	}
+
+	switch p := p.(type) {
+	case *ast.TupleLiteral:
+		q, ok := q.(*ast.TupleLiteral)
+		return ok && len(p.Elements) == len(q.Elements) && coversAll(p.Elements, q.Elements)
+
+	case *ast.ArrayLiteral:
+		q, ok := q.(*ast.ArrayLiteral)
+		if !ok {
+			return false
+		}
+		if p.Rest == nil {
+			return q.Rest == nil && len(p.Elements) == len(q.Elements) && coversAll(p.Elements, q.Elements)
+		}
+		return len(q.Elements) >= len(p.Elements) && irrefutable(p.Rest) &&
+			coversAll(p.Elements, q.Elements[:len(p.Elements)])
+
+	case *ast.AtomLiteral:
+		q, ok := q.(*ast.AtomLiteral)
+		return ok && p.Value == q.Value
+
+	case *ast.IntegerLiteral:
+		q, ok := q.(*ast.IntegerLiteral)
+		return ok && p.Value == q.Value
+
+	case *ast.StringLiteral:
+		q, ok := q.(*ast.StringLiteral)
+		return ok && p.Value == q.Value
+
+	case *ast.Boolean:
+		q, ok := q.(*ast.Boolean)
+		return ok && p.Value == q.Value
+	}
+
+	return false
+}
+
+func coversAll(ps, qs []ast.Expression) bool {
+	for i, p := range ps {
+		if !covers(p, qs[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,26 @@
+// analysis/function.go
+
+package analysis
+
+import "renelle/ast"
+
+// checkFunction warns about the clauses of a function that can never run
+// because an earlier clause matches every argument they do. Two plain `fn`
+// statements of the same name and arity in a row are clauses of one
+// function, so a second definition meant to replace the first is caught here.
+func checkFunction(node *ast.FunctionStatement) []Warning {
+	warnings := []Warning{}
+
+	for i, clause := range node.Clauses {
+		for _, earlier := range node.Clauses[:i] {
+			if coversAll(earlier.Patterns, clause.Patterns) {
+				warnings = append(warnings, newWarning(clause.Token,
+					"unreachable clause of %s: the clause at %d:%d always matches first",
+					node.Name.Value, earlier.Token.Line, earlier.Token.Column))
+				break
+			}
+		}
+	}
+
+	return warnings
+}
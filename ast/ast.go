@@ -196,6 +196,7 @@ func (isl *InterpolatedStringLiteral) AddComment(c string) { isl.comments = appe
 type ArrayLiteral struct {
 	Token    token.Token
 	Elements []Expression
+	Rest     Expression // the array after `|` in `[h | t]`, nil if none
 	comments []string
 }
 
@@ -212,6 +213,9 @@ func (al *ArrayLiteral) String() string {
 	}
 	out.WriteString("[")
 	out.WriteString(strings.Join(elements, " "))
+	if al.Rest != nil {
+		out.WriteString(" | " + al.Rest.String())
+	}
 	out.WriteString("]")
 	return out.String()
 }
@@ -238,6 +242,54 @@ func (pe *PrefixExpression) String() string {
 	return out.String()
 }
 
+// PinExpression is `^name` in a pattern, matching the value name is bound to
+// instead of binding it anew.
+type PinExpression struct {
+	Token token.Token // the ^ token
+	Name  *Identifier
+
+	comments []string
+}
+
+func (pe *PinExpression) expressionNode()      {}
+func (pe *PinExpression) T() token.Token       { return pe.Token }
+func (pe *PinExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PinExpression) Comments() []string   { return pe.comments }
+func (pe *PinExpression) AddComment(c string)  { pe.comments = append(pe.comments, c) }
+func (pe *PinExpression) String() string       { return "^" + pe.Name.String() }
+
+// SpreadExpression passes the elements of an array as arguments of a call, as
+// in `f(...args)`, and declares a rest parameter in a function's parameters.
+type SpreadExpression struct {
+	Token token.Token // the ... token
+	Value Expression
+
+	comments []string
+}
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) T() token.Token       { return se.Token }
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) Comments() []string   { return se.comments }
+func (se *SpreadExpression) AddComment(c string)  { se.comments = append(se.comments, c) }
+func (se *SpreadExpression) String() string       { return "..." + se.Value.String() }
+
+// TryExpression is `value?`, which unwraps an (:ok v) or (:some v) and
+// returns anything else from the enclosing function.
+type TryExpression struct {
+	Token token.Token // the ? token
+	Value Expression
+
+	comments []string
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) T() token.Token       { return te.Token }
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) Comments() []string   { return te.comments }
+func (te *TryExpression) AddComment(c string)  { te.comments = append(te.comments, c) }
+func (te *TryExpression) String() string       { return "(" + te.Value.String() + "?)" }
+
 type InfixExpression struct {
 	Token    token.Token // The operator token, e.g. +
 	Left     Expression
@@ -326,6 +378,7 @@ func (bs *BlockStatement) String() string {
 type FunctionLiteral struct {
 	Token      token.Token // The 'fn' token
 	Parameters []*Identifier
+	Rest       *Identifier // bound to an array of the arguments after Parameters, if any
 	Body       *BlockStatement
 
 	comments []string
@@ -342,6 +395,9 @@ func (fl *FunctionLiteral) String() string {
 	for _, p := range fl.Parameters {
 		params = append(params, p.String())
 	}
+	if fl.Rest != nil {
+		params = append(params, "..."+fl.Rest.String())
+	}
 	out.WriteString("//")
 	out.WriteString(strings.Join(params, " "))
 	out.WriteString(" => ")
@@ -355,20 +411,89 @@ type FunctionStatement struct {
 	Parameters []*Identifier
 	Body       *BlockStatement
 
+	// Defaults holds the default value of each parameter, or nil for those
+	// that must be given. It is nil if no parameter has a default.
+	Defaults []Expression
+
+	// Rest is bound to an array of the arguments after Parameters, if any.
+	Rest *Identifier
+
+	// Clauses replace Parameters and Body when the parameters are patterns,
+	// or the function is written as several `fn` statements of the same name
+	// and arity in a row. The first clause matching the arguments is run.
+	Clauses []*FunctionClause
+
 	comments []string
 }
 
+// FunctionClause is one `fn` statement of a function matching its arguments.
+type FunctionClause struct {
+	Token    token.Token // the 'fn' token
+	Patterns []Expression
+	Body     *BlockStatement
+}
+
+func (fc *FunctionClause) String(name string) string {
+	patterns := []string{}
+	for _, p := range fc.Patterns {
+		patterns = append(patterns, p.String())
+	}
+	return fc.Token.Literal + " " + name + "(" + strings.Join(patterns, " ") + ") " + fc.Body.String()
+}
+
+// Arity is the number of arguments the function takes.
+func (fs *FunctionStatement) Arity() int {
+	if fs.Clauses != nil {
+		return len(fs.Clauses[0].Patterns)
+	}
+	return len(fs.Parameters)
+}
+
+// AddClauses makes the clauses of next clauses of this function, which must
+// have the same name and arity.
+func (fs *FunctionStatement) AddClauses(next *FunctionStatement) {
+	fs.Clauses = append(fs.clauses(), next.clauses()...)
+	fs.Parameters = nil
+	fs.Body = nil
+}
+
+func (fs *FunctionStatement) clauses() []*FunctionClause {
+	if fs.Clauses != nil {
+		return fs.Clauses
+	}
+	patterns := make([]Expression, len(fs.Parameters))
+	for i, p := range fs.Parameters {
+		patterns[i] = p
+	}
+	return []*FunctionClause{{Token: fs.Token, Patterns: patterns, Body: fs.Body}}
+}
+
 func (fs *FunctionStatement) statementNode()       {}
 func (fs *FunctionStatement) T() token.Token       { return fs.Token }
 func (fs *FunctionStatement) TokenLiteral() string { return fs.Token.Literal }
 func (fs *FunctionStatement) Comments() []string   { return fs.comments }
 func (fs *FunctionStatement) AddComment(c string)  { fs.comments = append(fs.comments, c) }
 func (fs *FunctionStatement) String() string {
+	if fs.Clauses != nil {
+		clauses := []string{}
+		for _, c := range fs.Clauses {
+			clauses = append(clauses, c.String(fs.Name.String()))
+		}
+		return strings.Join(clauses, "\n")
+	}
+
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range fs.Parameters {
-		params = append(params, p.String())
+	for i, p := range fs.Parameters {
+		if fs.Defaults != nil && fs.Defaults[i] != nil {
+			params = append(params, p.String()+" = "+fs.Defaults[i].String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if fs.Rest != nil {
+		params = append(params, "..."+fs.Rest.String())
 	}
 
 	out.WriteString(fs.TokenLiteral() + " ")
@@ -386,6 +511,10 @@ type CallExpression struct {
 	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
 
+	// keyword arguments, as in `pad(s 5 char = "-")`, given after the others
+	KeywordNames  []*Identifier
+	KeywordValues []Expression
+
 	comments []string
 }
 
@@ -400,6 +529,9 @@ func (ce *CallExpression) String() string {
 	for _, a := range ce.Arguments {
 		args = append(args, a.String())
 	}
+	for i, name := range ce.KeywordNames {
+		args = append(args, name.String()+" = "+ce.KeywordValues[i].String())
+	}
 	out.WriteString(ce.Function.String())
 	out.WriteString("(")
 	out.WriteString(strings.Join(args, " "))
@@ -407,6 +539,44 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+// IsPlaceholder reports whether an argument of a call is the `_` placeholder
+// of a partial application, as in `add(_ 5)`.
+func IsPlaceholder(arg Expression) bool {
+	ident, ok := arg.(*Identifier)
+	return ok && ident.Value == "_"
+}
+
+// Placeholders is the number of `_` placeholders among the arguments. A call
+// with any is a partial application, making a function that takes the
+// missing arguments.
+func (ce *CallExpression) Placeholders() int {
+	n := 0
+	for _, a := range ce.Arguments {
+		if IsPlaceholder(a) {
+			n++
+		}
+	}
+	return n
+}
+
+// Piped returns the call `left |> ce` stands for: left fills the first `_`
+// placeholder among the arguments, or is passed before them if there is none.
+// The call itself is left as it is, as it may be run again.
+func (ce *CallExpression) Piped(left Expression) *CallExpression {
+	args := make([]Expression, 0, len(ce.Arguments)+1)
+	filled := false
+	for _, a := range ce.Arguments {
+		if !filled && IsPlaceholder(a) {
+			a, filled = left, true
+		}
+		args = append(args, a)
+	}
+	if !filled {
+		args = append([]Expression{left}, args...)
+	}
+	return &CallExpression{Token: ce.Token, Function: ce.Function, Arguments: args, KeywordNames: ce.KeywordNames, KeywordValues: ce.KeywordValues}
+}
+
 type AtomLiteral struct {
 	Token token.Token
 	Value string
@@ -501,7 +671,8 @@ func (te *TupleLiteral) String() string {
 }
 
 type MapLiteral struct {
-	Token token.Token // The '{' token
+	Token token.Token  // The '{' token
+	Keys  []Expression // the keys of Pairs in source order
 	Pairs map[Expression]Expression
 
 	comments []string
@@ -515,8 +686,8 @@ func (ml *MapLiteral) AddComment(c string)  { ml.comments = append(ml.comments,
 func (ml *MapLiteral) String() string {
 	var out bytes.Buffer
 	pairs := []string{}
-	for key, value := range ml.Pairs {
-		pairs = append(pairs, key.String()+" = "+value.String())
+	for _, key := range ml.Keys {
+		pairs = append(pairs, key.String()+" = "+ml.Pairs[key].String())
 	}
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, " "))
@@ -527,6 +698,7 @@ func (ml *MapLiteral) String() string {
 type MapUpdateLiteral struct {
 	Token token.Token // The 'with' token
 	Left  Expression
+	Keys  []Expression // the keys of Right in source order
 	Right map[Expression]Expression
 
 	comments []string
@@ -540,8 +712,8 @@ func (ml *MapUpdateLiteral) AddComment(c string)  { ml.comments = append(ml.comm
 func (ml *MapUpdateLiteral) String() string {
 	var out bytes.Buffer
 	pairs := []string{}
-	for key, value := range ml.Right {
-		pairs = append(pairs, key.String()+" = "+value.String())
+	for _, key := range ml.Keys {
+		pairs = append(pairs, key.String()+" = "+ml.Right[key].String())
 	}
 	out.WriteString("{ ")
 	out.WriteString(ml.Left.String())
@@ -578,6 +750,7 @@ type CaseExpression struct {
 	Token        token.Token // The 'case' token
 	Test         Expression
 	Conditions   []Expression
+	Guards       []Expression // nil where a clause has no `when` guard
 	Consequences []*BlockStatement
 
 	comments []string
@@ -594,11 +767,67 @@ func (ce *CaseExpression) String() string {
 	out.WriteString(ce.Test.String())
 	for i, cond := range ce.Conditions {
 		out.WriteString(cond.String())
+		if ce.Guards[i] != nil {
+			out.WriteString(" when " + ce.Guards[i].String())
+		}
 		out.WriteString(ce.Consequences[i].String())
 	}
 	return out.String()
 }
 
+// WithExpression runs its steps in order. The first step whose value does
+// not match its pattern ends it, and that value goes to the else clauses,
+// or is the result when there are none.
+type WithExpression struct {
+	Token        token.Token // The 'with' token
+	Body         *BlockStatement
+	Conditions   []Expression // the else clauses, as in a case
+	Guards       []Expression
+	Consequences []*BlockStatement
+
+	comments []string
+}
+
+func (we *WithExpression) expressionNode()      {}
+func (we *WithExpression) T() token.Token       { return we.Token }
+func (we *WithExpression) TokenLiteral() string { return we.Token.Literal }
+func (we *WithExpression) Comments() []string   { return we.comments }
+func (we *WithExpression) AddComment(c string)  { we.comments = append(we.comments, c) }
+func (we *WithExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("with")
+	out.WriteString(we.Body.String())
+	if we.Conditions != nil {
+		out.WriteString("else")
+	}
+	for i, cond := range we.Conditions {
+		out.WriteString(cond.String())
+		if we.Guards[i] != nil {
+			out.WriteString(" when " + we.Guards[i].String())
+		}
+		out.WriteString(we.Consequences[i].String())
+	}
+	return out.String()
+}
+
+// MatchStep is a `pattern <- value` step in the body of a with.
+type MatchStep struct {
+	Token   token.Token // The '<-' token
+	Pattern Expression
+	Value   Expression
+
+	comments []string
+}
+
+func (ms *MatchStep) statementNode()       {}
+func (ms *MatchStep) T() token.Token       { return ms.Token }
+func (ms *MatchStep) TokenLiteral() string { return ms.Token.Literal }
+func (ms *MatchStep) Comments() []string   { return ms.comments }
+func (ms *MatchStep) AddComment(c string)  { ms.comments = append(ms.comments, c) }
+func (ms *MatchStep) String() string {
+	return ms.Pattern.String() + " <- " + ms.Value.String()
+}
+
 type Module struct {
 	Token token.Token
 	Name  *Identifier
@@ -624,3 +853,58 @@ func (m *Module) String() string {
 	out.WriteString(strings.Join(stmts, ""))
 	return out.String()
 }
+
+type StructStatement struct {
+	Token  token.Token // The 'struct' token
+	Fields []*Identifier
+
+	comments []string
+}
+
+func (ss *StructStatement) statementNode()       {}
+func (ss *StructStatement) T() token.Token       { return ss.Token }
+func (ss *StructStatement) TokenLiteral() string { return ss.Token.Literal }
+func (ss *StructStatement) Comments() []string   { return ss.comments }
+func (ss *StructStatement) AddComment(c string)  { ss.comments = append(ss.comments, c) }
+func (ss *StructStatement) String() string {
+	fields := []string{}
+	for _, f := range ss.Fields {
+		fields = append(fields, f.String())
+	}
+
+	var out bytes.Buffer
+	out.WriteString("struct {")
+	out.WriteString(strings.Join(fields, " "))
+	out.WriteString("}")
+	return out.String()
+}
+
+// StructLiteral is both the construction syntax `MyApp.Dog{name = "Fido"}`
+// and the matching pattern. Fields and Values are kept in source order.
+type StructLiteral struct {
+	Token  token.Token // The struct name token
+	Name   *Identifier
+	Fields []*Identifier
+	Values []Expression
+
+	comments []string
+}
+
+func (sl *StructLiteral) expressionNode()      {}
+func (sl *StructLiteral) T() token.Token       { return sl.Token }
+func (sl *StructLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StructLiteral) Comments() []string   { return sl.comments }
+func (sl *StructLiteral) AddComment(c string)  { sl.comments = append(sl.comments, c) }
+func (sl *StructLiteral) String() string {
+	pairs := []string{}
+	for i, f := range sl.Fields {
+		pairs = append(pairs, f.String()+" = "+sl.Values[i].String())
+	}
+
+	var out bytes.Buffer
+	out.WriteString(sl.Name.String())
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, " "))
+	out.WriteString("}")
+	return out.String()
+}
@@ -0,0 +1,127 @@
+// ast/walk.go
+
+package ast
+
+// Inspect traverses the tree rooted at node depth first, calling f for each
+// node before its children. If f returns false the children are skipped.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		inspectStatements(n.Statements, f)
+	case *Module:
+		inspectStatements(n.Body, f)
+	case *BlockStatement:
+		inspectStatements(n.Statements, f)
+	case *LetStatement:
+		Inspect(n.Left, f)
+		Inspect(n.Value, f)
+	case *ReturnStatement:
+		Inspect(n.ReturnValue, f)
+	case *ExpressionStatement:
+		Inspect(n.Expression, f)
+	case *FunctionStatement:
+		inspectExpressions(n.Defaults, f)
+		inspectBlock(n.Body, f)
+		for _, clause := range n.Clauses {
+			inspectExpressions(clause.Patterns, f)
+			inspectBlock(clause.Body, f)
+		}
+	case *FunctionLiteral:
+		inspectBlock(n.Body, f)
+	case *InterpolatedStringLiteral:
+		inspectExpressions(n.Segments, f)
+	case *ArrayLiteral:
+		inspectExpressions(n.Elements, f)
+		Inspect(n.Rest, f)
+	case *TupleLiteral:
+		inspectExpressions(n.Elements, f)
+	case *MapLiteral:
+		for _, key := range n.Keys {
+			Inspect(key, f)
+			Inspect(n.Pairs[key], f)
+		}
+	case *MapUpdateLiteral:
+		Inspect(n.Left, f)
+		for _, key := range n.Keys {
+			Inspect(key, f)
+			Inspect(n.Right[key], f)
+		}
+	case *StructLiteral:
+		inspectExpressions(n.Values, f)
+	case *PrefixExpression:
+		Inspect(n.Right, f)
+	case *SpreadExpression:
+		Inspect(n.Value, f)
+	case *TryExpression:
+		Inspect(n.Value, f)
+	case *InfixExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *IfExpression:
+		Inspect(n.Condition, f)
+		inspectBlock(n.Consequence, f)
+		inspectBlock(n.Alternative, f)
+	case *CondExpression:
+		for i, condition := range n.Conditions {
+			Inspect(condition, f)
+			inspectBlock(n.Consequences[i], f)
+		}
+	case *CaseExpression:
+		Inspect(n.Test, f)
+		for i, condition := range n.Conditions {
+			Inspect(condition, f)
+			Inspect(n.Guards[i], f)
+			inspectBlock(n.Consequences[i], f)
+		}
+	case *WithExpression:
+		inspectBlock(n.Body, f)
+		for i, condition := range n.Conditions {
+			Inspect(condition, f)
+			Inspect(n.Guards[i], f)
+			inspectBlock(n.Consequences[i], f)
+		}
+	case *MatchStep:
+		Inspect(n.Pattern, f)
+		Inspect(n.Value, f)
+	case *CallExpression:
+		Inspect(n.Function, f)
+		inspectExpressions(n.Arguments, f)
+		inspectExpressions(n.KeywordValues, f)
+	case *IndexExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Index, f)
+	case *PropertyAccessExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *ApplyExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	}
+}
+
+func inspectStatements(statements []Statement, f func(Node) bool) {
+	for _, s := range statements {
+		if s != nil {
+			Inspect(s, f)
+		}
+	}
+}
+
+func inspectExpressions(expressions []Expression, f func(Node) bool) {
+	for _, e := range expressions {
+		if e != nil {
+			Inspect(e, f)
+		}
+	}
+}
+
+// inspectBlock skips a missing block, such as an if without an else.
+func inspectBlock(block *BlockStatement, f func(Node) bool) {
+	if block != nil {
+		Inspect(block, f)
+	}
+}
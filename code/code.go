@@ -0,0 +1,275 @@
+// code/code.go
+
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpPop
+	OpDup
+	OpTrue
+	OpFalse
+	OpNil
+
+	// infix operators
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpPow
+	OpEqual
+	OpNotEqual
+	OpLessThan
+	OpGreaterThan
+	OpLessEqual
+	OpGreaterEqual
+	OpConcat
+	OpArrayEqual
+	OpArrayNotEqual
+	OpRange
+	OpStep
+	OpIn
+
+	// prefix operators
+	OpMinus
+	OpBang
+
+	OpJump
+	OpJumpNotTruthy
+	OpJumpIfFalsy  // jumps if the top of the stack is falsy, keeping it, otherwise pops it
+	OpJumpIfTruthy // jumps if the top of the stack is truthy, keeping it, otherwise pops it
+	OpToBool
+
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	OpGetFree
+	OpCurrentClosure
+	OpGetModule
+	OpModule
+
+	OpArray
+	OpTuple
+	OpMap
+	OpMapUpdate
+	OpStruct
+	OpInterpolate
+	OpIndex
+	OpSlice
+	OpGetProperty
+	OpCallProperty
+
+	OpCall
+	OpCallKeywords // a call with the keyword arguments named by its constant last
+	OpCallSpread   // a call expanding the arrays at the argument positions listed by its constant
+	OpTailCall     // a call in tail position, which replaces the caller's frame
+	OpPartial      // a call with _ placeholders at the argument positions listed by its constant, made into a function
+	OpReturnValue
+	OpTry // unwraps an (:ok v) or (:some v), returning any other result from the function
+	OpClosure
+	OpMatch
+	OpDefault  // jumps over the default value of a parameter if its argument was given
+	OpOverload // combines a function with the one of other arities below it, named by its constant
+	OpNoMatch  // raises the error formatted by its constant with the value on the stack
+)
+
+type Definition struct {
+	Name          string
+	OperandWidths []int
+	Operator      string // the source operator, for infix and prefix operators
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {Name: "OpConstant", OperandWidths: []int{2}},
+	OpPop:      {Name: "OpPop"},
+	OpDup:      {Name: "OpDup"},
+	OpTrue:     {Name: "OpTrue"},
+	OpFalse:    {Name: "OpFalse"},
+	OpNil:      {Name: "OpNil"},
+
+	OpAdd:           {Name: "OpAdd", Operator: "+"},
+	OpSub:           {Name: "OpSub", Operator: "-"},
+	OpMul:           {Name: "OpMul", Operator: "*"},
+	OpDiv:           {Name: "OpDiv", Operator: "/"},
+	OpMod:           {Name: "OpMod", Operator: "%"},
+	OpPow:           {Name: "OpPow", Operator: "**"},
+	OpEqual:         {Name: "OpEqual", Operator: "=="},
+	OpNotEqual:      {Name: "OpNotEqual", Operator: "!="},
+	OpLessThan:      {Name: "OpLessThan", Operator: "<"},
+	OpGreaterThan:   {Name: "OpGreaterThan", Operator: ">"},
+	OpLessEqual:     {Name: "OpLessEqual", Operator: "<="},
+	OpGreaterEqual:  {Name: "OpGreaterEqual", Operator: ">="},
+	OpConcat:        {Name: "OpConcat", Operator: "++"},
+	OpArrayEqual:    {Name: "OpArrayEqual", Operator: "==="},
+	OpArrayNotEqual: {Name: "OpArrayNotEqual", Operator: "!=="},
+	OpRange:         {Name: "OpRange", Operator: ".."},
+	OpStep:          {Name: "OpStep", Operator: "//"},
+	OpIn:            {Name: "OpIn", Operator: "in"},
+
+	OpMinus: {Name: "OpMinus", Operator: "-"},
+	OpBang:  {Name: "OpBang", Operator: "!"},
+
+	OpJump:          {Name: "OpJump", OperandWidths: []int{2}},
+	OpJumpNotTruthy: {Name: "OpJumpNotTruthy", OperandWidths: []int{2}},
+	OpJumpIfFalsy:   {Name: "OpJumpIfFalsy", OperandWidths: []int{2}},
+	OpJumpIfTruthy:  {Name: "OpJumpIfTruthy", OperandWidths: []int{2}},
+	OpToBool:        {Name: "OpToBool"},
+
+	OpGetGlobal:      {Name: "OpGetGlobal", OperandWidths: []int{2}},
+	OpSetGlobal:      {Name: "OpSetGlobal", OperandWidths: []int{2}},
+	OpGetLocal:       {Name: "OpGetLocal", OperandWidths: []int{2}},
+	OpSetLocal:       {Name: "OpSetLocal", OperandWidths: []int{2}},
+	OpGetFree:        {Name: "OpGetFree", OperandWidths: []int{1}},
+	OpCurrentClosure: {Name: "OpCurrentClosure"},
+	OpGetModule:      {Name: "OpGetModule", OperandWidths: []int{2}},
+	OpModule:         {Name: "OpModule", OperandWidths: []int{2}},
+
+	OpArray:        {Name: "OpArray", OperandWidths: []int{2}},
+	OpTuple:        {Name: "OpTuple", OperandWidths: []int{2}},
+	OpMap:          {Name: "OpMap", OperandWidths: []int{2}},
+	OpMapUpdate:    {Name: "OpMapUpdate", OperandWidths: []int{2}},
+	OpStruct:       {Name: "OpStruct", OperandWidths: []int{2}},
+	OpInterpolate:  {Name: "OpInterpolate", OperandWidths: []int{2}},
+	OpIndex:        {Name: "OpIndex"},
+	OpSlice:        {Name: "OpSlice"},
+	OpGetProperty:  {Name: "OpGetProperty", OperandWidths: []int{2}},
+	OpCallProperty: {Name: "OpCallProperty", OperandWidths: []int{2, 1}},
+
+	OpCall:         {Name: "OpCall", OperandWidths: []int{1}},
+	OpCallKeywords: {Name: "OpCallKeywords", OperandWidths: []int{2, 1}},
+	OpCallSpread:   {Name: "OpCallSpread", OperandWidths: []int{2, 1}},
+	OpTailCall:     {Name: "OpTailCall", OperandWidths: []int{1}},
+	OpPartial:      {Name: "OpPartial", OperandWidths: []int{2, 1}},
+	OpReturnValue:  {Name: "OpReturnValue"},
+	OpTry:          {Name: "OpTry"},
+	OpClosure:      {Name: "OpClosure", OperandWidths: []int{2, 1}},
+	OpMatch:        {Name: "OpMatch", OperandWidths: []int{2, 1}},
+	OpDefault:      {Name: "OpDefault", OperandWidths: []int{2, 2}},
+	OpOverload:     {Name: "OpOverload", OperandWidths: []int{2}},
+	OpNoMatch:      {Name: "OpNoMatch", OperandWidths: []int{2}},
+}
+
+// InfixOperators maps source operators to the opcode implementing them.
+var InfixOperators = map[string]Opcode{}
+
+func init() {
+	for op := OpAdd; op <= OpIn; op++ {
+		InfixOperators[definitions[op].Operator] = op
+	}
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
@@ -0,0 +1,1223 @@
+// compiler/compiler.go
+
+package compiler
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	"renelle/ast"
+	"renelle/code"
+	"renelle/evaluator"
+	"renelle/object"
+	"renelle/token"
+)
+
+// Error is a compile error, reported at the node that caused it.
+type Error struct {
+	Message  string
+	Line     int
+	Column   int
+	FileName string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.FileName, e.Line, e.Column, e.Message)
+}
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+type CompilationScope struct {
+	instructions    code.Instructions
+	positions       []object.SourcePos
+	lastInstruction EmittedInstruction
+}
+
+type Compiler struct {
+	constants []object.Object
+
+	symbolTable    *SymbolTable
+	definedGlobals map[string]bool
+
+	// the names of the block being compiled defined by fn statements of
+	// different arities, which are bound to an Overloads of them all, mapped
+	// to whether one of them has been compiled yet
+	overloaded map[string]bool
+
+	// the jumps taken when a match step of the with being compiled fails,
+	// to be pointed at its else clauses
+	stepJumps []int
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	pos      token.Token
+	fileName string
+}
+
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	Positions    []object.SourcePos
+	FileName     string
+	NumGlobals   int
+	GlobalNames  []string
+}
+
+func New() *Compiler {
+	return &Compiler{
+		constants:      []object.Object{},
+		symbolTable:    NewSymbolTable(),
+		definedGlobals: make(map[string]bool),
+		scopes:         []CompilationScope{{instructions: code.Instructions{}}},
+	}
+}
+
+// Compile compiles a whole program. The compiled program returns the value
+// of its last statement, or the result of calling `main` if it defines one.
+func (c *Compiler) Compile(program *ast.Program) error {
+	if len(program.Statements) > 0 {
+		c.fileName = program.T().FileName
+	}
+
+	if err := c.compileBlock(program.Statements, false); err != nil {
+		return err
+	}
+
+	if c.definedGlobals["main"] {
+		// main is called from the last statement of the program, as in
+		// the evaluator.
+		main, _ := c.symbolTable.Resolve("main")
+		c.setPos(program.Statements[len(program.Statements)-1].T())
+		c.emit(code.OpPop)
+		c.emit(code.OpGetGlobal, main.Index)
+		c.emit(code.OpCall, 0)
+	}
+
+	c.emit(code.OpReturnValue)
+	return nil
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		Positions:    c.scopes[c.scopeIndex].positions,
+		FileName:     c.fileName,
+		NumGlobals:   c.symbolTable.Root().NumDefinitions(),
+		GlobalNames:  c.symbolTable.Root().Names(),
+	}
+}
+
+// compileBlock compiles statements so that they leave the value of the last
+// one on the stack, as the evaluator returns it. In tail position the last
+// statement is compiled as a tail call where possible.
+func (c *Compiler) compileBlock(stmts []ast.Statement, tail bool) error {
+	statements := []ast.Statement{}
+	for _, s := range stmts {
+		if es, ok := s.(*ast.ExpressionStatement); ok && es.Expression == nil {
+			continue
+		}
+		statements = append(statements, s)
+	}
+
+	// functions can be called before the statement defining them
+	arities := map[string]map[int]bool{}
+	for _, s := range statements {
+		if fn, ok := s.(*ast.FunctionStatement); ok && fn.Name != nil {
+			c.define(fn.Name.Value)
+			if arities[fn.Name.Value] == nil {
+				arities[fn.Name.Value] = map[int]bool{}
+			}
+			arities[fn.Name.Value][fn.Arity()] = true
+		}
+	}
+
+	outerOverloaded := c.overloaded
+	c.overloaded = map[string]bool{}
+	for name, a := range arities {
+		if len(a) > 1 {
+			c.overloaded[name] = false
+		}
+	}
+	defer func() { c.overloaded = outerOverloaded }()
+
+	if len(statements) == 0 {
+		c.emit(code.OpNil)
+		return nil
+	}
+
+	for i, s := range statements {
+		if err := c.compileStatement(s, tail && i == len(statements)-1); err != nil {
+			return err
+		}
+		if i < len(statements)-1 {
+			c.emit(code.OpPop)
+		}
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileStatement(stmt ast.Statement, tail bool) error {
+	c.setPos(stmt.T())
+
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		if tail {
+			return c.compileTail(stmt.Expression)
+		}
+		return c.compile(stmt.Expression)
+
+	case *ast.LetStatement:
+		// `let f = \x => ...` may call itself through f, as in the evaluator
+		fn, isFn := stmt.Value.(*ast.FunctionLiteral)
+		ident, isIdent := stmt.Left.(*ast.Identifier)
+		if isFn && isIdent && ident.Value != "_" {
+			if err := c.compileFunction(ident.Value, fn.Parameters, nil, fn.Rest, fn.Body); err != nil {
+				return err
+			}
+		} else if err := c.compile(stmt.Value); err != nil {
+			return err
+		}
+		c.setPos(stmt.Token)
+		c.emit(code.OpDup)
+
+		switch left := stmt.Left.(type) {
+		case *ast.Identifier:
+			if left.Value == "_" {
+				c.emit(code.OpPop)
+				return nil
+			}
+			if unicode.IsUpper(rune(left.Value[0])) {
+				return c.errorf(left.Token, "local variables can not start with an uppercase letter")
+			}
+			c.storeSymbol(c.define(left.Value))
+			return nil
+		case *ast.TupleLiteral, *ast.ArrayLiteral, *ast.MapLiteral, *ast.StructLiteral, *ast.PinExpression:
+			return c.compileMatch(left, false)
+		default:
+			return c.errorf(stmt.Token, "invalid left-hand side of assignment")
+		}
+
+	case *ast.MatchStep:
+		// the value matched is the value of the step
+		if err := c.compile(stmt.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpDup)
+		if err := c.compileMatch(stmt.Pattern, true); err != nil {
+			return err
+		}
+		c.stepJumps = append(c.stepJumps, c.emit(code.OpJumpNotTruthy, 9999))
+		return nil
+
+	case *ast.ReturnStatement:
+		if stmt.ReturnValue == nil {
+			c.emit(code.OpNil)
+		} else if c.scopeIndex > 0 {
+			if err := c.compileTail(stmt.ReturnValue); err != nil {
+				return err
+			}
+		} else if err := c.compile(stmt.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+		return nil
+
+	case *ast.FunctionStatement:
+		if stmt.Name == nil || stmt.Body == nil && stmt.Clauses == nil {
+			return c.errorf(stmt.Token, "invalid function declaration")
+		}
+		symbol := c.define(stmt.Name.Value)
+		merge, overloaded := c.overloaded[stmt.Name.Value]
+		if merge {
+			// combined with the arities defined before it
+			c.loadSymbol(symbol)
+		}
+		if stmt.Clauses != nil {
+			if err := c.compileClauses(stmt.Name.Value, stmt.Clauses); err != nil {
+				return err
+			}
+		} else if err := c.compileFunction(stmt.Name.Value, stmt.Parameters, stmt.Defaults, stmt.Rest, stmt.Body); err != nil {
+			return err
+		}
+		if merge {
+			c.emit(code.OpOverload, c.addConstant(&object.String{Value: stmt.Name.Value}))
+		}
+		if overloaded {
+			c.overloaded[stmt.Name.Value] = true
+		}
+		c.storeSymbol(symbol)
+		c.emit(code.OpNil)
+		return nil
+
+	case *ast.Module:
+		c.emit(code.OpModule, c.addConstant(&ModuleDefinition{Node: stmt}))
+		return nil
+
+	case *ast.StructStatement:
+		return c.errorf(stmt.Token, "struct can only be declared inside a module")
+
+	case *ast.BlockStatement:
+		return c.compileBlock(stmt.Statements, tail)
+
+	default:
+		return c.errorf(stmt.T(), "unsupported statement %T", stmt)
+	}
+}
+
+func (c *Compiler) compile(node ast.Expression) error {
+	if node == nil {
+		return c.errorf(c.pos, "incomplete expression")
+	}
+
+	c.setPos(node.T())
+
+	switch node := node.(type) {
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
+
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+
+	case *ast.InterpolatedStringLiteral:
+		for _, segment := range node.Segments {
+			if err := c.compile(segment); err != nil {
+				return err
+			}
+		}
+		c.setPos(node.Token)
+		c.emit(code.OpInterpolate, len(node.Segments))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.AtomLiteral:
+		c.emit(code.OpConstant, c.addConstant(evaluator.Atom(node.Value)))
+
+	case *ast.Identifier:
+		c.loadIdentifier(node)
+
+	case *ast.PinExpression:
+		return c.errorf(node.Token, "cannot use %s outside of a pattern", node)
+
+	case *ast.SpreadExpression:
+		return c.errorf(node.Token, "cannot use %s outside of the arguments of a call", node)
+
+	case *ast.PrefixExpression:
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		c.setPos(node.Token)
+		switch node.Operator {
+		case "-":
+			c.emit(code.OpMinus)
+		case "!":
+			c.emit(code.OpBang)
+		default:
+			return c.errorf(node.Token, "unknown operator: %s", node.Operator)
+		}
+
+	case *ast.TryExpression:
+		if err := c.compile(node.Value); err != nil {
+			return err
+		}
+		c.setPos(node.Token)
+		c.emit(code.OpTry)
+
+	case *ast.InfixExpression:
+		return c.compileInfix(node)
+
+	case *ast.IfExpression:
+		return c.compileIf(node, false)
+
+	case *ast.CondExpression:
+		return c.compileCond(node, false)
+
+	case *ast.CaseExpression:
+		return c.compileCase(node, false)
+
+	case *ast.WithExpression:
+		return c.compileWith(node, false)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunction("anonymous", node.Parameters, nil, node.Rest, node.Body)
+
+	case *ast.CallExpression:
+		return c.compileCall(node, code.OpCall)
+
+	case *ast.IndexExpression:
+		if err := c.compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.compile(node.Index); err != nil {
+			return err
+		}
+		c.setPos(node.Token)
+		c.emit(code.OpIndex)
+
+	case *ast.PropertyAccessExpression:
+		return c.compilePropertyAccess(node)
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+		if node.Rest != nil {
+			if err := c.compile(node.Rest); err != nil {
+				return err
+			}
+			c.emit(code.OpConcat)
+		}
+
+	case *ast.TupleLiteral:
+		for _, el := range node.Elements {
+			if err := c.compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpTuple, len(node.Elements))
+
+	case *ast.MapLiteral:
+		for _, key := range node.Keys {
+			if err := c.compile(key); err != nil {
+				return err
+			}
+			if err := c.compile(node.Pairs[key]); err != nil {
+				return err
+			}
+		}
+		c.setPos(node.Token)
+		c.emit(code.OpMap, len(node.Pairs))
+
+	case *ast.MapUpdateLiteral:
+		return c.compileMapUpdate(node)
+
+	case *ast.StructLiteral:
+		c.emit(code.OpGetModule, c.addConstant(&object.String{Value: node.Name.Value}))
+
+		// the struct name comes first, then the field names in order
+		names := []object.Object{&object.String{Value: node.Name.Value}}
+		for i, field := range node.Fields {
+			names = append(names, &object.String{Value: field.Value})
+			if err := c.compile(node.Values[i]); err != nil {
+				return err
+			}
+		}
+		c.setPos(node.Token)
+		c.emit(code.OpStruct, c.addConstant(&object.Array{Elements: names}))
+
+	default:
+		return c.errorf(node.T(), "unsupported expression %T", node)
+	}
+
+	return nil
+}
+
+// compileTail compiles an expression in tail position of a function body.
+// Calls found there are made with OpTailCall, which reuses the caller's
+// frame, so deep recursion runs in constant space.
+func (c *Compiler) compileTail(node ast.Expression) error {
+	switch node := node.(type) {
+	case *ast.IfExpression:
+		c.setPos(node.Token)
+		return c.compileIf(node, true)
+	case *ast.CondExpression:
+		c.setPos(node.Token)
+		return c.compileCond(node, true)
+	case *ast.CaseExpression:
+		c.setPos(node.Token)
+		return c.compileCase(node, true)
+	case *ast.WithExpression:
+		c.setPos(node.Token)
+		return c.compileWith(node, true)
+	case *ast.CallExpression:
+		c.setPos(node.Token)
+		return c.compileCall(node, code.OpTailCall)
+	}
+	return c.compile(node)
+}
+
+func (c *Compiler) compileCall(node *ast.CallExpression, op code.Opcode) error {
+	if err := c.compile(node.Function); err != nil {
+		return err
+	}
+	if node.Placeholders() > 0 {
+		return c.compilePartial(node)
+	}
+	if node.KeywordNames != nil {
+		return c.compileKeywordCall(node)
+	}
+	if hasSpread(node.Arguments) {
+		return c.compileSpreadCall(node)
+	}
+	if err := c.compileArguments(node.Token, node.Arguments); err != nil {
+		return err
+	}
+	c.setPos(node.Token)
+	c.emit(op, len(node.Arguments))
+	return nil
+}
+
+// compileSpreadCall compiles the arguments of a call spreading arrays into
+// its arguments, which the vm expands before the call.
+func (c *Compiler) compileSpreadCall(node *ast.CallExpression) error {
+	spreads := []object.Object{}
+	args := make([]ast.Expression, len(node.Arguments))
+	for i, arg := range node.Arguments {
+		args[i] = arg
+		if spread, ok := arg.(*ast.SpreadExpression); ok {
+			spreads = append(spreads, &object.Integer{Value: int64(i)})
+			args[i] = spread.Value
+		}
+	}
+	if err := c.compileArguments(node.Token, args); err != nil {
+		return err
+	}
+	c.setPos(node.Token)
+	c.emit(code.OpCallSpread, c.addConstant(&object.Array{Elements: spreads}), len(args))
+	return nil
+}
+
+// compilePartial compiles the arguments given to a call with `_`
+// placeholders, which the vm makes into a function taking the others.
+func (c *Compiler) compilePartial(node *ast.CallExpression) error {
+	placeholders := []object.Object{}
+	args := []ast.Expression{}
+	for i, arg := range node.Arguments {
+		if ast.IsPlaceholder(arg) {
+			placeholders = append(placeholders, &object.Integer{Value: int64(i)})
+			continue
+		}
+		args = append(args, arg)
+	}
+	if err := c.compileArguments(node.Token, args); err != nil {
+		return err
+	}
+	c.setPos(node.Token)
+	c.emit(code.OpPartial, c.addConstant(&object.Array{Elements: placeholders}), len(node.Arguments))
+	return nil
+}
+
+func hasSpread(args []ast.Expression) bool {
+	for _, arg := range args {
+		if _, ok := arg.(*ast.SpreadExpression); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// compileKeywordCall compiles the arguments of a call given keyword
+// arguments, which the vm orders by the parameters of the function called.
+func (c *Compiler) compileKeywordCall(node *ast.CallExpression) error {
+	args := append(append([]ast.Expression{}, node.Arguments...), node.KeywordValues...)
+	if err := c.compileArguments(node.Token, args); err != nil {
+		return err
+	}
+
+	names := make([]object.Object, len(node.KeywordNames))
+	for i, name := range node.KeywordNames {
+		names[i] = &object.String{Value: name.Value}
+	}
+	c.setPos(node.Token)
+	c.emit(code.OpCallKeywords, c.addConstant(&object.Array{Elements: names}), len(args))
+	return nil
+}
+
+func (c *Compiler) compileInfix(node *ast.InfixExpression) error {
+	switch node.Operator {
+	case "::":
+		if err := c.compileSliceBound(node.Left, 0); err != nil {
+			return err
+		}
+		if err := c.compileSliceBound(node.Right, math.MaxInt64); err != nil {
+			return err
+		}
+		c.setPos(node.Token)
+		c.emit(code.OpSlice)
+		return nil
+
+	case "|>":
+		return c.compilePipe(node)
+
+	case "and", "or":
+		if err := c.compile(node.Left); err != nil {
+			return err
+		}
+		jump := code.OpJumpIfFalsy
+		if node.Operator == "or" {
+			jump = code.OpJumpIfTruthy
+		}
+		jumpPos := c.emit(jump, 9999)
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(code.OpToBool)
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+		return nil
+	}
+
+	op, ok := code.InfixOperators[node.Operator]
+	if !ok {
+		return c.errorf(node.Token, "unknown operator: %s", node.Operator)
+	}
+
+	if err := c.compile(node.Left); err != nil {
+		return err
+	}
+	if err := c.compile(node.Right); err != nil {
+		return err
+	}
+	c.setPos(node.Token)
+	c.emit(op)
+	return nil
+}
+
+// compileSliceBound compiles one side of `a::b`, where `_` means open ended.
+func (c *Compiler) compileSliceBound(bound ast.Expression, open int64) error {
+	if ident, ok := bound.(*ast.Identifier); ok && ident.Value == "_" {
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: open}))
+		return nil
+	}
+	return c.compile(bound)
+}
+
+// compilePipe compiles `x |> f(a)` as `f(x a)`, and `x |> f(a _)` as `f(a x)`.
+func (c *Compiler) compilePipe(node *ast.InfixExpression) error {
+	switch right := node.Right.(type) {
+	case *ast.CallExpression:
+		return c.compile(right.Piped(node.Left))
+	case *ast.PropertyAccessExpression:
+		call, ok := right.Right.(*ast.CallExpression)
+		if !ok {
+			return c.errorf(node.Token, "pipe operator must be followed by a function call")
+		}
+		return c.compile(&ast.PropertyAccessExpression{Token: right.Token, Left: right.Left, Right: call.Piped(node.Left)})
+	case *ast.FunctionLiteral:
+		if len(right.Parameters) > 1 || len(right.Parameters) == 0 && right.Rest == nil {
+			return c.errorf(node.Token, "function literal must take exactly one argument")
+		}
+		return c.compile(&ast.CallExpression{Token: node.Token, Function: right, Arguments: []ast.Expression{node.Left}})
+	default:
+		return c.errorf(node.Token, "pipe operator must be followed by a function call")
+	}
+}
+
+func (c *Compiler) compileIf(node *ast.IfExpression, tail bool) error {
+	if err := c.compile(node.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+	if err := c.compileBlock(node.Consequence.Statements, tail); err != nil {
+		return err
+	}
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+	if node.Alternative == nil {
+		c.emit(code.OpNil)
+	} else if err := c.compileBlock(node.Alternative.Statements, tail); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+func (c *Compiler) compileCond(node *ast.CondExpression, tail bool) error {
+	ends := []int{}
+	for i, condition := range node.Conditions {
+		if err := c.compile(condition); err != nil {
+			return err
+		}
+		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+		if err := c.compileBlock(node.Consequences[i].Statements, tail); err != nil {
+			return err
+		}
+		ends = append(ends, c.emit(code.OpJump, 9999))
+		c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+	}
+
+	c.emit(code.OpNil)
+
+	for _, pos := range ends {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	return nil
+}
+
+// compileCase keeps the tested value on the stack while each branch's pattern
+// is tried in turn. Bindings made by a pattern are only visible in its branch.
+func (c *Compiler) compileCase(node *ast.CaseExpression, tail bool) error {
+	if err := c.compile(node.Test); err != nil {
+		return err
+	}
+	return c.compileCaseClauses(node.Token, node.Conditions, node.Guards, node.Consequences, tail)
+}
+
+// compileCaseClauses matches the value on top of the stack against the clauses
+// of a case, leaving the value of the branch taken in its place.
+func (c *Compiler) compileCaseClauses(tok token.Token, conditions, guards []ast.Expression, consequences []*ast.BlockStatement, tail bool) error {
+	ends := []int{}
+	for i, condition := range conditions {
+		outer := c.symbolTable
+		c.symbolTable = NewBlockSymbolTable(outer)
+
+		c.emit(code.OpDup)
+		if err := c.compileMatch(condition, true); err != nil {
+			return err
+		}
+		nextPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		// a clause whose guard is falsy falls through to the next one
+		guardPos := -1
+		if guard := guards[i]; guard != nil {
+			if err := c.compile(guard); err != nil {
+				return err
+			}
+			guardPos = c.emit(code.OpJumpNotTruthy, 9999)
+		}
+
+		c.emit(code.OpPop)
+		if err := c.compileBlock(consequences[i].Statements, tail); err != nil {
+			return err
+		}
+		ends = append(ends, c.emit(code.OpJump, 9999))
+		c.changeOperand(nextPos, len(c.currentInstructions()))
+		if guardPos != -1 {
+			c.changeOperand(guardPos, len(c.currentInstructions()))
+		}
+
+		c.symbolTable = outer
+	}
+
+	c.setPos(tok)
+	c.emit(code.OpNoMatch, c.addConstant(&object.String{Value: "no matching case for %s"}))
+
+	for _, pos := range ends {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	return nil
+}
+
+// compileWith compiles the statements of a with in a scope of their own. A
+// match step that fails jumps past them with its value on the stack, which
+// is the result unless the with has else clauses to match it against.
+func (c *Compiler) compileWith(node *ast.WithExpression, tail bool) error {
+	outer := c.symbolTable
+	c.symbolTable = NewBlockSymbolTable(outer)
+	outerJumps := c.stepJumps
+	c.stepJumps = nil
+
+	err := c.compileBlock(node.Body.Statements, tail)
+	jumps := c.stepJumps
+	c.stepJumps = outerJumps
+	c.symbolTable = outer
+	if err != nil {
+		return err
+	}
+
+	endPos := c.emit(code.OpJump, 9999)
+	for _, pos := range jumps {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	if node.Conditions != nil {
+		if err := c.compileCaseClauses(node.Token, node.Conditions, node.Guards, node.Consequences, tail); err != nil {
+			return err
+		}
+	}
+	c.changeOperand(endPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileMatch matches the value on top of the stack against a pattern. In a
+// case branch the match pushes whether it succeeded; in a let a mismatch is
+// an error.
+func (c *Compiler) compileMatch(expr ast.Expression, inCase bool) error {
+	b := &patternBuilder{c: c}
+	pattern, err := b.build(expr)
+	if err != nil {
+		return err
+	}
+	pattern.NumValues = b.numValues
+	pattern.Source = expr.String()
+
+	mode := 1
+	if inCase {
+		mode = 0
+	}
+
+	c.setPos(expr.T())
+	c.emit(code.OpMatch, c.addConstant(pattern), mode)
+	return nil
+}
+
+type patternBuilder struct {
+	c         *Compiler
+	numValues int
+}
+
+func (b *patternBuilder) build(expr ast.Expression) (*Pattern, error) {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		if expr.Value == "_" {
+			return &Pattern{Kind: PatternWildcard}, nil
+		}
+		if unicode.IsUpper(rune(expr.Value[0])) {
+			return nil, b.c.errorf(expr.Token, "local variables can not start with an uppercase letter")
+		}
+		return &Pattern{Kind: PatternBind, Symbol: b.c.define(expr.Value)}, nil
+
+	case *ast.TupleLiteral:
+		elements, err := b.buildAll(expr.Elements)
+		return &Pattern{Kind: PatternTuple, Elements: elements}, err
+
+	case *ast.ArrayLiteral:
+		elements, err := b.buildAll(expr.Elements)
+		if err != nil || expr.Rest == nil {
+			return &Pattern{Kind: PatternArray, Elements: elements}, err
+		}
+		rest, err := b.build(expr.Rest)
+		return &Pattern{Kind: PatternArray, Elements: elements, Rest: rest}, err
+
+	case *ast.StructLiteral:
+		fields := make([]string, len(expr.Fields))
+		for i, field := range expr.Fields {
+			fields[i] = field.Value
+		}
+		elements, err := b.buildAll(expr.Values)
+		return &Pattern{Kind: PatternStruct, Module: expr.Name.Value, Fields: fields, Elements: elements}, err
+
+	case *ast.PinExpression:
+		// a pinned name is matched by its value, like a literal
+		if err := b.c.compile(expr.Name); err != nil {
+			return nil, err
+		}
+		b.numValues++
+		return &Pattern{Kind: PatternValue, Value: b.numValues - 1}, nil
+
+	case *ast.InfixExpression:
+		if expr.Operator != "in" {
+			break
+		}
+		if err := b.c.compile(expr.Right); err != nil {
+			return nil, err
+		}
+		b.numValues++
+		collection := b.numValues - 1
+		element, err := b.build(expr.Left)
+		return &Pattern{Kind: PatternMember, Value: collection, Elements: []*Pattern{element}}, err
+
+	case *ast.MapLiteral:
+		pattern := &Pattern{Kind: PatternMap}
+		for key, value := range expr.Pairs {
+			if err := b.c.compile(key); err != nil {
+				return nil, err
+			}
+			pattern.Keys = append(pattern.Keys, b.numValues)
+			b.numValues++
+
+			element, err := b.build(value)
+			if err != nil {
+				return nil, err
+			}
+			pattern.Elements = append(pattern.Elements, element)
+		}
+		return pattern, nil
+	}
+
+	if err := b.c.compile(expr); err != nil {
+		return nil, err
+	}
+	b.numValues++
+	return &Pattern{Kind: PatternValue, Value: b.numValues - 1}, nil
+}
+
+func (b *patternBuilder) buildAll(exprs []ast.Expression) ([]*Pattern, error) {
+	patterns := make([]*Pattern, len(exprs))
+	for i, expr := range exprs {
+		pattern, err := b.build(expr)
+		if err != nil {
+			return nil, err
+		}
+		patterns[i] = pattern
+	}
+	return patterns, nil
+}
+
+func (c *Compiler) compileFunction(name string, params []*ast.Identifier, defaults []ast.Expression, rest *ast.Identifier, body *ast.BlockStatement) error {
+	c.enterFunctionScope(name)
+
+	names := make([]string, len(params))
+	symbols := make([]Symbol, len(params))
+	for i, p := range params {
+		names[i] = p.Value
+		symbols[i] = c.symbolTable.DefineParameter(p.Value)
+	}
+	// the vm gathers the arguments after the parameters into the next slot
+	if rest != nil {
+		c.symbolTable.DefineParameter(rest.Value)
+	}
+
+	// parameters left out of the call are set to their defaults first
+	numDefaults := 0
+	for i, d := range defaults {
+		if d == nil {
+			continue
+		}
+		numDefaults++
+		skipPos := c.emit(code.OpDefault, symbols[i].Index, 9999)
+		if err := c.compile(d); err != nil {
+			return err
+		}
+		c.storeSymbol(symbols[i])
+		c.replaceInstruction(skipPos, code.Make(code.OpDefault, symbols[i].Index, len(c.currentInstructions())))
+	}
+
+	if err := c.compileBlock(body.Statements, true); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+
+	fn := c.emitClosure(name, len(params))
+	fn.NumDefaults = numDefaults
+	fn.Variadic = rest != nil
+	fn.Parameters = names
+	return nil
+}
+
+// compileClauses compiles a function that runs the first of its clauses
+// whose patterns match the arguments.
+func (c *Compiler) compileClauses(name string, clauses []*ast.FunctionClause) error {
+	c.enterFunctionScope(name)
+
+	// the arguments are held in slots no name in the source can refer to
+	args := make([]Symbol, len(clauses[0].Patterns))
+	for i := range args {
+		args[i] = c.symbolTable.DefineParameter(fmt.Sprintf("$%d", i))
+	}
+
+	for _, clause := range clauses {
+		outer := c.symbolTable
+		c.symbolTable = NewBlockSymbolTable(outer)
+
+		nextPos := []int{}
+		for i, pattern := range clause.Patterns {
+			c.loadSymbol(args[i])
+			if err := c.compileMatch(pattern, true); err != nil {
+				return err
+			}
+			nextPos = append(nextPos, c.emit(code.OpJumpNotTruthy, 9999))
+		}
+
+		if err := c.compileBlock(clause.Body.Statements, true); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+		for _, pos := range nextPos {
+			c.changeOperand(pos, len(c.currentInstructions()))
+		}
+
+		c.symbolTable = outer
+	}
+
+	for _, arg := range args {
+		c.loadSymbol(arg)
+	}
+	c.emit(code.OpTuple, len(args))
+	c.setPos(clauses[0].Token)
+	c.emit(code.OpNoMatch, c.addConstant(&object.String{Value: evaluator.NoMatchingClause(name, clauses)}))
+
+	c.emitClosure(name, len(args))
+	return nil
+}
+
+// enterFunctionScope starts compiling a function, in which its name refers to
+// itself. An overloaded name refers to the Overloads it is bound to instead.
+func (c *Compiler) enterFunctionScope(name string) {
+	_, overloaded := c.overloaded[name]
+	c.enterScope()
+	if name != "anonymous" && !overloaded {
+		c.symbolTable.DefineFunctionName(name)
+	}
+}
+
+// emitClosure leaves the scope of the function just compiled, and emits the
+// closure over it.
+func (c *Compiler) emitClosure(name string, numParameters int) *object.CompiledFunction {
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.NumDefinitions()
+	positions := c.scopes[c.scopeIndex].positions
+	instructions := c.leaveScope()
+
+	for _, s := range freeSymbols {
+		c.loadSymbol(s)
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: numParameters,
+		Name:          name,
+		FileName:      c.fileName,
+		Positions:     positions,
+	}
+
+	c.emit(code.OpClosure, c.addConstant(compiledFn), len(freeSymbols))
+	return compiledFn
+}
+
+func (c *Compiler) compileArguments(tok token.Token, args []ast.Expression) error {
+	if len(args) > math.MaxUint8 {
+		return c.errorf(tok, "too many arguments: %d", len(args))
+	}
+	for _, arg := range args {
+		if err := c.compile(arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Compiler) compilePropertyAccess(node *ast.PropertyAccessExpression) error {
+	if err := c.compile(node.Left); err != nil {
+		return err
+	}
+
+	switch right := node.Right.(type) {
+	case *ast.Identifier:
+		c.setPos(node.Token)
+		c.emit(code.OpGetProperty, c.addConstant(&object.String{Value: right.Value}))
+	case *ast.CallExpression:
+		ident, ok := right.Function.(*ast.Identifier)
+		if !ok {
+			return c.errorf(right.Token, "invalid function call: %s", right.Function.String())
+		}
+		if right.Placeholders() > 0 {
+			c.setPos(node.Token)
+			c.emit(code.OpGetProperty, c.addConstant(&object.String{Value: ident.Value}))
+			return c.compilePartial(right)
+		}
+		if right.KeywordNames != nil {
+			c.setPos(node.Token)
+			c.emit(code.OpGetProperty, c.addConstant(&object.String{Value: ident.Value}))
+			return c.compileKeywordCall(right)
+		}
+		if hasSpread(right.Arguments) {
+			c.setPos(node.Token)
+			c.emit(code.OpGetProperty, c.addConstant(&object.String{Value: ident.Value}))
+			return c.compileSpreadCall(right)
+		}
+		if err := c.compileArguments(right.Token, right.Arguments); err != nil {
+			return err
+		}
+		c.setPos(right.Token)
+		c.emit(code.OpCallProperty, c.addConstant(&object.String{Value: ident.Value}), len(right.Arguments))
+	default:
+		return c.errorf(node.Token, "invalid property access")
+	}
+
+	return nil
+}
+
+// compileMapUpdate compiles `{ m with key = value }`. Whether the keys are
+// map keys or struct fields is only known at run time, so the field names
+// are kept alongside the compiled keys.
+func (c *Compiler) compileMapUpdate(node *ast.MapUpdateLiteral) error {
+	if err := c.compile(node.Left); err != nil {
+		return err
+	}
+
+	names := []object.Object{}
+	for _, key := range node.Keys {
+		value := node.Right[key]
+		switch key := key.(type) {
+		case *ast.Identifier:
+			names = append(names, &object.String{Value: key.Value})
+			if _, ok := c.symbolTable.Resolve(key.Value); ok {
+				c.loadIdentifier(key)
+			} else {
+				c.emit(code.OpConstant, c.addConstant(evaluator.Atom(key.Value)))
+			}
+		case *ast.AtomLiteral:
+			names = append(names, &object.String{Value: key.Value})
+			if err := c.compile(key); err != nil {
+				return err
+			}
+		default:
+			names = append(names, &object.String{Value: ""})
+			if err := c.compile(key); err != nil {
+				return err
+			}
+		}
+
+		if err := c.compile(value); err != nil {
+			return err
+		}
+	}
+
+	c.setPos(node.Token)
+	c.emit(code.OpMapUpdate, c.addConstant(&object.Array{Elements: names}))
+	return nil
+}
+
+func (c *Compiler) loadIdentifier(node *ast.Identifier) {
+	if node.Value == "_" {
+		c.emit(code.OpNil)
+		return
+	}
+
+	if unicode.IsUpper(rune(node.Value[0])) {
+		c.emit(code.OpGetModule, c.addConstant(&object.String{Value: node.Value}))
+		return
+	}
+
+	if symbol, ok := c.symbolTable.Resolve(node.Value); ok {
+		c.loadSymbol(symbol)
+		return
+	}
+
+	if builtin, ok := evaluator.LookupBuiltin(node.Value); ok {
+		c.emit(code.OpConstant, c.addConstant(builtin))
+		return
+	}
+
+	// `r?` is read as one name, and unwraps r as `(r)?` when only r is bound
+	if base, ok := strings.CutSuffix(node.Value, "?"); ok && base != "" {
+		if symbol, ok := c.symbolTable.Resolve(base); ok {
+			c.loadSymbol(symbol)
+			c.emit(code.OpTry)
+			return
+		}
+	}
+
+	// not known yet, so it has to be a global defined before this runs
+	c.loadSymbol(c.symbolTable.Root().Define(node.Value))
+}
+
+func (c *Compiler) define(name string) Symbol {
+	symbol := c.symbolTable.Define(name)
+	if symbol.Scope == GlobalScope {
+		c.definedGlobals[name] = true
+	}
+	return symbol
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
+
+func (c *Compiler) storeSymbol(s Symbol) {
+	if s.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, s.Index)
+	} else {
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	scope := &c.scopes[c.scopeIndex]
+	posNewInstruction := len(scope.instructions)
+
+	if n := len(scope.positions); n == 0 || scope.positions[n-1].Line != c.pos.Line || scope.positions[n-1].Column != c.pos.Column {
+		scope.positions = append(scope.positions, object.SourcePos{Offset: posNewInstruction, Line: c.pos.Line, Column: c.pos.Column})
+	}
+
+	scope.instructions = append(scope.instructions, ins...)
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	c.scopes[c.scopeIndex].lastInstruction = EmittedInstruction{Opcode: op, Position: pos}
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := code.Make(op, operand)
+
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{instructions: code.Instructions{}})
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
+
+// setPos records where the instructions emitted next came from. Synthesised
+// nodes without a position keep the current one.
+func (c *Compiler) setPos(tok token.Token) {
+	if tok.Line > 0 {
+		c.pos = tok
+	}
+}
+
+func (c *Compiler) errorf(tok token.Token, format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...), Line: tok.Line, Column: tok.Column, FileName: tok.FileName}
+}
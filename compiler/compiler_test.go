@@ -0,0 +1,179 @@
+// compiler/compiler_test.go
+
+package compiler
+
+import (
+	"testing"
+
+	"renelle/ast"
+	"renelle/code"
+	"renelle/lexer"
+	"renelle/object"
+	"renelle/parser"
+)
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input, "test")
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func concatInstructions(s ...[]byte) code.Instructions {
+	out := code.Instructions{}
+	for _, ins := range s {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+func TestCompileInstructions(t *testing.T) {
+	tests := []struct {
+		input        string
+		instructions code.Instructions
+	}{
+		{
+			"1 + 2",
+			concatInstructions(
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpReturnValue),
+			),
+		},
+		{
+			"let a = 1 a",
+			concatInstructions(
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpDup),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpReturnValue),
+			),
+		},
+		{
+			"if true { 1 }",
+			concatInstructions(
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJump, 11),
+				code.Make(code.OpNil),
+				code.Make(code.OpReturnValue),
+			),
+		},
+		{
+			"true and false",
+			concatInstructions(
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpIfFalsy, 6),
+				code.Make(code.OpFalse),
+				code.Make(code.OpToBool),
+				code.Make(code.OpReturnValue),
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		compiler := New()
+		if err := compiler.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		got := compiler.Bytecode().Instructions
+		if got.String() != tt.instructions.String() {
+			t.Errorf("wrong instructions for %q.\nwant=\n%s\ngot=\n%s", tt.input, tt.instructions, got)
+		}
+	}
+}
+
+func TestCompileFunction(t *testing.T) {
+	compiler := New()
+	if err := compiler.Compile(parse("fn f(a) { let g = \\x => x + a g }")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	var fns []*object.CompiledFunction
+	for _, c := range compiler.Bytecode().Constants {
+		if fn, ok := c.(*object.CompiledFunction); ok {
+			fns = append(fns, fn)
+		}
+	}
+
+	if len(fns) != 2 {
+		t.Fatalf("expected 2 compiled functions. got=%d", len(fns))
+	}
+
+	// the inner function is compiled first, capturing a as a free variable
+	expected := concatInstructions(
+		code.Make(code.OpGetLocal, 0),
+		code.Make(code.OpGetFree, 0),
+		code.Make(code.OpAdd),
+		code.Make(code.OpReturnValue),
+	)
+	if code.Instructions(fns[0].Instructions).String() != expected.String() {
+		t.Errorf("wrong inner function.\nwant=\n%s\ngot=\n%s", expected, code.Instructions(fns[0].Instructions))
+	}
+
+	if fns[0].Name != "g" || fns[1].Name != "f" {
+		t.Errorf("wrong function names. got=%q, %q", fns[0].Name, fns[1].Name)
+	}
+
+	if fns[1].NumParameters != 1 || fns[1].NumLocals != 2 {
+		t.Errorf("wrong locals for f. got params=%d locals=%d", fns[1].NumParameters, fns[1].NumLocals)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		line     int
+		column   int
+	}{
+		{"let A = 1", "local variables can not start with an uppercase letter", 1, 5},
+		{"let (a B) = (1 2)", "local variables can not start with an uppercase letter", 1, 8},
+	}
+
+	for _, tt := range tests {
+		err := New().Compile(parse(tt.input))
+		compileErr, ok := err.(*Error)
+		if !ok {
+			t.Errorf("expected compile error for %q. got=%v", tt.input, err)
+			continue
+		}
+		if compileErr.Message != tt.expected {
+			t.Errorf("wrong message. want=%q, got=%q", tt.expected, compileErr.Message)
+		}
+		if compileErr.Line != tt.line || compileErr.Column != tt.column {
+			t.Errorf("wrong position. want=%d:%d, got=%d:%d", tt.line, tt.column, compileErr.Line, compileErr.Column)
+		}
+	}
+}
+
+func TestCompileTailCall(t *testing.T) {
+	compiler := New()
+	if err := compiler.Compile(parse("fn f(n) { g(n) f(n) }")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	fn, ok := compiler.Bytecode().Constants[0].(*object.CompiledFunction)
+	if !ok {
+		t.Fatalf("constant is not a compiled function. got=%T", compiler.Bytecode().Constants[0])
+	}
+
+	// only the call in tail position reuses the frame
+	expected := concatInstructions(
+		code.Make(code.OpGetGlobal, 1),
+		code.Make(code.OpGetLocal, 0),
+		code.Make(code.OpCall, 1),
+		code.Make(code.OpPop),
+		code.Make(code.OpCurrentClosure),
+		code.Make(code.OpGetLocal, 0),
+		code.Make(code.OpTailCall, 1),
+		code.Make(code.OpReturnValue),
+	)
+	if code.Instructions(fn.Instructions).String() != expected.String() {
+		t.Errorf("wrong instructions.\nwant=\n%s\ngot=\n%s", expected, code.Instructions(fn.Instructions))
+	}
+}
@@ -0,0 +1,56 @@
+// compiler/pattern.go
+
+package compiler
+
+import (
+	"fmt"
+
+	"renelle/ast"
+	"renelle/object"
+)
+
+type PatternKind int
+
+const (
+	PatternWildcard PatternKind = iota // _
+	PatternBind                        // binds the value to Symbol
+	PatternValue                       // the value must equal an expression's value
+	PatternTuple
+	PatternArray
+	PatternMap
+	PatternStruct
+	PatternMember // the value must be in the collection at Value, and match Elements[0]
+)
+
+// Pattern describes the shape a value is matched against in `case` and
+// destructuring `let`. It is stored as a constant and interpreted by OpMatch.
+//
+// Expressions inside the pattern (literals, map keys) are compiled to code
+// that runs before the match; Value and Keys index into those results.
+type Pattern struct {
+	Kind     PatternKind
+	Symbol   Symbol
+	Value    int
+	Keys     []int
+	Module   string   // struct patterns
+	Fields   []string // struct patterns
+	Elements []*Pattern
+	Rest     *Pattern // array patterns written [h | t], nil if none
+
+	NumValues int // number of expression values OpMatch pops
+	Source    string
+}
+
+func (p *Pattern) Type() object.ObjectType { return "PATTERN" }
+func (p *Pattern) Inspect() string         { return fmt.Sprintf("pattern %s", p.Source) }
+
+// ModuleDefinition holds a module declaration. The vm hands it to the
+// evaluator, so modules behave the same whichever engine loads them.
+type ModuleDefinition struct {
+	Node *ast.Module
+}
+
+func (md *ModuleDefinition) Type() object.ObjectType { return "MODULE_DEFINITION" }
+func (md *ModuleDefinition) Inspect() string {
+	return fmt.Sprintf("module definition %s", md.Node.Name.Value)
+}
@@ -0,0 +1,120 @@
+// compiler/symbol_table.go
+
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+// SymbolTable resolves names for one function, or for a block inside one.
+// Block tables (used for case branches) keep their names to themselves but
+// allocate slots from the function they belong to.
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	FreeSymbols []Symbol
+
+	store          map[string]Symbol
+	numDefinitions *int
+	names          *[]string
+	global         bool
+	block          bool
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: make(map[string]Symbol), numDefinitions: new(int), names: &[]string{}, global: true}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := &SymbolTable{store: make(map[string]Symbol), numDefinitions: new(int), names: &[]string{}}
+	s.Outer = outer
+	return s
+}
+
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := &SymbolTable{store: make(map[string]Symbol), numDefinitions: outer.numDefinitions, names: outer.names, global: outer.global, block: true}
+	s.Outer = outer
+	return s
+}
+
+// NumDefinitions is the number of slots the function needs for its locals.
+func (s *SymbolTable) NumDefinitions() int {
+	return *s.numDefinitions
+}
+
+// Names returns the name given to each slot, by index.
+func (s *SymbolTable) Names() []string {
+	return *s.names
+}
+
+// Define binds name in this table, reusing its slot if it is already bound.
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok && (symbol.Scope == GlobalScope || symbol.Scope == LocalScope) {
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Index: *s.numDefinitions, Scope: LocalScope}
+	if s.global {
+		symbol.Scope = GlobalScope
+	}
+
+	s.store[name] = symbol
+	*s.numDefinitions++
+	*s.names = append(*s.names, name)
+	return symbol
+}
+
+// DefineParameter always gives the name a new slot, so that each parameter
+// lines up with its argument even if names repeat, as in `\_ _ => 1`.
+func (s *SymbolTable) DefineParameter(name string) Symbol {
+	delete(s.store, name)
+	return s.Define(name)
+}
+
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	obj, ok := s.store[name]
+	if ok || s.Outer == nil {
+		return obj, ok
+	}
+
+	obj, ok = s.Outer.Resolve(name)
+	if !ok || s.block || obj.Scope == GlobalScope {
+		return obj, ok
+	}
+
+	return s.defineFree(obj), true
+}
+
+// Root returns the table holding the globals.
+func (s *SymbolTable) Root() *SymbolTable {
+	root := s
+	for root.Outer != nil {
+		root = root.Outer
+	}
+	return root
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Scope: FreeScope}
+	s.store[original.Name] = symbol
+	return symbol
+}
@@ -0,0 +1,95 @@
+// compiler/symbol_table_test.go
+
+package compiler
+
+import "testing"
+
+func TestDefineResolve(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+	if a != (Symbol{Name: "a", Scope: GlobalScope, Index: 0}) {
+		t.Errorf("wrong global symbol. got=%+v", a)
+	}
+
+	// redefining a name reuses its slot
+	if again := global.Define("a"); again != a {
+		t.Errorf("redefined symbol got a new slot. got=%+v", again)
+	}
+
+	local := NewEnclosedSymbolTable(global)
+	b := local.Define("b")
+	if b != (Symbol{Name: "b", Scope: LocalScope, Index: 0}) {
+		t.Errorf("wrong local symbol. got=%+v", b)
+	}
+
+	nested := NewEnclosedSymbolTable(local)
+	c := nested.Define("c")
+
+	expected := []Symbol{
+		{Name: "a", Scope: GlobalScope, Index: 0},
+		{Name: "b", Scope: FreeScope, Index: 0},
+		{Name: "c", Scope: LocalScope, Index: 0},
+	}
+
+	for _, sym := range expected {
+		result, ok := nested.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", sym.Name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+
+	if len(nested.FreeSymbols) != 1 || nested.FreeSymbols[0] != b {
+		t.Errorf("wrong free symbols. got=%+v", nested.FreeSymbols)
+	}
+
+	if c.Index != 0 || nested.NumDefinitions() != 1 {
+		t.Errorf("wrong number of definitions. got=%d", nested.NumDefinitions())
+	}
+}
+
+func TestBlockSymbolTable(t *testing.T) {
+	local := NewEnclosedSymbolTable(NewSymbolTable())
+	local.Define("a")
+
+	block := NewBlockSymbolTable(local)
+	b := block.Define("b")
+	if b != (Symbol{Name: "b", Scope: LocalScope, Index: 1}) {
+		t.Errorf("block symbol should take the next slot of its function. got=%+v", b)
+	}
+
+	if a, ok := block.Resolve("a"); !ok || a.Scope != LocalScope {
+		t.Errorf("outer local should stay local in a block. got=%+v", a)
+	}
+
+	if _, ok := local.Resolve("b"); ok {
+		t.Errorf("block symbol should not be visible outside the block")
+	}
+
+	if local.NumDefinitions() != 2 {
+		t.Errorf("block definitions should count towards the function. got=%d", local.NumDefinitions())
+	}
+}
+
+func TestDefineParameter(t *testing.T) {
+	local := NewEnclosedSymbolTable(NewSymbolTable())
+	first := local.DefineParameter("_")
+	second := local.DefineParameter("_")
+
+	if first.Index != 0 || second.Index != 1 {
+		t.Errorf("repeated parameters should get their own slots. got=%d, %d", first.Index, second.Index)
+	}
+}
+
+func TestDefineFunctionName(t *testing.T) {
+	local := NewEnclosedSymbolTable(NewSymbolTable())
+	local.DefineFunctionName("f")
+
+	expected := Symbol{Name: "f", Scope: FunctionScope, Index: 0}
+	if result, ok := local.Resolve("f"); !ok || result != expected {
+		t.Errorf("expected f to resolve to %+v, got=%+v", expected, result)
+	}
+}
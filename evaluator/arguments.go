@@ -0,0 +1,131 @@
+// evaluator/arguments.go
+
+package evaluator
+
+import (
+	"renelle/ast"
+	"renelle/object"
+)
+
+// evalArguments evaluates the arguments of a call to function, returning the
+// function to call, which is picked from an Overloads when keyword arguments
+// are given, and the arguments in the order of its parameters.
+func evalArguments(function object.Object, call *ast.CallExpression, env *object.Environment, ctx *object.EvalContext) (object.Object, []object.Object, object.Object) {
+	args, err := evalSpreadArguments(call.Arguments, env, ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if call.KeywordNames == nil {
+		return function, args, nil
+	}
+
+	values := evalExpressions(call.KeywordValues, env, ctx)
+	if len(values) == 1 && isError(values[0]) {
+		return nil, nil, values[0]
+	}
+	names := make([]string, len(call.KeywordNames))
+	for i, name := range call.KeywordNames {
+		names[i] = name.Value
+	}
+
+	ctx.Line = call.Token.Line
+	ctx.Column = call.Token.Column
+	return bindKeywords(ctx, function, args, names, values)
+}
+
+// evalSpreadArguments evaluates the positional arguments of a call, passing
+// the elements of an array spread with `...` as arguments of their own.
+func evalSpreadArguments(exps []ast.Expression, env *object.Environment, ctx *object.EvalContext) ([]object.Object, object.Object) {
+	args := make([]object.Object, 0, len(exps))
+	for _, e := range exps {
+		spread, ok := e.(*ast.SpreadExpression)
+		if !ok {
+			evaluated := Eval(e, env, ctx)
+			if isError(evaluated) {
+				return nil, evaluated
+			}
+			args = append(args, evaluated)
+			continue
+		}
+
+		evaluated := Eval(spread.Value, env, ctx)
+		if isError(evaluated) {
+			return nil, evaluated
+		}
+		array, ok := evaluated.(*object.Array)
+		if r, isRange := evaluated.(*object.Range); isRange {
+			array, ok = r.Array()
+		}
+		if !ok {
+			ctx.Line = spread.Token.Line
+			ctx.Column = spread.Token.Column
+			return nil, newError(ctx, "cannot spread %s, expected an array", evaluated.Type())
+		}
+		args = append(args, array.Elements...)
+	}
+	return args, nil
+}
+
+// bindKeywords places keyword arguments after the positional ones, at the
+// parameters they name. Parameters left out in between are nil, and take
+// their default values when the function is called.
+func bindKeywords(ctx *object.EvalContext, function object.Object, args []object.Object, names []string, values []object.Object) (object.Object, []object.Object, object.Object) {
+	if overloads, ok := function.(*object.Overloads); ok {
+		if fn, ok := overloads.Lookup(len(args) + len(names)); ok {
+			function = fn
+		}
+	}
+
+	var params []string
+	switch fn := function.(type) {
+	case *object.Function:
+		if fn.Clauses == nil {
+			params = fn.ParameterNames()
+		}
+	case *object.Closure:
+		params = fn.Fn.Parameters
+	}
+	if params == nil {
+		return nil, nil, newError(ctx, "keyword arguments can only be given to functions with named parameters, got %s", function.Type())
+	}
+
+	least, most := object.ArityRange(function)
+	if len(args) > most {
+		return nil, nil, newError(ctx, "wrong number of arguments. got=%d, want=%s", len(args)+len(names), object.ArityString(least, most))
+	}
+
+	// arguments past the parameters are kept for a rest parameter
+	bound := make([]object.Object, max(len(params), len(args)))
+	copy(bound, args)
+	last := len(args)
+	for i, name := range names {
+		index := indexOf(params, name)
+		if index < 0 {
+			return nil, nil, newError(ctx, "unknown keyword argument %s", name)
+		}
+		if bound[index] != nil {
+			return nil, nil, newError(ctx, "argument %s given more than once", name)
+		}
+		bound[index] = values[i]
+		if index >= last {
+			last = index + 1
+		}
+	}
+
+	for i := 0; i < least; i++ {
+		if bound[i] == nil {
+			return nil, nil, newError(ctx, "missing argument %s", params[i])
+		}
+	}
+
+	return function, bound[:last], nil
+}
+
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -5,45 +5,63 @@ package evaluator
 import (
 	"fmt"
 	"renelle/constants"
+	"renelle/hostlib"
 	"renelle/object"
 )
 
+// The Array functions that call back into Renelle code live here rather
+// than in hostlib.
+func init() {
+	hostlib.Register("Array",
+		hostlib.Function{Name: "iter", Arities: []int{2}, Fn: iter,
+			Doc: "Calls the function with each element of the array, returning :ok."},
+		hostlib.Function{Name: "reduce", Arities: []int{2, 3}, Fn: reduce,
+			Doc: "Folds the array into one value with the function, starting from the initial value or the first element."},
+		hostlib.Function{Name: "reduce_while", Arities: []int{2, 3}, Fn: reduceWhile,
+			Doc: "Folds the array like reduce, until the function returns (:halt acc) instead of (:cont acc)."},
+	)
+}
+
+// elements iterates over an array, or a range without copying it.
+func elements(obj object.Object) (object.Iterator, bool) {
+	switch obj.(type) {
+	case *object.Array, *object.Range:
+		return object.Iterate(obj)
+	}
+	return nil, false
+}
+
 func reduceWhile(ctx *object.EvalContext, args ...object.Object) object.Object {
 	if len(args) != 2 && len(args) != 3 {
 		return newError(ctx, "wrong number of arguments. got=%d, want=2 or 3", len(args))
 	}
 
-	list, ok := args[0].(*object.Array)
+	next, ok := elements(args[0])
 	if !ok {
 		return newError(ctx, "first argument to `reduce_while` must be ARRAY, got %s", args[0].Type())
 	}
 
 	var initial object.Object
-	var fn *object.Function
-	var startIndex int
+	var fn object.Object
 
 	if len(args) == 2 {
-		if len(list.Elements) == 0 {
+		if initial, ok = next(ctx); !ok {
 			return newError(ctx, "cannot reduce empty array without initial value")
 		}
-		initial = list.Elements[0]
-		fn, ok = args[1].(*object.Function)
-		if !ok {
+		fn = args[1]
+		if !isCallable(fn) {
 			return newError(ctx, "second argument to `reduce_while` must be FUNCTION, got %s", args[1].Type())
 		}
-		startIndex = 1
 	} else {
 		initial = args[1]
-		fn, ok = args[2].(*object.Function)
-		if !ok {
+		fn = args[2]
+		if !isCallable(fn) {
 			return newError(ctx, "third argument to `reduce_while` must be FUNCTION, got %s", args[2].Type())
 		}
-		startIndex = 0
 	}
 
 	accumulator := initial
-	for i := startIndex; i < len(list.Elements); i++ {
-		elem := list.Elements[i]
+	for elem, ok := next(ctx); ok; elem, ok = next(ctx) {
 		result := applyFunction(fn, []object.Object{accumulator, elem}, ctx)
 		if result.Type() == object.ERROR_OBJ {
 			return result
@@ -76,28 +94,18 @@ func iter(ctx *object.EvalContext, args ...object.Object) object.Object {
 		return newError(ctx, "wrong number of arguments. got=%d, want=2", len(args))
 	}
 
-	list, ok := args[0].(*object.Array)
+	next, ok := elements(args[0])
 	if !ok {
 		return newError(ctx, "first argument to `iter` must be ARRAY, got %s", args[0].Type())
 	}
 
-	var fn object.Object
-	switch arg := args[1].(type) {
-	case *object.Function:
-		fn = arg
-	case *object.Builtin:
-		fn = arg
-	default:
+	fn := args[1]
+	if !isCallable(fn) {
 		return newError(ctx, "second argument to `iter` must be FUNCTION, got %s", args[1].Type())
 	}
 
-	for i := 0; i < len(list.Elements); i++ {
-		var result object.Object
-		if function, ok := fn.(*object.Function); ok {
-			result = applyFunction(function, []object.Object{list.Elements[i]}, ctx)
-		} else if builtin, ok := fn.(*object.Builtin); ok {
-			result = builtin.Fn(ctx, []object.Object{list.Elements[i]}...)
-		}
+	for elem, ok := next(ctx); ok; elem, ok = next(ctx) {
+		result := applyFunction(fn, []object.Object{elem}, ctx)
 
 		if result.Type() == object.ERROR_OBJ {
 			return result
@@ -112,37 +120,32 @@ func reduce(ctx *object.EvalContext, args ...object.Object) object.Object {
 		return newError(ctx, "wrong number of arguments. got=%d, want=2 or 3", len(args))
 	}
 
-	list, ok := args[0].(*object.Array)
+	next, ok := elements(args[0])
 	if !ok {
 		return newError(ctx, "first argument to `reduce` must be ARRAY, got %s", args[0].Type())
 	}
 
 	var initial object.Object
-	var fn *object.Function
-	var startIndex int
+	var fn object.Object
 
 	if len(args) == 2 {
-		if len(list.Elements) == 0 {
+		if initial, ok = next(ctx); !ok {
 			return newError(ctx, "cannot reduce empty array without initial value")
 		}
-		initial = list.Elements[0]
-		fn, ok = args[1].(*object.Function)
-		if !ok {
+		fn = args[1]
+		if !isCallable(fn) {
 			return newError(ctx, "second argument to `reduce` must be FUNCTION, got %s", args[1].Type())
 		}
-		startIndex = 1
 	} else {
 		initial = args[1]
-		fn, ok = args[2].(*object.Function)
-		if !ok {
+		fn = args[2]
+		if !isCallable(fn) {
 			return newError(ctx, "third argument to `reduce` must be FUNCTION, got %s", args[2].Type())
 		}
-		startIndex = 0
 	}
 
 	accumulator := initial
-	for i := startIndex; i < len(list.Elements); i++ {
-		elem := list.Elements[i]
+	for elem, ok := next(ctx); ok; elem, ok = next(ctx) {
 		result := applyFunction(fn, []object.Object{accumulator, elem}, ctx)
 		if result.Type() == object.ERROR_OBJ {
 			return result
@@ -158,8 +161,8 @@ func loop(ctx *object.EvalContext, args ...object.Object) object.Object {
 		return newError(ctx, "wrong number of arguments. got=%d, want =1", len(args))
 	}
 
-	fnObj, ok := args[1].(*object.Function)
-	if !ok {
+	fnObj := args[1]
+	if !isCallable(fnObj) {
 		return newError(ctx, "second argument to loop must be a function, got %s", args[1].Type())
 	}
 
@@ -195,6 +198,15 @@ func loop(ctx *object.EvalContext, args ...object.Object) object.Object {
 	return accumulator
 }
 
+func isCallable(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Function, *object.Overloads, *object.Partial, *object.Builtin, object.Callable:
+		return true
+	default:
+		return false
+	}
+}
+
 var builtins = map[string]*object.Builtin{
 	"len": {
 		Fn: func(ctx *object.EvalContext, args ...object.Object) object.Object {
@@ -209,6 +221,12 @@ var builtins = map[string]*object.Builtin{
 				return &object.Integer{Value: int64(len(arg.Elements))}
 			case *object.Tuple:
 				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Range:
+				n, ok := arg.Len()
+				if !ok {
+					return newError(ctx, "the length of %s is too large for an integer", arg.Inspect())
+				}
+				return &object.Integer{Value: n}
 			default:
 				return newError(ctx, "argument to `len` not supported, got %s", args[0].Type())
 			}
@@ -219,6 +237,9 @@ var builtins = map[string]*object.Builtin{
 			if len(args) != 1 {
 				return newError(ctx, "wrong number of arguments. got=%d, want=1", len(args))
 			}
+			if r, ok := args[0].(*object.Range); ok {
+				return evalRangeIndexExpression(ctx, r, &object.Integer{Value: 0})
+			}
 			if args[0].Type() != object.ARRAY_OBJ {
 				return newError(ctx, "argument to `head` must be ARRAY, got %s", args[0].Type())
 			}
@@ -237,11 +258,15 @@ var builtins = map[string]*object.Builtin{
 			if len(args) != 1 {
 				return newError(ctx, "wrong number of arguments. got=%d, want=1", len(args))
 			}
-			if args[0].Type() != object.ARRAY_OBJ {
+			arg := rangeArray(ctx, args[0])
+			if isError(arg) {
+				return arg
+			}
+			if arg.Type() != object.ARRAY_OBJ {
 				return newError(ctx, "argument to `tail` must be ARRAY, got %s", args[0].Type())
 			}
 
-			arr := args[0].(*object.Array)
+			arr := arg.(*object.Array)
 			length := len(arr.Elements)
 			if length > 0 {
 				newElements := make([]object.Object, length-1)
@@ -257,6 +282,9 @@ var builtins = map[string]*object.Builtin{
 			if len(args) != 1 {
 				return newError(ctx, "wrong number of arguments. got=%d, want=1", len(args))
 			}
+			if r, ok := args[0].(*object.Range); ok {
+				return evalRangeIndexExpression(ctx, r, &object.Integer{Value: -1})
+			}
 			if args[0].Type() != object.ARRAY_OBJ {
 				return newError(ctx, "argument to `last` must be ARRAY, got %s", args[0].Type())
 			}
@@ -275,18 +303,15 @@ var builtins = map[string]*object.Builtin{
 			if len(args) != 2 {
 				return newError(ctx, "wrong number of arguments. got=%d, want=2", len(args))
 			}
-			if args[0].Type() != object.ARRAY_OBJ {
+			arg := rangeArray(ctx, args[0])
+			if isError(arg) {
+				return arg
+			}
+			if arg.Type() != object.ARRAY_OBJ {
 				return newError(ctx, "argument to `push` must be ARRAY, got %s", args[0].Type())
 			}
 
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-
-			newElements := make([]object.Object, length+1)
-			copy(newElements, arr.Elements)
-			newElements[length] = args[1]
-
-			return &object.Array{Elements: newElements}
+			return arg.(*object.Array).Append(args[1])
 		},
 	},
 	"fst": {
@@ -357,24 +382,4 @@ var builtins = map[string]*object.Builtin{
 			return &object.String{Value: string(args[0].Type())}
 		},
 	},
-	"test": {
-		Fn: func(ctx *object.EvalContext, args ...object.Object) object.Object {
-			if len(args) != 2 {
-				return newError(ctx, "wrong number of arguments. got=%d, want=2", len(args))
-			}
-
-			if args[0].Type() != object.BOOLEAN_OBJ {
-				return newError(ctx, "first argument to `test` must be BOOLEAN, got %s", args[0].Type())
-			}
-
-			if args[1].Type() != object.STRING_OBJ {
-				return newError(ctx, "second argument to `test` must be STRING, got %s", args[1].Type())
-			}
-
-			if args[0].(*object.Boolean) == constants.FALSE {
-				fmt.Printf("Test failed: %s\n", args[1].(*object.String).Value)
-			}
-			return constants.OK
-		},
-	},
 }
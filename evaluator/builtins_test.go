@@ -1,7 +1,11 @@
 package evaluator
 
 import (
+	"renelle/constants"
+	"renelle/hostlib"
+	"renelle/lexer"
 	"renelle/object"
+	"renelle/parser"
 	"testing"
 )
 
@@ -33,3 +37,161 @@ func TestBuiltinTypeFunction(t *testing.T) {
 		}
 	}
 }
+
+func TestAssertModule(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`Assert.equal(1 + 1, 2)`, ""},
+		{`Assert.equal([1 2 3], [1 2 4])`, "Assert.equal failed\n  expected: [1 2 4]\n  actual:   [1 2 3]\n  diff:\n    @2: expected 4, got 3"},
+		{`Assert.equal({a: 1}, {a: 2}, "maps differ")`, "Assert.equal failed: maps differ\n  expected: {:a = 2}\n  actual:   {:a = 1}\n  diff:\n    :a: expected 2, got 1"},
+		{`Assert.not_equal(1, 2)`, ""},
+		{`Assert.not_equal(:a, :a)`, "Assert.not_equal failed: values are equal\n  actual:   :a"},
+		{`Assert.like((:ok 5), (:ok :_))`, ""},
+		{`Assert.like({a: 1, b: 2}, {a: 1})`, ""},
+		{`Assert.like((:error "x"), (:ok :_))`, "Assert.like failed\n  expected: (:ok :_)\n  actual:   (:error \"x\")\n  diff:\n    @0: expected :ok, got :error"},
+		{`Assert.raises(\ => 1 + true)`, ""},
+		{`Assert.raises(\ => 1 + true, "type mismatch")`, ""},
+		{`Assert.raises(\ => 1)`, "Assert.raises failed: expected an error\n  actual:   1"},
+		{`Assert.approx(0.1 + 0.2, 0.3)`, ""},
+		{`Assert.approx(1.0, 1.5, 0.1)`, "Assert.approx failed\n  expected: 1.5\n  actual:   1\n  diff:\n    difference 0.5 is greater than 0.1"},
+		{`Assert.contains([1 2 3], 2)`, ""},
+		{`Assert.contains("hello", "ell")`, ""},
+		{`Assert.contains([1 2 3], 5)`, "Assert.contains failed: element not found\n  expected: 5\n  actual:   [1 2 3]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if tt.expected == "" {
+			if evaluated != constants.OK {
+				t.Errorf("%s: expected :ok. got=%T (%+v)", tt.input, evaluated, evaluated)
+			}
+			continue
+		}
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestHostModules(t *testing.T) {
+	hostlib.Register("TestHost",
+		hostlib.Function{Name: "twice", Arities: []int{1}, Fn: func(ctx *object.EvalContext, args ...object.Object) object.Object {
+			return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+		}},
+		hostlib.Function{Name: "count", Fn: func(ctx *object.EvalContext, args ...object.Object) object.Object {
+			return &object.Integer{Value: int64(len(args))}
+		}},
+	)
+	// natives are added to modules with source too
+	hostlib.Register("Math", hostlib.Function{Name: "cube", Arities: []int{1}, Fn: func(ctx *object.EvalContext, args ...object.Object) object.Object {
+		n := args[0].(*object.Integer).Value
+		return &object.Integer{Value: n * n * n}
+	}})
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"TestHost.twice(21)", 42},
+		{"TestHost.count(1 2 3)", 3},
+		{"TestHost.count()", 0},
+		{"Math.cube(3)", 27},
+		{"Math.abs(-2)", 2},
+		{"TestHost.twice(1 2)", "wrong number of arguments. got=2, want=1"},
+		{"Math.round(1 2 3)", "wrong number of arguments. got=3, want=1 or 2"},
+		{"Array.reduce([1])", "wrong number of arguments. got=1, want=2 or 3"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+
+	fn, ok := hostlib.Lookup("Math", "round")
+	if !ok || fn.Doc == "" {
+		t.Errorf("Math.round is not registered with a doc")
+	}
+}
+
+func TestPushKeepsValues(t *testing.T) {
+	input := `
+    let a = push([] 1)
+    let b = push(a 2)
+    let c = push(a 3)
+    let d = b ++ [4]
+    let e = b ++ [5]
+    [a b c d e]
+    `
+
+	evaluated := testEval(input)
+	if evaluated.Inspect() != "[[1] [1 2] [1 3] [1 2 4] ...]" {
+		t.Errorf("wrong arrays. got=%s", evaluated.Inspect())
+	}
+	last := evaluated.(*object.Array).Elements[4]
+	if last.Inspect() != "[1 2 5]" {
+		t.Errorf("wrong array. expected=[1 2 5], got=%s", last.Inspect())
+	}
+}
+
+// benchmarkArray runs input against an array of 100k integers bound to xs.
+func benchmarkArray(b *testing.B, input string) {
+	env := object.NewEnvironment()
+	ctx := object.NewEvalContext()
+	setup := parser.New(lexer.New("let xs = Array.range(100_000)", "bench")).ParseProgram()
+	if result := Eval(setup, env, ctx); isError(result) {
+		b.Fatal(result.Inspect())
+	}
+	program := parser.New(lexer.New(input, "bench")).ParseProgram()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if result := Eval(program, env, ctx); isError(result) {
+			b.Fatal(result.Inspect())
+		}
+	}
+}
+
+func BenchmarkArrayPush(b *testing.B) {
+	benchmarkArray(b, "Array.reduce(xs [] \\acc x => push(acc x))")
+}
+
+func BenchmarkArrayMap(b *testing.B) {
+	benchmarkArray(b, "Array.map(xs \\x => x * 2)")
+}
+
+func BenchmarkArrayFilter(b *testing.B) {
+	benchmarkArray(b, "Array.filter(xs \\x => x % 2 == 0)")
+}
+
+func BenchmarkArrayChoose(b *testing.B) {
+	benchmarkArray(b, "Array.choose(xs \\x => if x % 2 == 0 { (:some x) } else { :none })")
+}
+
+func BenchmarkArrayWithIndex(b *testing.B) {
+	benchmarkArray(b, "Array.with_index(xs)")
+}
+
+func BenchmarkArrayZip(b *testing.B) {
+	benchmarkArray(b, "Array.zip(xs xs)")
+}
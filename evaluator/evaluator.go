@@ -8,7 +8,9 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"unicode"
 
 	"renelle/ast"
@@ -18,12 +20,22 @@ import (
 	"renelle/object"
 	"renelle/parser"
 	"renelle/stdlib"
+	"renelle/token"
 )
 
-var atoms = map[string]*object.Atom{
-	"nil":   constants.NIL,
-	"ok":    constants.OK,
-	"error": constants.ERROR,
+// atoms holds the one Atom of each name, shared by every program that runs,
+// including those running at the same time, so it is guarded by atomsMu.
+var (
+	atoms = map[string]*object.Atom{
+		"nil":   constants.NIL,
+		"ok":    constants.OK,
+		"error": constants.ERROR,
+	}
+	atomsMu sync.RWMutex
+)
+
+func init() {
+	hostlib.ApplyFunction = applyFunction
 }
 
 func ApplyFunction(fn object.Object, args []object.Object, ctx *object.EvalContext) object.Object {
@@ -45,13 +57,17 @@ func Eval(node ast.Node, env *object.Environment, ctx *object.EvalContext) objec
 
 		mainFunc, ok := env.Get("main")
 		if ok {
+			// main is called from the last statement of the program.
+			last := node.Statements[len(node.Statements)-1].T()
+			ctx.FileName, ctx.Line, ctx.Column = last.FileName, last.Line, last.Column
 			return applyFunction(mainFunc, []object.Object{}, ctx)
 		}
 
 		return result
 
 	case *ast.FunctionStatement:
-		env.Set(node.Name.Value, &object.Function{Parameters: node.Parameters, Body: node.Body, Env: env})
+		fn := &object.Function{Parameters: node.Parameters, Body: node.Body, Clauses: node.Clauses, Defaults: node.Defaults, Rest: node.Rest, Env: env, Name: node.Name.Value}
+		env.SetFunction(node.Name.Value, fn.Arity(), fn)
 
 	case *ast.ExpressionStatement:
 		return Eval(node.Expression, env, ctx)
@@ -77,35 +93,47 @@ func Eval(node ast.Node, env *object.Environment, ctx *object.EvalContext) objec
 				if unicode.IsUpper(rune(left.Value[0])) {
 					return newError(ctx, "local variables can not start with an uppercase letter")
 				}
+				// name functions bound by let so they show up in stack traces
+				if fn, ok := val.(*object.Function); ok && fn.Name == "" {
+					if _, ok := node.Value.(*ast.FunctionLiteral); ok {
+						fn.Name = left.Value
+					}
+				}
 				env.Set(left.Value, val)
 			}
-		case *ast.TupleLiteral:
-			ctx.Line = node.Token.Line
-			ctx.Column = node.Token.Column
-			return handleTupleDestructuring(left, val, env, ctx)
-		case *ast.ArrayLiteral:
+		case *ast.TupleLiteral, *ast.ArrayLiteral, *ast.MapLiteral, *ast.StructLiteral, *ast.PinExpression:
 			ctx.Line = node.Token.Line
 			ctx.Column = node.Token.Column
-			return handleArrayDestructuring(left, val, env, ctx)
-		case *ast.MapLiteral:
-			ctx.Line = node.Token.Line
-			ctx.Column = node.Token.Column
-			return handleMapDestructuring(left, val, env, ctx)
+			return matchPattern(left, val, env, ctx)
 		default:
 			return newError(ctx, "invalid left-hand side of assignment")
 		}
 		return val
 	case *ast.Module:
 		moduleEnv := object.NewEnclosedEnvironment(env)
+		module := &object.Module{Name: node.Name.Value, Environment: moduleEnv}
+		// the body can refer to its own struct, but the rest of the program
+		// only sees the module once the body has run without error
+		moduleEnv.DefineModule(node.Name.Value, module)
 		for _, statement := range node.Body {
+			if structStmt, ok := statement.(*ast.StructStatement); ok {
+				module.Struct = evalStructStatement(structStmt, module.Name)
+				continue
+			}
 			ret := Eval(statement, moduleEnv, ctx)
 			if isError(ret) {
 				return ret
 			}
+			if fnStmt, ok := statement.(*ast.FunctionStatement); ok {
+				if fn, ok := moduleEnv.Function(fnStmt.Name.Value, fnStmt.Arity()); ok {
+					fn.(*object.Function).Module = module.Name
+				}
+			}
 		}
-		module := &object.Module{Name: node.Name.Value, Environment: moduleEnv}
-		env.SetModule(node.Name.Value, module)
-		return module
+		return env.SetModule(node.Name.Value, module)
+
+	case *ast.StructStatement:
+		return newError(ctx, "struct can only be declared inside a module")
 
 	// expressions
 	case *ast.IntegerLiteral:
@@ -141,11 +169,16 @@ func Eval(node ast.Node, env *object.Environment, ctx *object.EvalContext) objec
 		return evalMapLiteral(node, env, ctx)
 	case *ast.MapUpdateLiteral:
 		return evalMapUpdateLiteral(node, env, ctx)
+	case *ast.StructLiteral:
+		return evalStructLiteral(node, env, ctx)
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env, ctx)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
+		if node.Rest != nil {
+			return evalArrayRest(elements, node.Rest, env, ctx)
+		}
 		return &object.Array{Elements: elements}
 
 	case *ast.TupleLiteral:
@@ -163,6 +196,10 @@ func Eval(node ast.Node, env *object.Environment, ctx *object.EvalContext) objec
 
 	case *ast.Identifier:
 		return evalIdentifier(ctx, node, env)
+	case *ast.PinExpression:
+		return newError(ctx, "cannot use %s outside of a pattern", node)
+	case *ast.SpreadExpression:
+		return newError(ctx, "cannot use %s outside of the arguments of a call", node)
 	case *ast.PropertyAccessExpression:
 		left := Eval(node.Left, env, ctx)
 		if isError(left) {
@@ -177,8 +214,20 @@ func Eval(node ast.Node, env *object.Environment, ctx *object.EvalContext) objec
 		if isError(right) {
 			return right
 		}
+		// errors point at the operator, as in the vm
+		ctx.Line = node.Token.Line
+		ctx.Column = node.Token.Column
 		return evalPrefixExpression(ctx, node.Operator, right)
 
+	case *ast.TryExpression:
+		value := Eval(node.Value, env, ctx)
+		if isError(value) {
+			return value
+		}
+		ctx.Line = node.Token.Line
+		ctx.Column = node.Token.Column
+		return evalTry(ctx, value)
+
 	case *ast.InfixExpression:
 		if node.Operator == "::" {
 			return evalSliceExpression(node.Left, node.Right, env, ctx)
@@ -187,18 +236,17 @@ func Eval(node ast.Node, env *object.Environment, ctx *object.EvalContext) objec
 		if node.Operator == "|>" {
 			switch right := node.Right.(type) {
 			case *ast.CallExpression:
-				right.Arguments = append([]ast.Expression{node.Left}, right.Arguments...)
-				return Eval(right, env, ctx)
+				return Eval(right.Piped(node.Left), env, ctx)
 			case *ast.PropertyAccessExpression:
 				// Assuming that the property access expression has a CallExpression as its property
 				if callExpr, ok := right.Right.(*ast.CallExpression); ok {
-					callExpr.Arguments = append([]ast.Expression{node.Left}, callExpr.Arguments...)
-					return Eval(right, env, ctx)
+					piped := &ast.PropertyAccessExpression{Token: right.Token, Left: right.Left, Right: callExpr.Piped(node.Left)}
+					return Eval(piped, env, ctx)
 				} else {
 					return newError(ctx, "pipe operator must be followed by a function call")
 				}
 			case *ast.FunctionLiteral:
-				if len(right.Parameters) != 1 {
+				if len(right.Parameters) > 1 || len(right.Parameters) == 0 && right.Rest == nil {
 					return newError(ctx, "function literal must take exactly one argument")
 				}
 				newCall := &ast.CallExpression{
@@ -233,51 +281,14 @@ func Eval(node ast.Node, env *object.Environment, ctx *object.EvalContext) objec
 		return evalInfixExpression(ctx, node.Operator, left, right)
 
 	case *ast.CaseExpression:
-		testVal := Eval(node.Test, env, ctx)
-		if isError(testVal) {
-			return testVal
-		}
-		for i, condition := range node.Conditions {
-			switch condition := condition.(type) {
-			case *ast.Identifier:
-				if condition.Value == "_" {
-					newEnv := object.NewEnclosedEnvironment(env)
-					return Eval(node.Consequences[i], newEnv, ctx)
-				} else {
-					newEnv := object.NewEnclosedEnvironment(env)
-					newEnv.Set(condition.Value, testVal)
-					return Eval(node.Consequences[i], newEnv, ctx)
-				}
-			case *ast.TupleLiteral:
-				ctx.Line = node.Token.Line
-				ctx.Column = node.Token.Column
-				newEnv := object.NewEnclosedEnvironment(env)
-				err := handleTupleDestructuring(condition, testVal, newEnv, ctx)
-				if isError(err) {
-					continue
-				}
-				return Eval(node.Consequences[i], newEnv, ctx)
-			case *ast.ArrayLiteral:
-				ctx.Line = node.Token.Line
-				ctx.Column = node.Token.Column
-				newEnv := object.NewEnclosedEnvironment(env)
-				err := handleArrayDestructuring(condition, testVal, newEnv, ctx)
-				if isError(err) {
-					continue
-				}
-				return Eval(node.Consequences[i], newEnv, ctx)
-			default:
-				conditionVal := Eval(condition, env, ctx)
-				if isError(conditionVal) {
-					return conditionVal
-				}
-				if object.Equals(conditionVal, testVal) {
-					newEnv := object.NewEnclosedEnvironment(env)
-					return Eval(node.Consequences[i], newEnv, ctx)
-				}
-			}
+		branch, branchEnv, err := matchCase(node, env, ctx)
+		if err != nil {
+			return err
 		}
-		return newError(ctx, "no matching case")
+		return Eval(branch, branchEnv, ctx)
+	case *ast.WithExpression:
+		return evalWith(node, env, ctx, Eval)
+
 	case *ast.IfExpression:
 		return evalIfExpression(node, env, ctx)
 
@@ -287,7 +298,7 @@ func Eval(node ast.Node, env *object.Environment, ctx *object.EvalContext) objec
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Body: body, Env: env}
+		return &object.Function{Parameters: params, Rest: node.Rest, Body: body, Env: env}
 
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env, ctx)
@@ -311,17 +322,86 @@ func Eval(node ast.Node, env *object.Environment, ctx *object.EvalContext) objec
 			return function
 		}
 
-		args := evalExpressions(node.Arguments, env, ctx)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
+		if node.Placeholders() > 0 {
+			return evalPartial(function, node, env, ctx)
 		}
 
+		function, args, err := evalArguments(function, node, env, ctx)
+		if err != nil {
+			return err
+		}
+
+		ctx.Line = node.Token.Line
+		ctx.Column = node.Token.Column
 		return applyFunction(function, args, ctx)
 	}
 
 	return nil
 }
 
+// matchCase finds the first branch of a case expression whose pattern matches,
+// returning it with the environment holding the pattern's bindings.
+func matchCase(node *ast.CaseExpression, env *object.Environment, ctx *object.EvalContext) (*ast.BlockStatement, *object.Environment, object.Object) {
+	testVal := Eval(node.Test, env, ctx)
+	if isError(testVal) {
+		return nil, nil, testVal
+	}
+	return matchClauses(node.Token, node.Conditions, node.Guards, node.Consequences, testVal, env, ctx)
+}
+
+// matchClauses finds the first of the clauses whose pattern matches testVal,
+// as in a case expression.
+func matchClauses(tok token.Token, conditions, guards []ast.Expression, consequences []*ast.BlockStatement, testVal object.Object, env *object.Environment, ctx *object.EvalContext) (*ast.BlockStatement, *object.Environment, object.Object) {
+	for i, condition := range conditions {
+		newEnv, err := matchClause(tok, condition, testVal, env, ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		if newEnv == nil {
+			continue
+		}
+
+		// a clause whose guard is falsy falls through to the next one
+		if guard := guards[i]; guard != nil {
+			guardVal := Eval(guard, newEnv, ctx)
+			if isError(guardVal) {
+				return nil, nil, guardVal
+			}
+			if !isTruthy(guardVal) {
+				continue
+			}
+		}
+		return consequences[i], newEnv, nil
+	}
+	return nil, nil, newError(ctx, "no matching case for %s", testVal.Inspect())
+}
+
+// matchClause matches the tested value against one clause's pattern,
+// returning the environment holding its bindings, or nil if it does not
+// match.
+func matchClause(tok token.Token, condition ast.Expression, testVal object.Object, env *object.Environment, ctx *object.EvalContext) (*object.Environment, object.Object) {
+	newEnv := object.NewEnclosedEnvironment(env)
+
+	if destructures(condition) {
+		ctx.Line = tok.Line
+		ctx.Column = tok.Column
+		matched, err := tryMatch(condition, testVal, newEnv, ctx)
+		if !matched {
+			return nil, err
+		}
+		return newEnv, nil
+	}
+
+	conditionVal := patternValue(condition, env, ctx)
+	if isError(conditionVal) {
+		return nil, conditionVal
+	}
+	if object.Equals(conditionVal, testVal) {
+		return newEnv, nil
+	}
+	return nil, nil
+}
+
 func evalExpressions(exps []ast.Expression, env *object.Environment, ctx *object.EvalContext) []object.Object {
 	var result []object.Object
 
@@ -336,135 +416,64 @@ func evalExpressions(exps []ast.Expression, env *object.Environment, ctx *object
 	return result
 }
 
-func handleTupleDestructuring(tuple *ast.TupleLiteral, val object.Object, env *object.Environment, ctx *object.EvalContext) object.Object {
-	tupleObject, ok := val.(*object.Tuple)
-	if !ok {
-		return newError(ctx, "right-hand side of assignment is not a tuple")
-	}
-	if len(tuple.Elements) != len(tupleObject.Elements) {
-		return newError(ctx, "cannot destructure tuple: size mismatch")
+func evalStructStatement(node *ast.StructStatement, moduleName string) *object.StructDefinition {
+	fields := make([]string, len(node.Fields))
+	for i, field := range node.Fields {
+		fields[i] = field.Value
 	}
-	for i, el := range tuple.Elements {
-		switch el := el.(type) {
-		case *ast.Identifier:
-			if el.Value == "_" {
-				continue
-			} else if unicode.IsUpper(rune(el.Value[0])) {
-				return newError(ctx, "local variables can not start with an uppercase letter")
-			}
-			env.Set(el.Value, tupleObject.Elements[i])
-		case *ast.TupleLiteral:
-			return handleTupleDestructuring(el, tupleObject.Elements[i], env, ctx)
-		case *ast.ArrayLiteral:
-			return handleArrayDestructuring(el, tupleObject.Elements[i], env, ctx)
-		default:
-			leftVal := Eval(el, env, ctx)
-			if isError(leftVal) {
-				return leftVal
-			}
-			if !object.Equals(leftVal, tupleObject.Elements[i]) {
-				return newError(ctx, "cannot destructure tuple: value mismatch")
-			}
-		}
-	}
-	return constants.OK
+	return &object.StructDefinition{Module: moduleName, Fields: fields}
 }
 
-func handleArrayDestructuring(array *ast.ArrayLiteral, val object.Object, env *object.Environment, ctx *object.EvalContext) object.Object {
-	arrayObject, ok := val.(*object.Array)
-	if !ok {
-		return newError(ctx, "right-hand side of assignment is not an array")
+func evalStructLiteral(node *ast.StructLiteral, env *object.Environment, ctx *object.EvalContext) object.Object {
+	module := Eval(node.Name, env, ctx)
+	if isError(module) {
+		return module
 	}
-	if len(array.Elements) != len(arrayObject.Elements) {
-		return newError(ctx, "cannot destructure array: size mismatch")
+
+	fields := make([]string, len(node.Fields))
+	for i, field := range node.Fields {
+		fields[i] = field.Value
 	}
-	for i, el := range array.Elements {
-		switch el := el.(type) {
-		case *ast.Identifier:
-			if el.Value == "_" {
-				continue
-			} else if unicode.IsUpper(rune(el.Value[0])) {
-				return newError(ctx, "local variables can not start with an uppercase letter")
-			}
-			env.Set(el.Value, arrayObject.Elements[i])
-		case *ast.TupleLiteral:
-			return handleTupleDestructuring(el, arrayObject.Elements[i], env, ctx)
-		case *ast.ArrayLiteral:
-			return handleArrayDestructuring(el, arrayObject.Elements[i], env, ctx)
-		default:
-			leftVal := Eval(el, env, ctx)
-			if isError(leftVal) {
-				return leftVal
-			}
-			if !object.Equals(leftVal, arrayObject.Elements[i]) {
-				return newError(ctx, "cannot destructure array: value mismatch")
-			}
-		}
+
+	values := evalExpressions(node.Values, env, ctx)
+	if len(values) == 1 && isError(values[0]) {
+		return values[0]
 	}
-	return constants.OK
+
+	ctx.Line = node.Token.Line
+	ctx.Column = node.Token.Column
+	return newStruct(ctx, module, node.Name.Value, fields, values)
 }
 
-func handleMapDestructuring(left *ast.MapLiteral, val object.Object, env *object.Environment, ctx *object.EvalContext) object.Object {
-	mapObj, ok := val.(*object.Map)
-	if !ok {
-		return newError(ctx, "expected map, got %s", val.Type())
+// newStruct builds a struct of the given module, checking that exactly the
+// declared fields are set.
+func newStruct(ctx *object.EvalContext, moduleObj object.Object, name string, fields []string, values []object.Object) object.Object {
+	module, ok := moduleObj.(*object.Module)
+	if !ok || module.Struct == nil {
+		return newError(ctx, "%s does not define a struct", name)
 	}
 
-	for keyExpr, valueExpr := range left.Pairs {
-		// Evaluate the key expression
-		keyVal := Eval(keyExpr, env, ctx)
-		if isError(keyVal) {
-			return keyVal
-		}
-
-		// Get the value from the map
-		value, ok := mapObj.Get(keyVal)
-		if !ok {
-			return newError(ctx, "key not found: %s", keyVal.Inspect())
+	fieldValues := make(map[string]object.Object, len(fields))
+	for i, field := range fields {
+		if !module.Struct.HasField(field) {
+			return newError(ctx, "unknown field %s for struct %s", field, module.Name)
 		}
+		fieldValues[field] = values[i]
+	}
 
-		// Handle the value expression based on its type
-		switch valueExpr := valueExpr.(type) {
-		case *ast.Identifier:
-			// Set the value in the environment under the name given by the value expression
-			if unicode.IsUpper(rune(valueExpr.Value[0])) {
-				return newError(ctx, "local variables can not start with an uppercase letter")
-			}
-			if valueExpr.Value != "_" {
-				env.Set(valueExpr.Value, value)
-			}
-		case *ast.MapLiteral:
-			// Recursively handle nested map destructuring
-			return handleMapDestructuring(valueExpr, value, env, ctx)
-		case *ast.ArrayLiteral:
-			// Handle array destructuring
-			return handleArrayDestructuring(valueExpr, value, env, ctx)
-		case *ast.TupleLiteral:
-			// Handle tuple destructuring
-			return handleTupleDestructuring(valueExpr, value, env, ctx)
-		default:
-			// Evaluate the value expression
-			valueVal := Eval(valueExpr, env, ctx)
-			if isError(valueVal) {
-				return valueVal
-			}
-
-			// Check if the value matches the value expression
-			if !object.Equals(valueVal, value) {
-				return newError(ctx, "cannot destructure map: value mismatch")
-			}
+	for _, field := range module.Struct.Fields {
+		if _, ok := fieldValues[field]; !ok {
+			return newError(ctx, "missing field %s for struct %s", field, module.Name)
 		}
 	}
 
-	return val
+	return &object.Struct{Module: module.Name, Fields: module.Struct.Fields, Values: fieldValues}
 }
 
 func evalMapLiteral(node *ast.MapLiteral, env *object.Environment, ctx *object.EvalContext) object.Object {
-	hashTableSize := int(float64(len(node.Pairs)) / 0.7)
-	hashTable := object.NewHashTable(hashTableSize)
-	mapObject := &object.Map{Store: hashTable}
+	mapObject := &object.Map{}
 
-	for keyNode, valueNode := range node.Pairs {
+	for _, keyNode := range node.Keys {
 		key := Eval(keyNode, env, ctx)
 		if isError(key) {
 			return key
@@ -475,7 +484,7 @@ func evalMapLiteral(node *ast.MapLiteral, env *object.Environment, ctx *object.E
 			return newError(ctx, "unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env, ctx)
+		value := Eval(node.Pairs[keyNode], env, ctx)
 		if isError(value) {
 			return value
 		}
@@ -487,69 +496,75 @@ func evalMapLiteral(node *ast.MapLiteral, env *object.Environment, ctx *object.E
 }
 
 func evalPropertyAccessExpression(left object.Object, right ast.Expression, env *object.Environment, ctx *object.EvalContext) object.Object {
-	switch left := left.(type) {
-	case *object.Module:
-		switch right := right.(type) {
-		case *ast.Identifier:
-			// Get the function from the module
-			funcObj, ok := left.Environment.Get(right.Value)
-			if !ok {
-				return newError(ctx, "property %s not found", right.Value)
-			}
+	if ident, ok := right.(*ast.Identifier); ok {
+		return getProperty(ctx, left, ident.Value)
+	}
 
-			// Check if the object is a function or a built-in
-			switch funcObj := funcObj.(type) {
-			case *object.Function, *object.Builtin:
-				return funcObj
-			default:
-				return newError(ctx, "property %s is not a function", right.Value)
-			}
-		case *ast.CallExpression:
-			// Ensure the function expression is an Identifier
-			ident, ok := right.Function.(*ast.Identifier)
-			if !ok {
-				return newError(ctx, "invalid function call: %s", right.Function.String())
-			}
+	module, ok := left.(*object.Module)
+	if !ok {
+		switch left.(type) {
+		case *object.Map, *object.Struct:
+			return newError(ctx, "invalid property access: %s", right.String())
+		default:
+			return newError(ctx, "property access not supported: %s", left.Type())
+		}
+	}
 
-			// Get the function from the module
-			funcObj, ok := left.Environment.Get(ident.Value)
-			if !ok {
-				return newError(ctx, "function %s not found", ident.Value)
-			}
+	call, ok := right.(*ast.CallExpression)
+	if !ok {
+		return newError(ctx, "invalid property access: %s", right.String())
+	}
 
-			switch funcObj := funcObj.(type) {
-			case *object.Function:
-				// Evaluate the arguments
-				args := evalExpressions(right.Arguments, env, ctx)
+	// Ensure the function expression is an Identifier
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return newError(ctx, "invalid function call: %s", call.Function.String())
+	}
 
-				// Apply the function to the arguments
-				return applyFunction(funcObj, args, ctx)
-			case *object.Builtin:
-				// Evaluate the arguments
-				args := evalExpressions(right.Arguments, env, ctx)
+	funcObj := moduleFunction(ctx, module, ident.Value)
+	if isError(funcObj) {
+		return funcObj
+	}
 
-				// Apply the built-in function to the arguments
-				return funcObj.Fn(ctx, args...)
-			default:
-				return newError(ctx, "property %s is not a function", ident.Value)
-			}
+	if call.Placeholders() > 0 {
+		return evalPartial(funcObj, call, env, ctx)
+	}
+
+	funcObj, args, err := evalArguments(funcObj, call, env, ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx.Line = call.Token.Line
+	ctx.Column = call.Token.Column
+	return applyFunction(funcObj, args, ctx)
+}
+
+// getProperty reads name from a module, map or struct, as in `left.name`.
+func getProperty(ctx *object.EvalContext, left object.Object, name string) object.Object {
+	switch left := left.(type) {
+	case *object.Module:
+		funcObj, ok := left.Environment.Get(name)
+		if !ok {
+			return newError(ctx, "property %s not found", name)
+		}
 
+		switch funcObj := funcObj.(type) {
+		case *object.Function, *object.Overloads, *object.Partial, *object.Builtin, object.Callable:
+			return funcObj
 		default:
-			return newError(ctx, "invalid property access: %s", right.String())
+			return newError(ctx, "property %s is not a function", name)
 		}
 	case *object.Map:
-		// Ensure the right expression is an Identifier
-		ident, ok := right.(*ast.Identifier)
+		value, ok := left.Get(getOrCreateAtom(name))
 		if !ok {
-			return newError(ctx, "invalid property access: %s", right.String())
+			return constants.NIL
 		}
-
-		// Get or create the atom for the identifier
-		key := getOrCreateAtom(ident.Value)
-
-		value, ok := left.Get(key)
+		return value
+	case *object.Struct:
+		value, ok := left.Get(name)
 		if !ok {
-			return constants.NIL
+			return newError(ctx, "unknown field %s for struct %s", name, left.Module)
 		}
 		return value
 	default:
@@ -557,59 +572,234 @@ func evalPropertyAccessExpression(left object.Object, right ast.Expression, env
 	}
 }
 
+// moduleFunction looks up a function to be called as `Module.name(...)`.
+func moduleFunction(ctx *object.EvalContext, module *object.Module, name string) object.Object {
+	funcObj, ok := module.Environment.Get(name)
+	if !ok {
+		return newError(ctx, "function %s not found", name)
+	}
+
+	switch funcObj.(type) {
+	case *object.Function, *object.Overloads, *object.Partial, *object.Builtin, object.Callable:
+		return funcObj
+	default:
+		return newError(ctx, "property %s is not a function", name)
+	}
+}
+
 func evalMapUpdateLiteral(node *ast.MapUpdateLiteral, env *object.Environment, ctx *object.EvalContext) object.Object {
 	mapObj := Eval(node.Left, env, ctx)
 	if isError(mapObj) {
 		return mapObj
 	}
 
+	if structObj, ok := mapObj.(*object.Struct); ok {
+		return evalStructUpdate(node, structObj, env, ctx)
+	}
+
 	mapObjTyped, ok := mapObj.(*object.Map)
 	if !ok {
 		return newError(ctx, "not a map: %s", mapObj.Type())
 	}
 
-	mapCopy := mapObjTyped.Copy(len(node.Right))
-	for keyNode, valueNode := range node.Right {
+	result := mapObjTyped
+	for _, keyNode := range node.Keys {
 		key := Eval(keyNode, env, ctx)
 		if isError(key) {
 			return key
 		}
 
+		value := Eval(node.Right[keyNode], env, ctx)
+		if isError(value) {
+			return value
+		}
+
+		result = result.With(key, value)
+	}
+
+	return result
+}
+
+func evalStructUpdate(node *ast.MapUpdateLiteral, structObj *object.Struct, env *object.Environment, ctx *object.EvalContext) object.Object {
+	fields := []string{}
+	values := []object.Object{}
+	for _, keyNode := range node.Keys {
+		valueNode := node.Right[keyNode]
+		switch key := keyNode.(type) {
+		case *ast.Identifier:
+			fields = append(fields, key.Value)
+		case *ast.AtomLiteral:
+			fields = append(fields, key.Value)
+		default:
+			return newError(ctx, "invalid struct field: %s", keyNode.String())
+		}
+
 		value := Eval(valueNode, env, ctx)
 		if isError(value) {
 			return value
 		}
+		values = append(values, value)
+	}
 
-		mapCopy.Put(key, value)
+	return updateStruct(ctx, structObj, fields, values)
+}
+
+// updateStruct returns a copy of the struct with the given fields replaced.
+func updateStruct(ctx *object.EvalContext, structObj *object.Struct, fields []string, values []object.Object) object.Object {
+	updates := make(map[string]object.Object, len(fields))
+	for i, field := range fields {
+		if _, ok := structObj.Get(field); !ok {
+			return newError(ctx, "unknown field %s for struct %s", field, structObj.Module)
+		}
+		updates[field] = values[i]
 	}
 
-	return mapCopy
+	return structObj.With(updates)
 }
 
 func applyFunction(fn object.Object, args []object.Object, ctx *object.EvalContext) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		if len(args) != len(fn.Parameters) {
-			return newError(ctx, "wrong number of arguments. got=%d, want=%d", len(args), len(fn.Parameters))
+		if err := checkArity(fn, args, ctx); err != nil {
+			return err
+		}
+
+		ctx.PushFrame(fn.QualifiedName())
+		result := callFunction(fn, args, ctx)
+		if err, ok := result.(*object.Error); ok && err.Stack == nil {
+			err.Stack = ctx.StackTrace()
 		}
-		extendedEnv := extendFunctionEnv(fn, args, ctx)
-		evaluated := Eval(fn.Body, extendedEnv, ctx)
-		return unwrapReturnValue(evaluated)
+		ctx.PopFrame()
+		return result
+	case *object.Overloads:
+		resolved, ok := fn.Lookup(len(args))
+		if !ok {
+			return newError(ctx, "wrong number of arguments. got=%d, want=%s", len(args), fn.ArityString())
+		}
+		return applyFunction(resolved, args, ctx)
+	case *object.Partial:
+		return applyPartial(fn, args, ctx)
 	case *object.Builtin:
 		return fn.Fn(ctx, args...)
+	case object.Callable:
+		return fn.Call(ctx, args)
 	default:
 		return newError(ctx, "not a function: %s", fn.Type())
 	}
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object, ctx *object.EvalContext) *object.Environment {
+// callFunction runs the body of a function whose call has been pushed on the
+// stack. Calls in tail position come back as a TailCall, and are run here in
+// place of the current call instead of nesting another applyFunction.
+func callFunction(fn *object.Function, args []object.Object, ctx *object.EvalContext) object.Object {
+	for {
+		body, extendedEnv := fn.Body, (*object.Environment)(nil)
+		if fn.Clauses != nil {
+			var err object.Object
+			body, extendedEnv, err = matchFunctionClause(fn, args, ctx)
+			if err != nil {
+				return err
+			}
+		} else {
+			var err object.Object
+			extendedEnv, err = extendFunctionEnv(fn, args, ctx)
+			if err != nil {
+				return err
+			}
+		}
+		evaluated := unwrapReturnValue(evalTail(body, extendedEnv, ctx))
+
+		tc, ok := evaluated.(*object.TailCall)
+		if !ok {
+			return evaluated
+		}
+
+		fn, args = tc.Fn, tc.Args
+		if err := checkArity(fn, args, ctx); err != nil {
+			return err
+		}
+		ctx.ReplaceFrame(fn.QualifiedName())
+	}
+}
+
+// checkArity returns an error if fn can not be called with args.
+func checkArity(fn *object.Function, args []object.Object, ctx *object.EvalContext) *object.Error {
+	if min, max := object.ArityRange(fn); len(args) < min || len(args) > max {
+		return newError(ctx, "wrong number of arguments. got=%d, want=%s", len(args), object.ArityString(min, max))
+	}
+	return nil
+}
+
+// extendFunctionEnv binds the parameters of fn to the arguments. Parameters
+// left out are bound to their default values, which can refer to the
+// parameters before them, and a rest parameter to an array of the arguments
+// after them.
+func extendFunctionEnv(fn *object.Function, args []object.Object, ctx *object.EvalContext) (*object.Environment, object.Object) {
 	env := object.NewEnclosedEnvironment(fn.Env)
 
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) && args[paramIdx] != nil {
+			env.Set(param.Value, args[paramIdx])
+			continue
+		}
+
+		value := Eval(fn.Defaults[paramIdx], env, ctx)
+		if isError(value) {
+			return nil, value
+		}
+		env.Set(param.Value, value)
+	}
+
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
+	}
+
+	return env, nil
+}
+
+// matchFunctionClause returns the body of the first clause of fn whose
+// patterns match the arguments, with an environment binding their names.
+func matchFunctionClause(fn *object.Function, args []object.Object, ctx *object.EvalContext) (*ast.BlockStatement, *object.Environment, object.Object) {
+	for _, clause := range fn.Clauses {
+		env := object.NewEnclosedEnvironment(fn.Env)
+		matched, err := matchArguments(clause.Patterns, args, env, ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		if matched {
+			return clause.Body, env, nil
+		}
+	}
+
+	ctx.Line = fn.Clauses[0].Token.Line
+	ctx.Column = fn.Clauses[0].Token.Column
+	return nil, nil, newError(ctx, NoMatchingClause(fn.Name, fn.Clauses), (&object.Tuple{Elements: args}).Inspect())
+}
+
+func matchArguments(patterns []ast.Expression, args []object.Object, env *object.Environment, ctx *object.EvalContext) (bool, object.Object) {
+	for i, pattern := range patterns {
+		if matched, err := tryMatch(pattern, args[i], env, ctx); !matched {
+			return false, err
+		}
 	}
+	return true, nil
+}
 
-	return env
+// NoMatchingClause is the format of the error raised when no clause of a
+// function matches its arguments, which fill in the %s as a tuple.
+func NoMatchingClause(name string, clauses []*ast.FunctionClause) string {
+	lines := make([]string, len(clauses))
+	for i, c := range clauses {
+		lines[i] = strconv.Itoa(c.Token.Line)
+	}
+	if len(lines) == 1 {
+		return fmt.Sprintf("no matching clause for %s%%s, tried the clause on line %s", name, lines[0])
+	}
+	return fmt.Sprintf("no matching clause for %s%%s, tried the clauses on lines %s", name, strings.Join(lines, ", "))
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -683,10 +873,14 @@ func evalSliceExpression(left, right ast.Expression, env *object.Environment, ct
 
 func evalIndexExpression(ctx *object.EvalContext, left, index object.Object) object.Object {
 	switch {
+	case left.Type() == object.RANGE_OBJ:
+		return evalRangeIndexExpression(ctx, left.(*object.Range), index)
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(ctx, left, index)
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.SLICE_OBJ:
 		return evalArraySliceExpression(ctx, left, index)
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.RANGE_OBJ:
+		return evalArrayRangeExpression(left.(*object.Array), index.(*object.Range))
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.ARRAY_OBJ:
 		return evalArrayMaskExpression(ctx, left, index)
 	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
@@ -696,7 +890,6 @@ func evalIndexExpression(ctx *object.EvalContext, left, index object.Object) obj
 	case left.Type() == object.MAP_OBJ:
 		return evalMapIndexExpression(ctx, left, index)
 	default:
-		fmt.Print(index.Type())
 		return newError(ctx, "index operator not supported: %s", left.Type())
 	}
 }
@@ -806,9 +999,8 @@ func evalArrayMaskExpression(ctx *object.EvalContext, array, mask object.Object)
 			elements = append(elements, newRow)
 		}
 		return &object.Array{Elements: elements}
-	case *object.Slice:
-		slice := maskObject.Elements[0].(*object.Slice)
-		rows := evalIndexExpression(ctx, arrayObject, slice)
+	case *object.Slice, *object.Range:
+		rows := evalIndexExpression(ctx, arrayObject, maskObject.Elements[0])
 
 		if len(maskObject.Elements) == 1 {
 			return rows
@@ -888,6 +1080,9 @@ func evalIdentifier(ctx *object.EvalContext, node *ast.Identifier, env *object.E
 		if node.Value == "loop" {
 			return &object.Builtin{Fn: loop}
 		}
+		if val, ok := tryName(node.Value, env); ok {
+			return evalTry(ctx, val)
+		}
 	}
 	return newError(ctx, "identifier not found: "+node.Value)
 }
@@ -910,6 +1105,14 @@ func evalInfixExpression(ctx *object.EvalContext, operator string, left, right o
 		return nativeBoolToBooleanObject(isTruthy(left) && isTruthy(right))
 	case operator == "or":
 		return nativeBoolToBooleanObject(isTruthy(left) || isTruthy(right))
+	case operator == "..":
+		return evalRangeExpression(ctx, left, right)
+	case operator == "//":
+		return evalStepExpression(ctx, left, right)
+	case operator == "in":
+		return evalInExpression(ctx, left, right)
+	case left.Type() == object.RANGE_OBJ || right.Type() == object.RANGE_OBJ:
+		return evalRangeInfixExpression(ctx, operator, left, right)
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(ctx, operator, left, right)
 	case left.Type() == object.FLOAT_OBJ && right.Type() == object.FLOAT_OBJ:
@@ -925,6 +1128,9 @@ func evalInfixExpression(ctx *object.EvalContext, operator string, left, right o
 	case left.Type() == object.ARRAY_OBJ && right.Type() == object.INTEGER_OBJ,
 		left.Type() == object.ARRAY_OBJ && right.Type() == object.FLOAT_OBJ:
 		return evalArrayMathExpression(ctx, operator, left, right)
+	case left.Type() == object.STRUCT_OBJ && right.Type() == object.STRUCT_OBJ,
+		left.Type() == object.MAP_OBJ && right.Type() == object.MAP_OBJ:
+		return evalEqualityInfixExpression(ctx, operator, left, right)
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
@@ -936,6 +1142,18 @@ func evalInfixExpression(ctx *object.EvalContext, operator string, left, right o
 	}
 }
 
+// evalEqualityInfixExpression compares structs or maps by their contents.
+func evalEqualityInfixExpression(ctx *object.EvalContext, operator string, left, right object.Object) object.Object {
+	switch operator {
+	case "==":
+		return nativeBoolToBooleanObject(object.Equals(left, right))
+	case "!=":
+		return nativeBoolToBooleanObject(!object.Equals(left, right))
+	default:
+		return newError(ctx, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
 func evalIntegerInfixExpression(ctx *object.EvalContext, operator string, left, right object.Object) object.Object {
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
@@ -1045,7 +1263,7 @@ func evalArrayInfixExpression(ctx *object.EvalContext, operator string, left, ri
 
 		return &object.Array{Elements: elements}
 	case "++":
-		return &object.Array{Elements: append(leftVal.Elements, rightVal.Elements...)}
+		return leftVal.Append(rightVal.Elements...)
 	case "===":
 		if len(leftVal.Elements) != len(rightVal.Elements) {
 			return constants.FALSE
@@ -1150,10 +1368,19 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 }
 
 func getOrCreateAtom(value string) *object.Atom {
+	atomsMu.RLock()
+	atom, ok := atoms[value]
+	atomsMu.RUnlock()
+	if ok {
+		return atom
+	}
+
+	atomsMu.Lock()
+	defer atomsMu.Unlock()
 	if atom, ok := atoms[value]; ok {
 		return atom
 	}
-	atom := &object.Atom{Value: value}
+	atom = &object.Atom{Value: value}
 	atoms[value] = atom
 	return atom
 }
@@ -1178,9 +1405,12 @@ func newError(ctx *object.EvalContext, format string, a ...interface{}) *object.
 	}
 }
 
+// isError reports whether evaluation stops at obj and hands it up as it is:
+// an error, or a value returned by `?` from the middle of an expression.
 func isError(obj object.Object) bool {
 	if obj != nil {
-		return obj.Type() == object.ERROR_OBJ
+		rt := obj.Type()
+		return rt == object.ERROR_OBJ || rt == object.RETURN_VALUE_OBJ
 	}
 	return false
 }
@@ -1207,6 +1437,13 @@ func loadModule(ctx *object.EvalContext, moduleName string, env *object.Environm
 		return loadModuleFromFile(ctx, depsModulePath, env, moduleName)
 	}
 
+	// A module registered by the host need not have any source
+	if _, ok := hostlib.Module(moduleName); ok {
+		moduleEnv := object.NewEnvironment()
+		hostlib.Install(moduleName, moduleEnv)
+		return env.SetModule(moduleName, &object.Module{Name: moduleName, Environment: moduleEnv})
+	}
+
 	return newError(ctx, "module not found: %s", moduleName)
 }
 
@@ -1270,12 +1507,13 @@ func loadModuleFromFile(ctx *object.EvalContext, modulePath string, env *object.
 	p := parser.New(l)
 	program := p.ParseProgram()
 	if len(p.Errors()) != 0 {
-		return newError(ctx, "parser errors: %v", p.Errors())
+		return newError(ctx, "parser errors in module %s:\n%s", moduleName, parser.RenderErrors(p.Errors()))
 	}
 
 	newctx := object.NewEvalContext()
 	Eval(program, env.Root(), newctx)
 	if module, ok := env.GetModule(moduleName); ok {
+		hostlib.Install(moduleName, module.Environment)
 		return module
 	}
 
@@ -1292,63 +1530,13 @@ func loadModuleFromEmbedFS(ctx *object.EvalContext, fs embed.FS, modulePath stri
 	p := parser.New(l)
 	program := p.ParseProgram()
 	if len(p.Errors()) != 0 {
-		return newError(ctx, "parser errors: %v", p.Errors())
+		return newError(ctx, "parser errors in module %s:\n%s", moduleName, parser.RenderErrors(p.Errors()))
 	}
 
 	newctx := object.NewEvalContext()
 	Eval(program, env.Root(), newctx)
 	if module, ok := env.GetModule(moduleName); ok {
-		switch moduleName {
-		case "Array":
-			module.Environment.Set("iter", &object.Builtin{Fn: iter})
-			module.Environment.Set("range", &object.Builtin{Fn: hostlib.ArrayRange})
-			module.Environment.Set("reverse", &object.Builtin{Fn: hostlib.ArrayReverse})
-			module.Environment.Set("reduce", &object.Builtin{Fn: reduce})
-			module.Environment.Set("reduce_while", &object.Builtin{Fn: reduceWhile})
-		case "File":
-			module.Environment.Set("open", &object.Builtin{Fn: hostlib.FileOpen})
-			module.Environment.Set("open!", &object.Builtin{Fn: hostlib.FileOpenBang})
-			module.Environment.Set("write", &object.Builtin{Fn: hostlib.FileWrite})
-			module.Environment.Set("write!", &object.Builtin{Fn: hostlib.FileWriteBang})
-		case "Map":
-			module.Environment.Set("get", &object.Builtin{Fn: hostlib.MapGet})
-			module.Environment.Set("has_key?", &object.Builtin{Fn: hostlib.MapHasKey})
-			module.Environment.Set("keys", &object.Builtin{Fn: hostlib.MapKeys})
-			module.Environment.Set("length", &object.Builtin{Fn: hostlib.MapLength})
-			module.Environment.Set("try_get", &object.Builtin{Fn: hostlib.MapTryGet})
-		case "Math":
-			module.Environment.Set("abs", &object.Builtin{Fn: hostlib.MathAbs})
-			module.Environment.Set("ceiling", &object.Builtin{Fn: hostlib.MathCeil})
-			module.Environment.Set("cos", &object.Builtin{Fn: hostlib.MathCos})
-			module.Environment.Set("floor", &object.Builtin{Fn: hostlib.MathFloor})
-			module.Environment.Set("max", &object.Builtin{Fn: hostlib.MathMax})
-			module.Environment.Set("min", &object.Builtin{Fn: hostlib.MathMin})
-			module.Environment.Set("pi", &object.Builtin{Fn: hostlib.MathPi})
-			module.Environment.Set("round", &object.Builtin{Fn: hostlib.MathRound})
-			module.Environment.Set("sin", &object.Builtin{Fn: hostlib.MathSin})
-			module.Environment.Set("sqrt", &object.Builtin{Fn: hostlib.MathSqrt})
-			module.Environment.Set("tan", &object.Builtin{Fn: hostlib.MathTan})
-		case "String":
-			module.Environment.Set("concat", &object.Builtin{Fn: hostlib.StringConcat})
-			module.Environment.Set("contains?", &object.Builtin{Fn: hostlib.StringContains})
-			module.Environment.Set("ends_with?", &object.Builtin{Fn: hostlib.StringEndsWith})
-			module.Environment.Set("index_of", &object.Builtin{Fn: hostlib.StringIndexOf})
-			module.Environment.Set("length", &object.Builtin{Fn: hostlib.StringLength})
-			module.Environment.Set("lower", &object.Builtin{Fn: hostlib.StringLower})
-			module.Environment.Set("match?", &object.Builtin{Fn: hostlib.StringMatch})
-			module.Environment.Set("pad_left", &object.Builtin{Fn: hostlib.StringPadLeft})
-			module.Environment.Set("pad_right", &object.Builtin{Fn: hostlib.StringPadRight})
-			module.Environment.Set("parse_num", &object.Builtin{Fn: hostlib.StringParseNum})
-			module.Environment.Set("replace", &object.Builtin{Fn: hostlib.StringReplace})
-			module.Environment.Set("replace_all", &object.Builtin{Fn: hostlib.StringReplaceAll})
-			module.Environment.Set("split", &object.Builtin{Fn: hostlib.StringSplit})
-			module.Environment.Set("starts_with?", &object.Builtin{Fn: hostlib.StringStartsWith})
-			module.Environment.Set("trim", &object.Builtin{Fn: hostlib.StringTrim})
-			module.Environment.Set("trim_end", &object.Builtin{Fn: hostlib.StringTrimEnd})
-			module.Environment.Set("trim_start", &object.Builtin{Fn: hostlib.StringTrimStart})
-			module.Environment.Set("try_parse_num", &object.Builtin{Fn: hostlib.StringTryParseNum})
-			module.Environment.Set("upper", &object.Builtin{Fn: hostlib.StringUpper})
-		}
+		hostlib.Install(moduleName, module.Environment)
 		return module
 	}
 
@@ -304,6 +304,11 @@ func TestErrorHandling(t *testing.T) {
 			"unknown operator: -BOOLEAN",
 			1, 1,
 		},
+		{
+			"let x = 1\n\nlet y = -true",
+			"unknown operator: -BOOLEAN",
+			3, 9,
+		},
 		{
 			"true + false;",
 			"unknown operator: BOOLEAN + BOOLEAN",
@@ -561,7 +566,7 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3]@-1",
-			nil,
+			3,
 		},
 	}
 
@@ -696,9 +701,9 @@ func TestEvalMapLiteral(t *testing.T) {
 			t.Fatalf("object is not Map. got=%T (%+v)", evaluated, evaluated)
 		}
 
-		if len(result.Store.Buckets) != len(tt.expected) {
+		if result.Len() != len(tt.expected) {
 			t.Fatalf("Map has wrong num of pairs. got=%d, want=%d",
-				len(result.Store.Buckets), len(tt.expected))
+				result.Len(), len(tt.expected))
 		}
 
 		for expectedKey, expectedValue := range tt.expected {
@@ -732,6 +737,26 @@ func TestEvalMapLiteral(t *testing.T) {
 		}
 	}
 }
+func TestMapUpdates(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"{b: 1, a: 2}", "{:b = 1, :a = 2}"},
+		{"let m = {a: 1}\nlet n = { m with :a = 2 :b = 3 }\n[m n]", "[{:a = 1} {:a = 2, :b = 3}]"},
+		{"{a: 1, b: 2} == {b: 2, a: 1}", "true"},
+		{"{a: 1} == {a: 1, b: 2}", "false"},
+		{"let m = {{a: 1 b: 2} = 5}\nm @ {b: 2 a: 1}", "5"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s. got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestEvalMapIndexOperator(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -863,6 +888,464 @@ func TestEvalCaseExpression(t *testing.T) {
 		testIntegerObject(t, evaluated, tt.expected)
 	}
 }
+
+func TestCaseGuards(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"case (:ok 5) { (:ok n) when n > 0 => n, (:ok n) => 0 }", 5},
+		{"let v = 0 - 5\ncase (:ok v) { (:ok n) when n > 0 => n, (:ok n) => 0 }", 0},
+		{"let limit = 3\ncase 4 { n when n < limit => 1, n when n == limit + 1 => 2, _ => 3 }", 2},
+		{"case [1 2] { [a b] when a > b => a, [a b] => b }", 2},
+		{"case 1 { 1 when false => 1 }", "no matching case for 1"},
+		{"case 1 { n when n + :a => 1, _ => 2 }", "type mismatch: INTEGER + ATOM"},
+		{"fn f(x) { case x { n when n > 0 => f(n - 1), _ => :done } }\nf(3)", ":done"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+			} else if evaluated.Inspect() != expected {
+				t.Errorf("%s: expected %s. got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestWithExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"with { (:ok a) <- (:ok 1)\n(:ok b) <- (:ok a + 1)\na + b }", 3},
+		{"with { (:ok a) <- (:ok 1)\n(:ok b) <- (:error :bad)\na + b }", "(:error :bad)"},
+		{"with { (:ok a) <- (:error 5)\na } else { (:error e) => e * 2 }", 10},
+		{"with { (:ok a) <- (:error 5)\na } else { (:error e) when e > 9 => 0, (:error e) => e }", 5},
+		{"with { (:ok a) <- :nope\na } else { (:error e) => e }", "no matching case for :nope"},
+		{"let a = 1\nwith { (:ok a) <- (:ok 5)\nlet b = a * 2\nb }", 10},
+		{"let a = 1\nwith { (:ok a) <- (:ok 5)\na }\na", 1},
+		{"with { 1 <- 2\n3 }", 2},
+		{"with { (:ok a) <- (:ok 1) }", "(:ok 1)"},
+		{"with { (:ok a) <- 1 + :a\na }", "type mismatch: INTEGER + ATOM"},
+		{"fn loop(n) { with { true <- n > 0\nloop(n - 1) } else { false => :done } }\nloop(100000)", ":done"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+			} else if evaluated.Inspect() != expected {
+				t.Errorf("%s: expected %s. got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestTryOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"fn f(r) { let v = (r)?\nv + 1 }\nf((:ok 1))", 2},
+		{"fn f(r) { let v = (r)?\nv + 1 }\nf((:error :bad))", "(:error :bad)"},
+		{"fn f(s) { String.try_parse_num(s)? * 2 }\nf(\"21\")", 42},
+		{"fn f(s) { String.try_parse_num(s)? * 2 }\nf(\"x\")", ":none"},
+		{"fn f(a b) { [(a)? (b)?] }\nf((:ok 1) (:error 2))", "(:error 2)"},
+		{"fn f(r) { return (r)? }\nf((:error 1))", "(:error 1)"},
+		{"fn f(r) { if true { (r)? } else { 0 } }\nf((:some 3))", 3},
+		{"let g = \\r => (r)?\n[g((:ok 1)) g(:none)]", "[1 :none]"},
+		{"fn f(r) { let v = r?\nv + 1 }\n[f((:ok 1)) f((:error :bad))]", "[2 (:error :bad)]"},
+		{"let ok? = 1\nlet ok = (:ok 2)\nok?", 1},
+		{"r?", "identifier not found: r?"},
+		{"fn f() { (5)? }\nf()", "? expects an (:ok v) or (:error e) result, or a (:some v) or :none option. got=5"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+			} else if evaluated.Inspect() != expected {
+				t.Errorf("%s: expected %s. got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestRanges(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1..5", "1..5"},
+		{"10..0//-2", "10..0//-2"},
+		{"len(1..10)", 10},
+		{"len(0..1000000000000//2)", 500000000001},
+		{"len(5..1)", 0},
+		{"[4 in 0..10//2, 5 in 0..10//2, 11 in 1..10, 0 in 1..10]", "[true false false false]"},
+		{"let n = 4\nArray.map(1..n - 1, \\x => x * 10)", "[10 20 30]"},
+		{"Array.sum(1..100)", 5050},
+		{"let xs = [10 20 30 40 50]\nxs @ (1..3)", "[20 30 40]"},
+		{"let xs = [10 20 30 40 50]\nxs @ (1..-2)", "[20 30 40]"},
+		{"let xs = [10 20 30]\nxs @ (-1..0//-1)", "[30 20 10]"},
+		{"let xs = [10 20 30]\nxs @ -1..0//-1", "[30 20 10]"},
+		{"let xs = [10 20 30]\nxs @ (-9..9//2)", "[10 30]"},
+		{"[[1 2 3] [4 5 6]] @ [0..1, 1..2]", "[[2 3] [5 6]]"},
+		{"1..3 == 1..3//1", true},
+		{"fn f(n) { case n { d in 0..9 => d * 10\n_ in 10..99 => :two\n_ => :big } }\n[f(7) f(42) f(420)]", "[70 :two :big]"},
+		{"case 5 { n in 1..9 when n > 6 => :high\nn in 1..9 => n }", 5},
+		{"\"ell\" in \"hello\"", true},
+		{"[3 in [1 2 3], :b in {:a = 1}]", "[true false]"},
+		{"Array.take(0..4_000_000_000_000_000_000, 3)", "[0 1 2]"},
+		{"Array.find(0..9_000_000_000_000_000_000//7, \\x => x > 20)", 21},
+		{"Array.range(2, 5)", "2..4"},
+		{"[Array.range(5) @ 0, Array.range(5) @ -1, Array.range(5) @ 5]", "[0 4 :nil]"},
+		{"Array.range(5) @ (1..2)", "[1 2]"},
+		{"push(Array.range(3), 9)", "[0 1 2 9]"},
+		{"Array.range(3) ++ [3]", "[0 1 2 3]"},
+		{"let [a | r] = Array.range(3)\n(a r)", "(0 1..2)"},
+		{"let [a b c] = 4..6\nc", 6},
+		{"case 1..2 { [a b c] => :three\n[a | []] => :one\n[a | r] => r }", "2..2"},
+		{"[Array.median(Array.range(5)), head(1..3), last(1..3), Array.tail(Array.range(3))]", "[2 1 3 [1 2]]"},
+		{"Array.zip(1..2, 3..4)", "[(1 3) (2 4)]"},
+		{"[Array.range(3) === [0 1 2], [0 1 2] === 0..2, 0..2 !== [0 1], 1..3 == 1..4//2]", "[true true true false]"},
+		{"Array.range(3) == [0 2 2]", "[true false true]"},
+		{"let a = Array.range(10)\n(a @ (a % 2 == 0)) === [0 2 4 6 8]", true},
+		{"Array.range(5, 2) |> Array.map(\\x => x)", "[]"},
+		{"let min = -9223372036854775807 - 1\n[min in min..9223372036854775807//2, 9223372036854775807 in min..9223372036854775807//2]", "[true false]"},
+		{"let min = -9223372036854775807 - 1\n[9223372036854775807 in 9223372036854775807..min//-3, min in 9223372036854775807..min//-1]", "[true true]"},
+		{"let min = -9223372036854775807 - 1\n[1 2 3] @ (min..9223372036854775807)", "[1 2 3]"},
+		{"let min = -9223372036854775807 - 1\n[1 2 3] @ (9223372036854775807..min//-2)", "[2]"},
+		{"let min = -9223372036854775807 - 1\nlen(min..9223372036854775807)", "the length of -9223372036854775808..9223372036854775807 is too large for an integer"},
+		{"Array.sort(0..4_000_000_000_000_000_000)", "sort() cannot make an array of the range 0..4000000000000000000, which has more than 2147483647 elements"},
+		{"1.5..2", "range bounds must be integers, got FLOAT..INTEGER"},
+		{"1..2//0", "the step of a range must be a non-zero integer, got 0"},
+		{"1 in 5", "in requires a range, array, tuple, map or string, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+			} else if evaluated.Inspect() != expected {
+				t.Errorf("%s: expected %s. got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestCasePatterns(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"case {status: 200 body: 5} { {status: 404} => 0, {status: 200 body: b} => b }", 5},
+		{"case {a: 5} { {a: 1 b: b} => b, {a: a} => a }", 5},
+		{"case (:error \"timeout\") { (:error \"closed\") => 1, (:error \"timeout\") => 2, _ => 3 }", 2},
+		{"case (:ok (:user 7)) { (:ok (:admin id)) => 0, (:ok (:user id)) => id }", 7},
+		{"case [1 2 3] { [] => 0, [h | t] => h }", 1},
+		{"case [1 2 3] { [h | t] => t }", "[2 3]"},
+		{"case [1] { [a b | t] => 1, [a | t] => 2 }", 2},
+		{"case [1] { [a | t] => t }", "[]"},
+		{"fn sum(xs) { case xs { [] => 0, [h | t] => h + sum(t) } }\nsum([1 2 3 4])", 10},
+		{"let [a | rest] = [1 2 3]\nrest", "[2 3]"},
+		{"let [a | rest] = []", "cannot destructure array: size mismatch"},
+		{"let (a (b c) d) = (1 (2 3) 4)\nd", 4},
+		{"let xs = [2 3]\n[0 1 | xs]", "[0 1 2 3]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+			} else if evaluated.Inspect() != expected {
+				t.Errorf("%s: expected %s. got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestPinPatterns(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1\ncase (:ok 1) { (:ok ^x) => 1, _ => 2 }", 1},
+		{"let x = 2\ncase (:ok 1) { (:ok ^x) => 1, _ => 2 }", 2},
+		{"let x = 2\ncase 2 { ^x => :same, _ => :other }", ":same"},
+		{"let k = 5\ncase {a: 5} { {a: ^k} => 1, _ => 0 }", 1},
+		{"let h = 1\ncase [1 2] { [^h | t] => t }", "[2]"},
+		{"let x = 1\nlet (^x y) = (1 2)\ny", 2},
+		{"let x = 1\nlet (^x y) = (3 2)", "cannot destructure tuple: value mismatch"},
+		{"let x = 1\nlet x = 2\nx", 2},
+		{"let x = 1\n^x", "cannot use ^x outside of a pattern"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+			} else if evaluated.Inspect() != expected {
+				t.Errorf("%s: expected %s. got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestFunctionClauses(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"fn fact(0) { 1 }\nfn fact(n) { n * fact(n - 1) }\nfact(5)", 120},
+		{"fn unwrap((:ok v)) { v }\nfn unwrap((:error _)) { 0 }\nunwrap((:ok 7)) + unwrap((:error :x))", 7},
+		{"fn size([]) { 0 }\nfn size([_ | t]) { 1 + size(t) }\nsize([1 2 3])", 3},
+		{"fn pick(:a x) { x }\nfn pick(:b x) { x * 2 }\npick(:b 4)", 8},
+		{"fn sum([] acc) { acc }\nfn sum([h | t] acc) { sum(t acc + h) }\nsum([1 2 3 4] 0)", 10},
+		{"let want = 2\nfn is_want(^want) { true }\nfn is_want(_) { false }\nis_want(2)", "true"},
+		{"fn f(:a) { 1 }\nfn f(:b) { 2 }\nf(:c)", "no matching clause for f(:c), tried the clauses on lines 1, 2"},
+		{"fn f(:a) { 1 }\nfn f(:b) { 2 }\nf(:a :b)", "wrong number of arguments. got=2, want=1"},
+		// a function defined again after other statements replaces the first
+		{"fn f(x) { 1 }\nlet y = 0\nfn f(x) { 2 }\nf(0)", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+			} else if evaluated.Inspect() != expected {
+				t.Errorf("%s: expected %s. got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestOverloads(t *testing.T) {
+	module := `
+    module Seq
+
+    fn range(n) { range(0 n) }
+    fn range(a b) { if a >= b { [] } else { [a | range(a + 1 b)] } }
+    `
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"Seq.range(3)", "[0 1 2]"},
+		{"Seq.range(1 3)", "[1 2]"},
+		{"1 |> Seq.range(3)", "[1 2]"},
+		{"let r = Seq.range\nr(2)", "[0 1]"},
+		{"Seq.range(1 2 3)", "wrong number of arguments. got=3, want=1 or 2"},
+		{"fn f(x) { x }\nfn f(x y) { x + y }\nf(1) + f(2 3)", 6},
+		{"fn count(n) { count(n 0) }\nfn count(n acc) { if n == 0 { acc } else { count(n - 1 acc + 1) } }\ncount(100000)", 100000},
+		// rebinding the name drops the other arities
+		{"fn f(x) { x }\nfn f(x y) { x + y }\nlet f = \\x => x * 10\nf(1)", 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithModule(module, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+			} else if evaluated.Inspect() != expected {
+				t.Errorf("%s: expected %s. got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestDefaultAndKeywordArguments(t *testing.T) {
+	module := `
+    module Text
+
+    fn pad(s n char = " ") { $"{char}{s}/{n}" }
+    fn between(s left = "<" right = left) { $"{left}{s}{right}" }
+    `
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`Text.pad("a" 1)`, " a/1"},
+		{`Text.pad("a" 1 "-")`, "-a/1"},
+		{`Text.pad("a" 1 char = "-")`, "-a/1"},
+		{`Text.pad("a" n = 2)`, " a/2"},
+		{`Text.pad(char = "*" n = 3 s = "b")`, "*b/3"},
+		{`"a" |> Text.pad(1 char = "-")`, "-a/1"},
+		{`Text.between("x")`, "<x<"},
+		{`Text.between("x" right = ">")`, "<x>"},
+		{`Text.between("x" "[")`, "[x["},
+		{"fn inc(x by = 1) { x + by }\ninc(1) + inc(1 by = 10)", 13},
+		{"fn count(n acc = 0) { if n == 0 { acc } else { count(n - 1 acc = acc + 1) } }\ncount(10000)", 10000},
+		{`Text.pad("a")`, "wrong number of arguments. got=1, want=2 to 3"},
+		{`Text.pad("a" 1 "-" "+")`, "wrong number of arguments. got=4, want=2 to 3"},
+		{`Text.pad("a" char = "-")`, "missing argument n"},
+		{`Text.pad("a" 1 width = 2)`, "unknown keyword argument width"},
+		{`Text.pad("a" 1 s = "b")`, "argument s given more than once"},
+		{"fn f((:ok v)) { v }\nf(v = 1)", "keyword arguments can only be given to functions with named parameters, got FUNCTION"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithModule(module, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+			} else if str, ok := evaluated.(*object.String); !ok || str.Value != expected {
+				t.Errorf("%s: expected %q. got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestRestParametersAndSpread(t *testing.T) {
+	module := `
+    module Log
+
+    fn line(level ...parts) { $"{level}: {parts}" }
+    fn count(...xs) { len(xs) }
+    `
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`Log.line(:info)`, ":info: []"},
+		{`Log.line(:info 1 2 3)`, ":info: [1 2 3]"},
+		{`let args = [1 2]` + "\n" + `Log.line(:warn ...args 3)`, ":warn: [1 2 3]"},
+		{`Log.line(...[:error "a"])`, ":error: [\"a\"]"},
+		{`:debug |> Log.line(1 2)`, ":debug: [1 2]"},
+		{`Log.count()`, 0},
+		{`Log.count(...[1 2] ...[3])`, 3},
+		{"let sum = \\...xs => case xs { [] => 0\n [h | t] => h + sum(...t) }\nsum(1 2 3 4)", 10},
+		{"let first = \\x ...more => x\nfirst(...[5 6 7])", 5},
+		{"[1 2 3] |> \\...xs => len(xs)", 1},
+		{"fn f(a b = 2 ...more) { a + b + len(more) }\nf(1) + f(1 5) + f(1 5 0 0)", 17},
+		{"fn add(a b) { a + b }\nadd(...[1 2])", 3},
+		{"fn add(a b) { a + b }\nadd(...[1 2 3])", "wrong number of arguments. got=3, want=2"},
+		{`Log.line()`, "wrong number of arguments. got=0, want=1 or more"},
+		{`Log.count(...5)`, "cannot spread INTEGER, expected an array"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithModule(module, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+			} else if str, ok := evaluated.(*object.String); !ok || str.Value != expected {
+				t.Errorf("%s: expected %q. got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestPartialApplication(t *testing.T) {
+	module := `
+    module Calc
+
+    fn add(a b) { a + b }
+    fn sub3(a b c) { a - b - c }
+    `
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let inc = Calc.add(_ 1)` + "\n" + `inc(41)`, 42},
+		{`let f = Calc.sub3(_ 1 _)` + "\n" + `f(10 2)`, 7},
+		{`10 |> Calc.sub3(100 _ 5)`, 85},
+		{`10 |> Calc.add(5)`, 15},
+		{"let sub = \\a b => a - b\nlet from10 = sub(10 _)\n3 |> from10(_)", 7},
+		{"fn twice(f x) { f(f(x)) }\ntwice(Calc.add(_ 3) 1)", 7},
+		{"fn run(x) { x |> Calc.add(1 _) }\nrun(1) + run(2)", 5},
+		{`let c = Function.curry(Calc.sub3)` + "\n" + `let c1 = c(10)` + "\n" + `let c2 = c1(1)` + "\n" + `c2(2)`, 7},
+		{`let c = Function.curry(Calc.sub3)` + "\n" + `let c2 = c(10 1)` + "\n" + `c2(2)`, 7},
+		{`let p = Function.partial(Calc.sub3 10)` + "\n" + `p(1 2)`, 7},
+		{`let inc = Calc.add(_ 1)` + "\n" + `inc()`, "wrong number of arguments. got=0, want=1"},
+		{`Function.curry(len)`, "curry() can not tell how many arguments the function takes, give it as curry(f n)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithModule(module, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: expected error %q. got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			} else if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+
+	evaluated := testEvalWithModule(module, `Calc.add(_ 1)`)
+	if evaluated.Inspect() != "Calc.add(_ 1)" {
+		t.Errorf("wrong inspect. got=%q", evaluated.Inspect())
+	}
+}
+
 func TestEvalModule(t *testing.T) {
 	input := `
     module TestModule 
@@ -898,6 +1381,29 @@ func TestEvalModule(t *testing.T) {
 	testIntegerObject(t, val, 20)
 }
 
+func TestEvalModuleWithError(t *testing.T) {
+	evaluated := testEvalWithModule(`
+    module Broken
+    struct { x }
+    let ok = Broken{x = 1}
+    let y = missing
+    `, `Broken.ok`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "identifier not found: missing" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+
+	env := object.NewEnvironment()
+	ctx := object.NewEvalContext()
+	Eval(parser.New(lexer.New("module Broken\nlet y = missing\n", "test")).ParseProgram(), env, ctx)
+	if _, ok := env.GetModule("Broken"); ok {
+		t.Errorf("module Broken was registered although its body failed")
+	}
+}
+
 func TestMapUpdateLiteral(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -933,3 +1439,163 @@ func TestMapUpdateLiteral(t *testing.T) {
 		}
 	}
 }
+
+// testEvalWithModule loads the module source, then evaluates input in the
+// same environment.
+func testEvalWithModule(module, input string) object.Object {
+	env := object.NewEnvironment()
+	ctx := object.NewEvalContext()
+
+	l := lexer.New(module, "test")
+	p := parser.New(l)
+	if result := Eval(p.ParseProgram(), env, ctx); isError(result) {
+		return result
+	}
+
+	l = lexer.New(input, "test")
+	p = parser.New(l)
+	return Eval(p.ParseProgram(), env, ctx)
+}
+
+func TestStructs(t *testing.T) {
+	module := `
+    module MyApp.Dog
+
+    struct {
+        name
+        age
+    }
+
+    fn bark(dog) {
+        $"Woof, my name is {dog.name}"
+    }
+    `
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let dog = MyApp.Dog{name = "Fido" age = 3}; dog.age`, 3},
+		{`let dog = MyApp.Dog{name: "Fido", age: 3}; dog.name`, "Fido"},
+		{`MyApp.Dog.bark(MyApp.Dog{name = "Fido" age = 3})`, "Woof, my name is Fido"},
+		{`let dog = MyApp.Dog{name = "Fido" age = 3}; let older = { dog with age = 4 }; older.age`, 4},
+		{`let dog = MyApp.Dog{name = "Fido" age = 3}; let older = { dog with age = 4 }; dog.age`, 3},
+		{`let dog = MyApp.Dog{name = "Fido" age = 3}; let older = { dog with :age = 4 }; type(older)`, "STRUCT"},
+		{`MyApp.Dog{name = "Fido" age = 3} == MyApp.Dog{age = 3 name = "Fido"}`, true},
+		{`MyApp.Dog{name = "Fido" age = 3} == MyApp.Dog{name = "Rex" age = 3}`, false},
+		{`let MyApp.Dog{name = n} = MyApp.Dog{name = "Fido" age = 3}; n`, "Fido"},
+		{`case MyApp.Dog{name = "Fido" age = 3} { MyApp.Dog{name = "Rex"} => 1, MyApp.Dog{age = a} => a }`, 3},
+		{`case {name: "Fido"} { MyApp.Dog{name = n} => n, _ => 0 }`, 0},
+		{`MyApp.Dog{name = "Fido"}`, "missing field age for struct MyApp.Dog"},
+		{`MyApp.Dog{name = "Fido" age = 3 breed = "lab"}`, "unknown field breed for struct MyApp.Dog"},
+		{`MyApp.Dog{name = "Fido" age = 3}.breed`, "unknown field breed for struct MyApp.Dog"},
+		{`let dog = MyApp.Dog{name = "Fido" age = 3}; { dog with breed = "lab" }`, "unknown field breed for struct MyApp.Dog"},
+		{`let dog = MyApp.Dog{name = "Fido" age = 3}; { dog with name = first age = second }`, "identifier not found: first"},
+		{`let dog = MyApp.Dog{name = "Fido" age = 3}; { dog with age = second name = first }`, "identifier not found: second"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithModule(module, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			switch result := evaluated.(type) {
+			case *object.String:
+				if result.Value != expected {
+					t.Errorf("wrong string value. expected=%q, got=%q", expected, result.Value)
+				}
+			case *object.Error:
+				if result.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, result.Message)
+				}
+			default:
+				t.Errorf("object is not String or Error. got=%T (%+v)", evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestStructInspect(t *testing.T) {
+	module := `
+    module Point
+    struct { x y }
+    `
+
+	evaluated := testEvalWithModule(module, `Point{y = 2 x = 1}`)
+	if evaluated.Inspect() != "Point{x = 1, y = 2}" {
+		t.Errorf("wrong inspect. got=%q", evaluated.Inspect())
+	}
+
+	evaluated = testEvalWithModule(`module Empty`, `Empty{}`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "Empty does not define a struct" {
+		t.Errorf("expected error for module without struct. got=%+v", evaluated)
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"fn count(n acc) { if n == 0 { acc } else { count(n - 1 acc + 1) } } count(1000000 0)", 1000000},
+		{"fn count(n) { cond { n == 0 => 0, true => count(n - 1) } } count(1000000)", 0},
+		{"fn count(n) { case n { 0 => 7, _ => count(n - 1) } } count(1000000)", 7},
+		{"fn count(n) { if n == 0 { return 3 } return count(n - 1) } count(1000000)", 3},
+		{"fn even(n) { if n == 0 { 1 } else { odd(n - 1) } } fn odd(n) { if n == 0 { 0 } else { even(n - 1) } } even(1000000)", 1},
+		{"let count = \\n acc => if n == 0 { acc } else { count(n - 1 acc + 2) } count(1000000 0)", 2000000},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestStackTraces(t *testing.T) {
+	module := `
+    module Shapes
+
+    fn area(w h) {
+        check(w) * h
+    }
+
+    fn check(x) {
+        x + "px"
+    }
+    `
+
+	input := `fn main() {
+    let f = \x => Shapes.area(x 2)
+    f(1) + 1
+}`
+
+	evaluated := testEvalWithModule(module, input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := []object.StackFrame{
+		{Function: "main", FileName: "test", Line: 1, Column: 1},
+		{Function: "f", FileName: "test", Line: 3, Column: 5},
+		{Function: "Shapes.area", FileName: "test", Line: 2, Column: 26},
+		{Function: "Shapes.check", FileName: "test", Line: 5, Column: 9},
+	}
+
+	if len(errObj.Stack) != len(expected) {
+		t.Fatalf("wrong stack depth. want=%d, got=%d (%+v)", len(expected), len(errObj.Stack), errObj.Stack)
+	}
+
+	for i, frame := range expected {
+		if errObj.Stack[i] != frame {
+			t.Errorf("wrong frame %d. want=%+v, got=%+v", i, frame, errObj.Stack[i])
+		}
+	}
+
+	if errObj.Line != 9 || errObj.Column != 11 {
+		t.Errorf("wrong error position. got=%d:%d", errObj.Line, errObj.Column)
+	}
+}
@@ -0,0 +1,104 @@
+// evaluator/exports.go
+
+package evaluator
+
+import (
+	"renelle/ast"
+	"renelle/object"
+)
+
+// The functions below let the vm share the evaluator's semantics for the
+// operations it does not implement itself, so both give the same results.
+
+// EvalInfix applies a binary operator such as + or == to two values.
+func EvalInfix(ctx *object.EvalContext, operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(ctx, operator, left, right)
+}
+
+// EvalPrefix applies the - or ! operator to a value.
+func EvalPrefix(ctx *object.EvalContext, operator string, right object.Object) object.Object {
+	return evalPrefixExpression(ctx, operator, right)
+}
+
+// EvalTry evaluates `value?`, giving the unwrapped value, or a ReturnValue
+// holding the result to return from the function.
+func EvalTry(ctx *object.EvalContext, value object.Object) object.Object {
+	return evalTry(ctx, value)
+}
+
+// EvalIndex evaluates `left @ index`.
+func EvalIndex(ctx *object.EvalContext, left, index object.Object) object.Object {
+	return evalIndexExpression(ctx, left, index)
+}
+
+// GetModule returns the named module, loading it if it is not loaded yet.
+func GetModule(ctx *object.EvalContext, name string, env *object.Environment) object.Object {
+	if module, ok := env.GetModule(name); ok {
+		return module
+	}
+	return loadModule(ctx, name, env)
+}
+
+// GetProperty evaluates `left.name`.
+func GetProperty(ctx *object.EvalContext, left object.Object, name string) object.Object {
+	return getProperty(ctx, left, name)
+}
+
+// CallProperty evaluates `left.name(args)`.
+func CallProperty(ctx *object.EvalContext, left object.Object, name string, args []object.Object) object.Object {
+	module, ok := left.(*object.Module)
+	if !ok {
+		switch left.(type) {
+		case *object.Map, *object.Struct:
+			return newError(ctx, "invalid property access: %s()", name)
+		default:
+			return newError(ctx, "property access not supported: %s", left.Type())
+		}
+	}
+
+	funcObj := moduleFunction(ctx, module, name)
+	if isError(funcObj) {
+		return funcObj
+	}
+	return applyFunction(funcObj, args, ctx)
+}
+
+// BindKeywords orders the arguments of a call to function with keyword
+// arguments by its parameters, picking the function from an Overloads.
+func BindKeywords(ctx *object.EvalContext, function object.Object, args []object.Object, names []string, values []object.Object) (object.Object, []object.Object, object.Object) {
+	return bindKeywords(ctx, function, args, names, values)
+}
+
+// LookupBuiltin returns the global builtin function with the given name.
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	if name == "loop" {
+		return &object.Builtin{Fn: loop}, true
+	}
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+// Atom returns the interned atom with the given name.
+func Atom(name string) *object.Atom {
+	return getOrCreateAtom(name)
+}
+
+// IsTruthy reports whether a value counts as true in a condition.
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+// NewStruct builds a struct of the given module from its field values.
+func NewStruct(ctx *object.EvalContext, module object.Object, name string, fields []string, values []object.Object) object.Object {
+	return newStruct(ctx, module, name, fields, values)
+}
+
+// UpdateStruct evaluates `{ s with field = value }` for a struct.
+func UpdateStruct(ctx *object.EvalContext, s *object.Struct, fields []string, values []object.Object) object.Object {
+	return updateStruct(ctx, s, fields, values)
+}
+
+// EvalModule evaluates a module declaration, registering the module in env.
+func EvalModule(node *ast.Module, env *object.Environment, ctx *object.EvalContext) object.Object {
+	return Eval(node, env, ctx)
+}
@@ -0,0 +1,39 @@
+// evaluator/partial.go
+
+package evaluator
+
+import (
+	"renelle/ast"
+	"renelle/object"
+)
+
+// evalPartial evaluates a call with `_` placeholders among its arguments,
+// such as `add(_ 5)`, to a function taking the arguments left out. The
+// arguments given are evaluated now, not each time it is called.
+func evalPartial(function object.Object, call *ast.CallExpression, env *object.Environment, ctx *object.EvalContext) object.Object {
+	args := make([]object.Object, len(call.Arguments))
+	for i, arg := range call.Arguments {
+		if ast.IsPlaceholder(arg) {
+			continue
+		}
+		evaluated := Eval(arg, env, ctx)
+		if isError(evaluated) {
+			return evaluated
+		}
+		args[i] = evaluated
+	}
+	return &object.Partial{Fn: function, Args: args}
+}
+
+// applyPartial calls a partial application, or gathers the arguments of a
+// curried function until it has them all.
+func applyPartial(fn *object.Partial, args []object.Object, ctx *object.EvalContext) object.Object {
+	filled, ok := fn.Fill(args)
+	if !ok {
+		return newError(ctx, "wrong number of arguments. got=%d, want=%d", len(args), fn.Placeholders())
+	}
+	if len(filled) < fn.Curried {
+		return &object.Partial{Fn: fn.Fn, Args: filled, Curried: fn.Curried}
+	}
+	return applyFunction(fn.Fn, filled, ctx)
+}
@@ -0,0 +1,263 @@
+// evaluator/pattern.go
+
+package evaluator
+
+import (
+	"unicode"
+
+	"renelle/ast"
+	"renelle/constants"
+	"renelle/object"
+)
+
+// matchPattern matches a value against the left-hand side of a let, binding
+// the names in it in env. A mismatch is returned as an error saying why,
+// after which env may hold some bindings.
+func matchPattern(pattern ast.Expression, val object.Object, env *object.Environment, ctx *object.EvalContext) object.Object {
+	m := &matcher{env: env, ctx: ctx}
+	return m.match(pattern, val)
+}
+
+// tryMatch matches a value against the pattern of a case clause or function
+// parameter, reporting whether it matched. The error is not a mismatch but
+// one raised evaluating the pattern, such as a pin of an unbound name.
+func tryMatch(pattern ast.Expression, val object.Object, env *object.Environment, ctx *object.EvalContext) (bool, object.Object) {
+	m := &matcher{env: env, ctx: ctx}
+	if isError(m.match(pattern, val)) {
+		return false, m.err
+	}
+	return true, nil
+}
+
+// matcher matches values against patterns. Its methods return an error when
+// the value does not match, and also set err when the error came from
+// evaluating part of the pattern rather than from the value.
+type matcher struct {
+	env *object.Environment
+	ctx *object.EvalContext
+	err object.Object
+}
+
+// fail records an error raised evaluating the pattern and returns it.
+func (m *matcher) fail(err object.Object) object.Object {
+	m.err = err
+	return err
+}
+
+func (m *matcher) match(pattern ast.Expression, val object.Object) object.Object {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value == "_" {
+			return val
+		}
+		if unicode.IsUpper(rune(pattern.Value[0])) {
+			return m.fail(newError(m.ctx, "local variables can not start with an uppercase letter"))
+		}
+		m.env.Set(pattern.Value, val)
+		return val
+	case *ast.TupleLiteral:
+		return m.handleTupleDestructuring(pattern, val)
+	case *ast.ArrayLiteral:
+		return m.handleArrayDestructuring(pattern, val)
+	case *ast.MapLiteral:
+		return m.handleMapDestructuring(pattern, val)
+	case *ast.StructLiteral:
+		return m.handleStructDestructuring(pattern, val)
+	case *ast.InfixExpression:
+		if pattern.Operator == "in" {
+			return m.matchMembership(pattern, val)
+		}
+	}
+
+	expected := m.value(pattern)
+	if isError(expected) {
+		return expected
+	}
+	if !object.Equals(expected, val) {
+		return newError(m.ctx, "value mismatch")
+	}
+	return val
+}
+
+// matchElement matches a value nested in a collection of the given kind, so
+// that a literal that differs is reported against the collection.
+func (m *matcher) matchElement(pattern ast.Expression, val object.Object, kind string) object.Object {
+	if destructures(pattern) {
+		return m.match(pattern, val)
+	}
+
+	expected := m.value(pattern)
+	if isError(expected) {
+		return expected
+	}
+	if !object.Equals(expected, val) {
+		return newError(m.ctx, "cannot destructure %s: value mismatch", kind)
+	}
+	return val
+}
+
+// destructures reports whether a pattern binds names or looks inside the
+// value, rather than being a value the matched one must equal.
+func destructures(pattern ast.Expression) bool {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier, *ast.TupleLiteral, *ast.ArrayLiteral, *ast.MapLiteral, *ast.StructLiteral:
+		return true
+	case *ast.InfixExpression:
+		return pattern.Operator == "in"
+	}
+	return false
+}
+
+// matchMembership matches `pattern in collection`, a value that is in the
+// collection and matches the pattern, as in `n in 1..9`.
+func (m *matcher) matchMembership(pattern *ast.InfixExpression, val object.Object) object.Object {
+	collection := Eval(pattern.Right, m.env, m.ctx)
+	if isError(collection) {
+		return m.fail(collection)
+	}
+	member := evalInExpression(m.ctx, val, collection)
+	if isError(member) {
+		return member
+	}
+	if !isTruthy(member) {
+		return newError(m.ctx, "%s is not in %s", val.Inspect(), collection.Inspect())
+	}
+	return m.match(pattern.Left, val)
+}
+
+// value evaluates a pattern that is matched by equality.
+func (m *matcher) value(pattern ast.Expression) object.Object {
+	expected := patternValue(pattern, m.env, m.ctx)
+	if isError(expected) {
+		return m.fail(expected)
+	}
+	return expected
+}
+
+// patternValue evaluates a pattern that is matched by equality: a literal,
+// or a pinned name, `^name`, which stands for the value it is bound to.
+func patternValue(pattern ast.Expression, env *object.Environment, ctx *object.EvalContext) object.Object {
+	if pin, ok := pattern.(*ast.PinExpression); ok {
+		return evalIdentifier(ctx, pin.Name, env)
+	}
+	return Eval(pattern, env, ctx)
+}
+
+func (m *matcher) matchElements(patterns []ast.Expression, values []object.Object, kind string) object.Object {
+	for i, el := range patterns {
+		if result := m.matchElement(el, values[i], kind); isError(result) {
+			return result
+		}
+	}
+	return constants.OK
+}
+
+func (m *matcher) handleTupleDestructuring(tuple *ast.TupleLiteral, val object.Object) object.Object {
+	tupleObject, ok := val.(*object.Tuple)
+	if !ok {
+		return newError(m.ctx, "right-hand side of assignment is not a tuple")
+	}
+	if len(tuple.Elements) != len(tupleObject.Elements) {
+		return newError(m.ctx, "cannot destructure tuple: size mismatch")
+	}
+	return m.matchElements(tuple.Elements, tupleObject.Elements, "tuple")
+}
+
+// handleArrayDestructuring matches an array or range element by element. A
+// pattern with a rest, `[h | t]`, matches those at least as long as its
+// elements, binding the rest to an array or range of those left over.
+func (m *matcher) handleArrayDestructuring(array *ast.ArrayLiteral, val object.Object) object.Object {
+	switch val.(type) {
+	case *object.Array, *object.Range:
+	default:
+		return newError(m.ctx, "right-hand side of assignment is not an array")
+	}
+	elements, rest, ok := object.Split(val, len(array.Elements))
+	if !ok || array.Rest == nil && rest != nil {
+		return newError(m.ctx, "cannot destructure array: size mismatch")
+	}
+	if result := m.matchElements(array.Elements, elements, "array"); isError(result) {
+		return result
+	}
+
+	if array.Rest != nil {
+		if rest == nil {
+			rest = &object.Array{Elements: []object.Object{}}
+		}
+		if result := m.matchElement(array.Rest, rest, "array"); isError(result) {
+			return result
+		}
+	}
+	return constants.OK
+}
+
+// evalArrayRest builds `[a b | rest]`, the elements followed by those of rest.
+func evalArrayRest(elements []object.Object, restExpr ast.Expression, env *object.Environment, ctx *object.EvalContext) object.Object {
+	rest := Eval(restExpr, env, ctx)
+	if isError(rest) {
+		return rest
+	}
+	if r, ok := rest.(*object.Range); ok {
+		if rest, ok = r.Array(); !ok {
+			return newError(ctx, "the range %s has too many elements to make an array of", r.Inspect())
+		}
+	}
+	restArray, ok := rest.(*object.Array)
+	if !ok {
+		return newError(ctx, "expected array after |, got %s", rest.Type())
+	}
+	result := make([]object.Object, 0, len(elements)+len(restArray.Elements))
+	result = append(result, elements...)
+	return &object.Array{Elements: append(result, restArray.Elements...)}
+}
+
+// handleMapDestructuring matches a map holding at least the pattern's keys,
+// with values matching theirs.
+func (m *matcher) handleMapDestructuring(left *ast.MapLiteral, val object.Object) object.Object {
+	mapObj, ok := val.(*object.Map)
+	if !ok {
+		return newError(m.ctx, "expected map, got %s", val.Type())
+	}
+
+	for _, keyExpr := range left.Keys {
+		valueExpr := left.Pairs[keyExpr]
+		keyVal := Eval(keyExpr, m.env, m.ctx)
+		if isError(keyVal) {
+			return m.fail(keyVal)
+		}
+		if _, ok := keyVal.(object.Hashable); !ok {
+			return newError(m.ctx, "unusable as hash key: %s", keyVal.Type())
+		}
+
+		value, ok := mapObj.Get(keyVal)
+		if !ok {
+			return newError(m.ctx, "key not found: %s", keyVal.Inspect())
+		}
+
+		if result := m.matchElement(valueExpr, value, "map"); isError(result) {
+			return result
+		}
+	}
+
+	return val
+}
+
+func (m *matcher) handleStructDestructuring(pattern *ast.StructLiteral, val object.Object) object.Object {
+	structObj, ok := val.(*object.Struct)
+	if !ok || structObj.Module != pattern.Name.Value {
+		return newError(m.ctx, "expected %s struct, got %s", pattern.Name.Value, val.Type())
+	}
+
+	for i, field := range pattern.Fields {
+		value, ok := structObj.Get(field.Value)
+		if !ok {
+			return newError(m.ctx, "unknown field %s for struct %s", field.Value, structObj.Module)
+		}
+
+		if result := m.matchElement(pattern.Values[i], value, "struct"); isError(result) {
+			return result
+		}
+	}
+
+	return val
+}
@@ -0,0 +1,170 @@
+// evaluator/range.go
+
+package evaluator
+
+import (
+	"strings"
+
+	"renelle/constants"
+	"renelle/object"
+)
+
+// evalRangeExpression makes `first..last`, counting up by one.
+func evalRangeExpression(ctx *object.EvalContext, left, right object.Object) object.Object {
+	first, ok := left.(*object.Integer)
+	last, ok2 := right.(*object.Integer)
+	if !ok || !ok2 {
+		return newError(ctx, "range bounds must be integers, got %s..%s", left.Type(), right.Type())
+	}
+	return &object.Range{First: first.Value, Last: last.Value, Step: 1}
+}
+
+// evalStepExpression gives a range a step, as in `10..1//-1`.
+func evalStepExpression(ctx *object.EvalContext, left, right object.Object) object.Object {
+	r, ok := left.(*object.Range)
+	if !ok {
+		return newError(ctx, "// steps a range, got %s", left.Type())
+	}
+	step, ok := right.(*object.Integer)
+	if !ok || step.Value == 0 {
+		return newError(ctx, "the step of a range must be a non-zero integer, got %s", right.Inspect())
+	}
+	return &object.Range{First: r.First, Last: r.Last, Step: step.Value}
+}
+
+// evalInExpression reports whether a value is an element of a range, array
+// or tuple, a key of a map, or a substring of a string.
+func evalInExpression(ctx *object.EvalContext, left, right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Range:
+		n, ok := left.(*object.Integer)
+		return nativeBoolToBooleanObject(ok && right.Contains(n.Value))
+	case *object.Array:
+		return nativeBoolToBooleanObject(containsEqual(right.Elements, left))
+	case *object.Tuple:
+		return nativeBoolToBooleanObject(containsEqual(right.Elements, left))
+	case *object.Map:
+		if _, ok := left.(object.Hashable); !ok {
+			return constants.FALSE
+		}
+		_, ok := right.Get(left)
+		return nativeBoolToBooleanObject(ok)
+	case *object.String:
+		s, ok := left.(*object.String)
+		if !ok {
+			return newError(ctx, "only a string can be in a string, got %s", left.Type())
+		}
+		return nativeBoolToBooleanObject(strings.Contains(right.Value, s.Value))
+	}
+	return newError(ctx, "in requires a range, array, tuple, map or string, got %s", right.Type())
+}
+
+func containsEqual(elements []object.Object, value object.Object) bool {
+	for _, el := range elements {
+		if object.Equals(el, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// evalArrayRangeExpression returns the elements of an array at the indexes
+// in a range, where negative indexes count back from the end. Indexes past
+// either end are left out, without going through the range one by one.
+func evalArrayRangeExpression(array *object.Array, r *object.Range) object.Object {
+	n := int64(len(array.Elements))
+	elements := []object.Object{}
+	if n == 0 {
+		return &object.Array{Elements: elements}
+	}
+	first, last := r.First, r.Last
+	if first < 0 {
+		first += n
+	}
+	if last < 0 {
+		last += n
+	}
+
+	// distances are uint64s, which hold the gap between any two int64s
+	if r.Step > 0 {
+		step := uint64(r.Step)
+		if first < 0 {
+			// skip to the first index that is not before the array
+			gap := uint64(0) - uint64(first)
+			first = int64(uint64(first) + (gap+step-1)/step*step)
+		}
+		end := min(last, n-1)
+		for i := first; i <= end; i += int64(step) {
+			elements = append(elements, array.Elements[i])
+			if uint64(end-i) < step {
+				break
+			}
+		}
+	} else {
+		step := -uint64(r.Step)
+		if first >= n {
+			// skip to the first index that is not past the array
+			gap := uint64(first) - uint64(n-1)
+			first = int64(uint64(first) - (gap+step-1)/step*step)
+		}
+		end := max(last, 0)
+		for i := first; i >= end; i -= int64(step) {
+			elements = append(elements, array.Elements[i])
+			if uint64(i-end) < step {
+				break
+			}
+		}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// rangeArray returns the elements of a range in an array, for the places a
+// range works as the array it stands for. Anything else is returned as is.
+func rangeArray(ctx *object.EvalContext, obj object.Object) object.Object {
+	r, ok := obj.(*object.Range)
+	if !ok {
+		return obj
+	}
+	arr, ok := r.Array()
+	if !ok {
+		return newError(ctx, "the range %s has too many elements to make an array of", r.Inspect())
+	}
+	return arr
+}
+
+// evalRangeInfixExpression compares two ranges, or a range and anything
+// with === and !==, by their elements. Otherwise it applies the operator to
+// the array of the range's elements, as in `1..3 ++ [4]`.
+func evalRangeInfixExpression(ctx *object.EvalContext, operator string, left, right object.Object) object.Object {
+	bothRanges := left.Type() == object.RANGE_OBJ && right.Type() == object.RANGE_OBJ
+	switch {
+	case operator == "===", bothRanges && operator == "==":
+		return nativeBoolToBooleanObject(object.Equals(left, right))
+	case operator == "!==", bothRanges && operator == "!=":
+		return nativeBoolToBooleanObject(!object.Equals(left, right))
+	}
+	if left = rangeArray(ctx, left); isError(left) {
+		return left
+	}
+	if right = rangeArray(ctx, right); isError(right) {
+		return right
+	}
+	return evalInfixExpression(ctx, operator, left, right)
+}
+
+// evalRangeIndexExpression indexes a range as the array of its elements,
+// finding a single element without making the array.
+func evalRangeIndexExpression(ctx *object.EvalContext, r *object.Range, index object.Object) object.Object {
+	if i, ok := index.(*object.Integer); ok {
+		n, ok := r.Index(i.Value)
+		if !ok {
+			return constants.NIL
+		}
+		return &object.Integer{Value: n}
+	}
+	arr := rangeArray(ctx, r)
+	if isError(arr) {
+		return arr
+	}
+	return evalIndexExpression(ctx, arr, index)
+}
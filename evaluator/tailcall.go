@@ -0,0 +1,128 @@
+// evaluator/tailcall.go
+
+package evaluator
+
+import (
+	"renelle/ast"
+	"renelle/constants"
+	"renelle/object"
+)
+
+// evalTail evaluates a node in tail position of a function body: the last
+// statement of a block, a branch of if, cond, case or with, or a returned
+// value.
+// A call to a Renelle function found there is not made, but returned as a
+// TailCall for applyFunction to run in place of the current call, so deep
+// recursion does not grow the Go stack.
+func evalTail(node ast.Node, env *object.Environment, ctx *object.EvalContext) object.Object {
+	if node == nil {
+		return nil
+	}
+
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		return evalTailBlock(node.Statements, env, ctx)
+
+	case *ast.ExpressionStatement:
+		return evalTail(node.Expression, env, ctx)
+
+	case *ast.ReturnStatement:
+		ctx.Line = node.Token.Line
+		ctx.Column = node.Token.Column
+		val := evalTail(node.ReturnValue, env, ctx)
+		if isError(val) {
+			return val
+		}
+		if tc, ok := val.(*object.TailCall); ok {
+			return tc
+		}
+		return &object.ReturnValue{Value: val}
+
+	case *ast.IfExpression:
+		condition := Eval(node.Condition, env, ctx)
+		if isError(condition) {
+			return condition
+		}
+
+		if isTruthy(condition) {
+			return evalTail(node.Consequence, env, ctx)
+		} else if node.Alternative != nil {
+			return evalTail(node.Alternative, env, ctx)
+		}
+		return constants.NIL
+
+	case *ast.CondExpression:
+		for i, cond := range node.Conditions {
+			condVal := Eval(cond, env, ctx)
+			if isError(condVal) {
+				return condVal
+			}
+
+			if isTruthy(condVal) {
+				return evalTailBlock(node.Consequences[i].Statements, env, ctx)
+			}
+		}
+		return constants.NIL
+
+	case *ast.CaseExpression:
+		ctx.Line = node.Token.Line
+		ctx.Column = node.Token.Column
+		branch, branchEnv, err := matchCase(node, env, ctx)
+		if err != nil {
+			return err
+		}
+		return evalTail(branch, branchEnv, ctx)
+
+	case *ast.WithExpression:
+		return evalWith(node, env, ctx, evalTail)
+
+	case *ast.CallExpression:
+		function := Eval(node.Function, env, ctx)
+		if isError(function) {
+			return function
+		}
+
+		if node.Placeholders() > 0 {
+			return evalPartial(function, node, env, ctx)
+		}
+
+		function, args, err := evalArguments(function, node, env, ctx)
+		if err != nil {
+			return err
+		}
+
+		ctx.Line = node.Token.Line
+		ctx.Column = node.Token.Column
+		if overloads, ok := function.(*object.Overloads); ok {
+			if resolved, ok := overloads.Lookup(len(args)); ok {
+				function = resolved
+			}
+		}
+		if fn, ok := function.(*object.Function); ok {
+			return &object.TailCall{Fn: fn, Args: args}
+		}
+		return applyFunction(function, args, ctx)
+	}
+
+	return Eval(node, env, ctx)
+}
+
+func evalTailBlock(stmts []ast.Statement, env *object.Environment, ctx *object.EvalContext) object.Object {
+	var result object.Object
+
+	for i, statement := range stmts {
+		if i == len(stmts)-1 {
+			return evalTail(statement, env, ctx)
+		}
+
+		result = Eval(statement, env, ctx)
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return result
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,44 @@
+// evaluator/try.go
+
+package evaluator
+
+import (
+	"strings"
+
+	"renelle/object"
+)
+
+// evalTry unwraps the value of `value?`. An (:ok v) or (:some v) gives v,
+// while an (:error ...) tuple or :none is returned from the enclosing
+// function as it is.
+func evalTry(ctx *object.EvalContext, value object.Object) object.Object {
+	switch value := value.(type) {
+	case *object.Tuple:
+		if len(value.Elements) > 0 {
+			if tag, ok := value.Elements[0].(*object.Atom); ok {
+				switch {
+				case len(value.Elements) == 2 && (tag.Value == "ok" || tag.Value == "some"):
+					return value.Elements[1]
+				case tag.Value == "error":
+					return &object.ReturnValue{Value: value}
+				}
+			}
+		}
+	case *object.Atom:
+		if value.Value == "none" {
+			return &object.ReturnValue{Value: value}
+		}
+	}
+	return newError(ctx, "? expects an (:ok v) or (:error e) result, or a (:some v) or :none option. got=%s", value.Inspect())
+}
+
+// tryName looks up the name in `r?`, which the lexer reads as a single name
+// ending in ?. When no such name is bound but r is, it gives the value of r,
+// so that `r?` unwraps r as `(r)?` does.
+func tryName(name string, env *object.Environment) (object.Object, bool) {
+	base, ok := strings.CutSuffix(name, "?")
+	if !ok || base == "" {
+		return nil, false
+	}
+	return env.Get(base)
+}
@@ -0,0 +1,66 @@
+// evaluator/with.go
+
+package evaluator
+
+import (
+	"renelle/ast"
+	"renelle/constants"
+	"renelle/object"
+)
+
+// evalWith runs the statements of a with expression in order. Each match
+// step binds its pattern for the statements after it; the first value that
+// does not match is handed to the else clauses, or is the result if there
+// are none. eval evaluates the last statement and the chosen clause, so that
+// evalTail can keep them in tail position.
+func evalWith(node *ast.WithExpression, env *object.Environment, ctx *object.EvalContext, eval func(ast.Node, *object.Environment, *object.EvalContext) object.Object) object.Object {
+	bodyEnv := object.NewEnclosedEnvironment(env)
+	var result object.Object = constants.NIL
+
+	stmts := node.Body.Statements
+	for i, statement := range stmts {
+		step, ok := statement.(*ast.MatchStep)
+		if !ok {
+			if i == len(stmts)-1 {
+				return eval(statement, bodyEnv, ctx)
+			}
+			result = Eval(statement, bodyEnv, ctx)
+			if result != nil {
+				rt := result.Type()
+				if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+					return result
+				}
+			}
+			continue
+		}
+
+		value := Eval(step.Value, bodyEnv, ctx)
+		if value != nil {
+			rt := value.Type()
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return value
+			}
+		}
+
+		stepEnv, err := matchClause(step.Token, step.Pattern, value, bodyEnv, ctx)
+		if err != nil {
+			return err
+		}
+		if stepEnv == nil {
+			if node.Conditions == nil {
+				return value
+			}
+			ctx.Line = node.Token.Line
+			ctx.Column = node.Token.Column
+			branch, branchEnv, err := matchClauses(node.Token, node.Conditions, node.Guards, node.Consequences, value, env, ctx)
+			if err != nil {
+				return err
+			}
+			return eval(branch, branchEnv, ctx)
+		}
+		bodyEnv = stepEnv
+		result = value
+	}
+
+	return result
+}
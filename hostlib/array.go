@@ -3,19 +3,807 @@
 package hostlib
 
 import (
+	"cmp"
 	"slices"
+	"sort"
+	"strings"
 
+	"renelle/constants"
 	"renelle/object"
 )
 
+func init() {
+	Register("Array",
+		Function{Name: "all?", Arities: []int{2}, Fn: ArrayAll,
+			Doc: "Reports whether the predicate is true for every element."},
+		Function{Name: "any?", Arities: []int{2}, Fn: ArrayAny,
+			Doc: "Reports whether the predicate is true for some element."},
+		Function{Name: "choose", Arities: []int{2}, Fn: ArrayChoose,
+			Doc: "Applies a function returning (:some value) or :none to each element and returns the values."},
+		Function{Name: "chunk", Arities: []int{2}, Fn: ArrayChunk,
+			Doc: "Splits the array into arrays of the given size; the last may be shorter."},
+		Function{Name: "concat", Arities: []int{2}, Fn: ArrayConcat,
+			Doc: "Returns the elements of the first array followed by those of the second."},
+		Function{Name: "contains?", Arities: []int{2}, Fn: ArrayContains,
+			Doc: "Reports whether the array has an element equal to the value."},
+		Function{Name: "drop", Arities: []int{2}, Fn: ArrayDrop,
+			Doc: "Returns the array without its first n elements."},
+		Function{Name: "filter", Arities: []int{2}, Fn: ArrayFilter,
+			Doc: "Returns the elements the predicate is true for."},
+		Function{Name: "find", Arities: []int{2}, Fn: ArrayFind,
+			Doc: "Returns the first element the predicate is true for, or :nil."},
+		Function{Name: "find_index", Arities: []int{2}, Fn: ArrayFindIndex,
+			Doc: "Returns the index of the first element the predicate is true for, or :nil."},
+		Function{Name: "flat_map", Arities: []int{2}, Fn: ArrayFlatMap,
+			Doc: "Applies a function returning an array to each element and joins the results."},
+		Function{Name: "group_by", Arities: []int{2}, Fn: ArrayGroupBy,
+			Doc: "Returns a map from each key the function gives to the elements with that key."},
+		Function{Name: "join", Arities: []int{2}, Fn: ArrayJoin,
+			Doc: "Joins the elements into a string with the separator between them."},
+		Function{Name: "map", Arities: []int{2}, Fn: ArrayMap,
+			Doc: "Returns the results of applying the function to each element."},
+		Function{Name: "max", Arities: []int{1}, Fn: ArrayMax,
+			Doc: "Returns the largest element."},
+		Function{Name: "max_by", Arities: []int{2}, Fn: ArrayMaxBy,
+			Doc: "Returns the element for which the function gives the largest key."},
+		Function{Name: "min", Arities: []int{1}, Fn: ArrayMin,
+			Doc: "Returns the smallest element."},
+		Function{Name: "min_by", Arities: []int{2}, Fn: ArrayMinBy,
+			Doc: "Returns the element for which the function gives the smallest key."},
+		Function{Name: "quicksort", Arities: []int{1}, Fn: ArraySort,
+			Doc: "Same as sort."},
+		Function{Name: "quicksort_by", Arities: []int{2}, Fn: ArraySortBy,
+			Doc: "Same as sort_by."},
+		Function{Name: "range", Arities: []int{1, 2}, Fn: ArrayRange,
+			Doc: "Returns the range of integers from 0, or the first argument, up to but not including the last."},
+		Function{Name: "replace_at", Arities: []int{3}, Fn: ArrayReplaceAt,
+			Doc: "Returns the array with the element at the index replaced by the value."},
+		Function{Name: "reverse", Arities: []int{1}, Fn: ArrayReverse,
+			Doc: "Returns the array in reverse order."},
+		Function{Name: "sort", Arities: []int{1}, Fn: ArraySort,
+			Doc: "Returns the elements in ascending order, keeping equal elements in their original order."},
+		Function{Name: "sort_by", Arities: []int{2}, Fn: ArraySortBy,
+			Doc: "Returns the elements in ascending order of the keys the function gives."},
+		Function{Name: "sort_with", Arities: []int{2}, Fn: ArraySortWith,
+			Doc: "Returns the elements ordered by a comparator returning a negative, zero or positive integer."},
+		Function{Name: "sum", Arities: []int{1}, Fn: ArraySum,
+			Doc: "Returns the sum of the numbers in the array."},
+		Function{Name: "take", Arities: []int{2}, Fn: ArrayTake,
+			Doc: "Returns the first n elements."},
+		Function{Name: "take_while", Arities: []int{2}, Fn: ArrayTakeWhile,
+			Doc: "Returns the elements before the first one the predicate is false for."},
+		Function{Name: "try_find", Arities: []int{2}, Fn: ArrayTryFind,
+			Doc: "Returns (:some element) for the first element the predicate is true for, or :none."},
+		Function{Name: "uniq", Arities: []int{1}, Fn: ArrayUniq,
+			Doc: "Returns the array without repeated elements, keeping the first of each."},
+		Function{Name: "with_index", Arities: []int{1}, Fn: ArrayWithIndex,
+			Doc: "Returns (index element) tuples for each element."},
+		Function{Name: "zip", Arities: []int{2}, Fn: ArrayZip,
+			Doc: "Pairs up the elements of two arrays in tuples, stopping at the shorter one."},
+		Function{Name: "zip_with", Arities: []int{3}, Fn: ArrayZipWith,
+			Doc: "Combines the elements of two arrays with a function, stopping at the shorter one."},
+	)
+}
+
+// arrayArg returns the array a function takes as its first argument, which
+// may also be given as a range of at most object.MaxRangeArray elements. Functions
+// that go through the elements in order use elementsArg instead.
+func arrayArg(ctx *object.EvalContext, name string, args []object.Object) (*object.Array, *object.Error) {
+	if len(args) == 0 {
+		return nil, newError(ctx, "%s() requires an array", name)
+	}
+	switch arg := args[0].(type) {
+	case *object.Array:
+		return arg, nil
+	case *object.Range:
+		arr, ok := arg.Array()
+		if !ok {
+			return nil, newError(ctx, "%s() cannot make an array of the range %s, which has more than %d elements", name, arg.Inspect(), object.MaxRangeArray)
+		}
+		return arr, nil
+	}
+	return nil, newError(ctx, "%s() requires an array, got %s", name, args[0].Type())
+}
+
+// elementsArg returns an iterator over the array or range a function takes
+// as its first argument, so that a range is never copied.
+func elementsArg(ctx *object.EvalContext, name string, args []object.Object) (object.Iterator, *object.Error) {
+	if len(args) == 0 {
+		return nil, newError(ctx, "%s() requires an array", name)
+	}
+	switch args[0].(type) {
+	case *object.Array, *object.Range:
+		next, _ := object.Iterate(args[0])
+		return next, nil
+	}
+	return nil, newError(ctx, "%s() requires an array, got %s", name, args[0].Type())
+}
+
+// countArg returns the non-negative count a function takes as its second
+// argument.
+func countArg(ctx *object.EvalContext, name string, args []object.Object) (int, *object.Error) {
+	n, ok := args[1].(*object.Integer)
+	if !ok || n.Value < 0 {
+		return 0, newError(ctx, "%s() requires a non-negative integer count", name)
+	}
+	return int(n.Value), nil
+}
+
+// call applies a Renelle function to args, reporting whether it returned a
+// value rather than an error.
+func call(ctx *object.EvalContext, fn object.Object, args ...object.Object) (object.Object, bool) {
+	result := ApplyFunction(fn, args, ctx)
+	if _, isErr := result.(*object.Error); isErr {
+		return result, false
+	}
+	return result, true
+}
+
+// compare orders two values: numbers by value, strings lexicographically, and
+// arrays and tuples element by element. ok is false if they have no order.
+func compare(a, b object.Object) (int, bool) {
+	switch a := a.(type) {
+	case *object.Integer:
+		switch b := b.(type) {
+		case *object.Integer:
+			return cmp.Compare(a.Value, b.Value), true
+		case *object.Float:
+			return cmp.Compare(float64(a.Value), b.Value), true
+		}
+	case *object.Float:
+		switch b := b.(type) {
+		case *object.Integer:
+			return cmp.Compare(a.Value, float64(b.Value)), true
+		case *object.Float:
+			return cmp.Compare(a.Value, b.Value), true
+		}
+	case *object.String:
+		if b, ok := b.(*object.String); ok {
+			return strings.Compare(a.Value, b.Value), true
+		}
+	case *object.Array:
+		if b, ok := b.(*object.Array); ok {
+			return compareElements(a.Elements, b.Elements)
+		}
+	case *object.Tuple:
+		if b, ok := b.(*object.Tuple); ok {
+			return compareElements(a.Elements, b.Elements)
+		}
+	}
+	return 0, false
+}
+
+func compareElements(a, b []object.Object) (int, bool) {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c, ok := compare(a[i], b[i]); !ok || c != 0 {
+			return c, ok
+		}
+	}
+	return cmp.Compare(len(a), len(b)), true
+}
+
+func unordered(ctx *object.EvalContext, name string, a, b object.Object) *object.Error {
+	return newError(ctx, "%s() cannot compare %s with %s", name, a.Type(), b.Type())
+}
+
+func ArrayMap(ctx *object.EvalContext, args ...object.Object) object.Object {
+	next, err := elementsArg(ctx, "map", args)
+	if err != nil {
+		return err
+	}
+
+	result := []object.Object{}
+	for el, ok := next(ctx); ok; el, ok = next(ctx) {
+		value, ok := call(ctx, args[1], el)
+		if !ok {
+			return value
+		}
+		result = append(result, value)
+	}
+	return &object.Array{Elements: result}
+}
+
+func ArrayFilter(ctx *object.EvalContext, args ...object.Object) object.Object {
+	next, err := elementsArg(ctx, "filter", args)
+	if err != nil {
+		return err
+	}
+
+	result := []object.Object{}
+	for el, ok := next(ctx); ok; el, ok = next(ctx) {
+		keep, ok := call(ctx, args[1], el)
+		if !ok {
+			return keep
+		}
+		if isTruthy(keep) {
+			result = append(result, el)
+		}
+	}
+	return &object.Array{Elements: result}
+}
+
+func ArrayChoose(ctx *object.EvalContext, args ...object.Object) object.Object {
+	next, err := elementsArg(ctx, "choose", args)
+	if err != nil {
+		return err
+	}
+
+	result := []object.Object{}
+	for el, ok := next(ctx); ok; el, ok = next(ctx) {
+		value, ok := call(ctx, args[1], el)
+		if !ok {
+			return value
+		}
+		if object.Equals(value, constants.NONE) {
+			continue
+		}
+		some, ok := value.(*object.Tuple)
+		if !ok || len(some.Elements) != 2 || !object.Equals(some.Elements[0], constants.SOME) {
+			return newError(ctx, "choose() requires the function to return (:some value) or :none, got %s", value.Inspect())
+		}
+		result = append(result, some.Elements[1])
+	}
+	return &object.Array{Elements: result}
+}
+
+func ArrayFlatMap(ctx *object.EvalContext, args ...object.Object) object.Object {
+	next, err := elementsArg(ctx, "flat_map", args)
+	if err != nil {
+		return err
+	}
+
+	result := []object.Object{}
+	for el, ok := next(ctx); ok; el, ok = next(ctx) {
+		value, ok := call(ctx, args[1], el)
+		if !ok {
+			return value
+		}
+		inner, err := elementsArg(ctx, "flat_map", []object.Object{value})
+		if err != nil {
+			return newError(ctx, "flat_map() requires the function to return an array, got %s", value.Type())
+		}
+		for el, ok := inner(ctx); ok; el, ok = inner(ctx) {
+			result = append(result, el)
+		}
+	}
+	return &object.Array{Elements: result}
+}
+
+// findIndex returns the index of the first element the predicate is true
+// for and the element, or -1, along with any error the predicate raised.
+func findIndex(ctx *object.EvalContext, next object.Iterator, predicate object.Object) (int, object.Object, object.Object) {
+	i := 0
+	for el, ok := next(ctx); ok; el, ok = next(ctx) {
+		found, ok := call(ctx, predicate, el)
+		if !ok {
+			return -1, nil, found
+		}
+		if isTruthy(found) {
+			return i, el, nil
+		}
+		i++
+	}
+	return -1, nil, nil
+}
+
+func ArrayFind(ctx *object.EvalContext, args ...object.Object) object.Object {
+	next, err := elementsArg(ctx, "find", args)
+	if err != nil {
+		return err
+	}
+
+	i, el, fnErr := findIndex(ctx, next, args[1])
+	switch {
+	case fnErr != nil:
+		return fnErr
+	case i < 0:
+		return constants.NIL
+	}
+	return el
+}
+
+func ArrayFindIndex(ctx *object.EvalContext, args ...object.Object) object.Object {
+	next, err := elementsArg(ctx, "find_index", args)
+	if err != nil {
+		return err
+	}
+
+	i, _, fnErr := findIndex(ctx, next, args[1])
+	switch {
+	case fnErr != nil:
+		return fnErr
+	case i < 0:
+		return constants.NIL
+	}
+	return &object.Integer{Value: int64(i)}
+}
+
+func ArrayTryFind(ctx *object.EvalContext, args ...object.Object) object.Object {
+	next, err := elementsArg(ctx, "try_find", args)
+	if err != nil {
+		return err
+	}
+
+	i, el, fnErr := findIndex(ctx, next, args[1])
+	switch {
+	case fnErr != nil:
+		return fnErr
+	case i < 0:
+		return constants.NONE
+	}
+	return &object.Tuple{Elements: []object.Object{constants.SOME, el}}
+}
+
+func ArrayAny(ctx *object.EvalContext, args ...object.Object) object.Object {
+	next, err := elementsArg(ctx, "any?", args)
+	if err != nil {
+		return err
+	}
+
+	i, _, fnErr := findIndex(ctx, next, args[1])
+	if fnErr != nil {
+		return fnErr
+	}
+	return nativeBool(i >= 0)
+}
+
+func ArrayAll(ctx *object.EvalContext, args ...object.Object) object.Object {
+	next, err := elementsArg(ctx, "all?", args)
+	if err != nil {
+		return err
+	}
+
+	for el, ok := next(ctx); ok; el, ok = next(ctx) {
+		value, ok := call(ctx, args[1], el)
+		if !ok {
+			return value
+		}
+		if !isTruthy(value) {
+			return constants.FALSE
+		}
+	}
+	return constants.TRUE
+}
+
+func ArrayContains(ctx *object.EvalContext, args ...object.Object) object.Object {
+	if r, ok := args[0].(*object.Range); ok {
+		n, ok := args[1].(*object.Integer)
+		return nativeBool(ok && r.Contains(n.Value))
+	}
+
+	arr, err := arrayArg(ctx, "contains?", args)
+	if err != nil {
+		return err
+	}
+
+	return nativeBool(slices.ContainsFunc(arr.Elements, func(el object.Object) bool {
+		return object.Equals(el, args[1])
+	}))
+}
+
+func ArrayConcat(ctx *object.EvalContext, args ...object.Object) object.Object {
+	arr, err := arrayArg(ctx, "concat", args)
+	if err != nil {
+		return err
+	}
+	other, err := arrayArg(ctx, "concat", args[1:])
+	if err != nil {
+		return newError(ctx, "concat() requires two arrays, got %s", args[1].Type())
+	}
+
+	return arr.Append(other.Elements...)
+}
+
+func ArrayJoin(ctx *object.EvalContext, args ...object.Object) object.Object {
+	next, err := elementsArg(ctx, "join", args)
+	if err != nil {
+		return err
+	}
+	sep, ok := args[1].(*object.String)
+	if !ok {
+		return newError(ctx, "join() requires a string separator, got %s", args[1].Type())
+	}
+
+	parts := []string{}
+	for el, ok := next(ctx); ok; el, ok = next(ctx) {
+		if s, ok := el.(*object.String); ok {
+			parts = append(parts, s.Value)
+		} else {
+			parts = append(parts, el.Inspect())
+		}
+	}
+	return &object.String{Value: strings.Join(parts, sep.Value)}
+}
+
+func ArraySum(ctx *object.EvalContext, args ...object.Object) object.Object {
+	next, err := elementsArg(ctx, "sum", args)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	var ftotal float64
+	isFloat := false
+	for el, ok := next(ctx); ok; el, ok = next(ctx) {
+		switch n := el.(type) {
+		case *object.Integer:
+			total += n.Value
+		case *object.Float:
+			ftotal += n.Value
+			isFloat = true
+		default:
+			return newError(ctx, "sum() requires an array of numbers, got %s", el.Type())
+		}
+	}
+
+	if isFloat {
+		return &object.Float{Value: float64(total) + ftotal}
+	}
+	return &object.Integer{Value: total}
+}
+
+// extreme returns the element with the largest key if sign is 1, or the
+// smallest if it is -1, keeping the first of equal keys. by computes the key
+// of each element once; it is nil for the elements themselves.
+func extreme(ctx *object.EvalContext, name string, args []object.Object, by object.Object, sign int) object.Object {
+	next, err := elementsArg(ctx, name, args)
+	if err != nil {
+		return err
+	}
+
+	var best, bestKey object.Object
+	for el, ok := next(ctx); ok; el, ok = next(ctx) {
+		key := el
+		if by != nil {
+			if key, ok = call(ctx, by, el); !ok {
+				return key
+			}
+		}
+		if best == nil {
+			best, bestKey = el, key
+			continue
+		}
+		c, ok := compare(key, bestKey)
+		if !ok {
+			return unordered(ctx, name, key, bestKey)
+		}
+		if c*sign > 0 {
+			best, bestKey = el, key
+		}
+	}
+	if best == nil {
+		return newError(ctx, "%s() requires a non-empty array", name)
+	}
+	return best
+}
+
+func ArrayMax(ctx *object.EvalContext, args ...object.Object) object.Object {
+	return extreme(ctx, "max", args, nil, 1)
+}
+
+func ArrayMaxBy(ctx *object.EvalContext, args ...object.Object) object.Object {
+	return extreme(ctx, "max_by", args, args[1], 1)
+}
+
+func ArrayMin(ctx *object.EvalContext, args ...object.Object) object.Object {
+	return extreme(ctx, "min", args, nil, -1)
+}
+
+func ArrayMinBy(ctx *object.EvalContext, args ...object.Object) object.Object {
+	return extreme(ctx, "min_by", args, args[1], -1)
+}
+
+// sortByKeys stably sorts the elements of arr by the matching keys.
+func sortByKeys(ctx *object.EvalContext, name string, arr *object.Array, keys []object.Object) object.Object {
+	order := make([]int, len(arr.Elements))
+	for i := range order {
+		order[i] = i
+	}
+
+	var sortErr *object.Error
+	sort.SliceStable(order, func(i, j int) bool {
+		if sortErr != nil {
+			return false
+		}
+		a, b := keys[order[i]], keys[order[j]]
+		c, ok := compare(a, b)
+		if !ok {
+			sortErr = unordered(ctx, name, a, b)
+		}
+		return c < 0
+	})
+	if sortErr != nil {
+		return sortErr
+	}
+
+	result := make([]object.Object, len(order))
+	for i, j := range order {
+		result[i] = arr.Elements[j]
+	}
+	return &object.Array{Elements: result}
+}
+
+func ArraySort(ctx *object.EvalContext, args ...object.Object) object.Object {
+	arr, err := arrayArg(ctx, "sort", args)
+	if err != nil {
+		return err
+	}
+
+	return sortByKeys(ctx, "sort", arr, arr.Elements)
+}
+
+func ArraySortBy(ctx *object.EvalContext, args ...object.Object) object.Object {
+	arr, err := arrayArg(ctx, "sort_by", args)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]object.Object, len(arr.Elements))
+	for i, el := range arr.Elements {
+		key, ok := call(ctx, args[1], el)
+		if !ok {
+			return key
+		}
+		keys[i] = key
+	}
+	return sortByKeys(ctx, "sort_by", arr, keys)
+}
+
+func ArraySortWith(ctx *object.EvalContext, args ...object.Object) object.Object {
+	arr, err := arrayArg(ctx, "sort_with", args)
+	if err != nil {
+		return err
+	}
+
+	result := make([]object.Object, len(arr.Elements))
+	copy(result, arr.Elements)
+
+	var sortErr object.Object
+	sort.SliceStable(result, func(i, j int) bool {
+		if sortErr != nil {
+			return false
+		}
+		value, ok := call(ctx, args[1], result[i], result[j])
+		if !ok {
+			sortErr = value
+			return false
+		}
+		c, ok := value.(*object.Integer)
+		if !ok {
+			sortErr = newError(ctx, "sort_with() requires the comparator to return an integer, got %s", value.Type())
+			return false
+		}
+		return c.Value < 0
+	})
+	if sortErr != nil {
+		return sortErr
+	}
+	return &object.Array{Elements: result}
+}
+
+func ArrayUniq(ctx *object.EvalContext, args ...object.Object) object.Object {
+	next, err := elementsArg(ctx, "uniq", args)
+	if err != nil {
+		return err
+	}
+
+	// hashable elements are looked up by hash, the rest compared one by one
+	seen := map[object.HashKey][]object.Object{}
+	var unhashable []object.Object
+	result := []object.Object{}
+	for el, ok := next(ctx); ok; el, ok = next(ctx) {
+		candidates := unhashable
+		var key object.HashKey
+		h, hashable := el.(object.Hashable)
+		if hashable {
+			key = h.HashKey()
+			candidates = seen[key]
+		}
+		if slices.ContainsFunc(candidates, func(other object.Object) bool { return object.Equals(el, other) }) {
+			continue
+		}
+		if hashable {
+			seen[key] = append(seen[key], el)
+		} else {
+			unhashable = append(unhashable, el)
+		}
+		result = append(result, el)
+	}
+	return &object.Array{Elements: result}
+}
+
+func ArrayGroupBy(ctx *object.EvalContext, args ...object.Object) object.Object {
+	next, err := elementsArg(ctx, "group_by", args)
+	if err != nil {
+		return err
+	}
+
+	groups := &object.Map{}
+	for el, ok := next(ctx); ok; el, ok = next(ctx) {
+		key, ok := call(ctx, args[1], el)
+		if !ok {
+			return key
+		}
+		if _, ok := key.(object.Hashable); !ok {
+			return newError(ctx, "group_by() requires the function to return a hashable key, got %s", key.Type())
+		}
+		group, ok := groups.Get(key)
+		if !ok {
+			group = &object.Array{Elements: []object.Object{}}
+		}
+		groups.Put(key, group.(*object.Array).Append(el))
+	}
+	return groups
+}
+
+func ArrayChunk(ctx *object.EvalContext, args ...object.Object) object.Object {
+	next, err := elementsArg(ctx, "chunk", args)
+	if err != nil {
+		return err
+	}
+	size, ok := args[1].(*object.Integer)
+	if !ok || size.Value < 1 {
+		return newError(ctx, "chunk() requires a positive integer size")
+	}
+
+	chunks := []object.Object{}
+	var chunk []object.Object
+	for el, ok := next(ctx); ok; el, ok = next(ctx) {
+		chunk = append(chunk, el)
+		if int64(len(chunk)) == size.Value {
+			chunks = append(chunks, &object.Array{Elements: chunk})
+			chunk = nil
+		}
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, &object.Array{Elements: chunk})
+	}
+	return &object.Array{Elements: chunks}
+}
+
+func ArrayTake(ctx *object.EvalContext, args ...object.Object) object.Object {
+	if arr, ok := args[0].(*object.Array); ok {
+		n, err := countArg(ctx, "take", args)
+		if err != nil {
+			return err
+		}
+		n = min(n, len(arr.Elements))
+		return &object.Array{Elements: arr.Elements[:n:n]}
+	}
+
+	next, err := elementsArg(ctx, "take", args)
+	if err != nil {
+		return err
+	}
+	n, err := countArg(ctx, "take", args)
+	if err != nil {
+		return err
+	}
+
+	result := []object.Object{}
+	for el, ok := next(ctx); ok && len(result) < n; el, ok = next(ctx) {
+		result = append(result, el)
+	}
+	return &object.Array{Elements: result}
+}
+
+func ArrayDrop(ctx *object.EvalContext, args ...object.Object) object.Object {
+	arr, err := arrayArg(ctx, "drop", args)
+	if err != nil {
+		return err
+	}
+	n, err := countArg(ctx, "drop", args)
+	if err != nil {
+		return err
+	}
+
+	n = min(n, len(arr.Elements))
+	return &object.Array{Elements: arr.Elements[n:]}
+}
+
+func ArrayTakeWhile(ctx *object.EvalContext, args ...object.Object) object.Object {
+	next, err := elementsArg(ctx, "take_while", args)
+	if err != nil {
+		return err
+	}
+
+	result := []object.Object{}
+	for el, ok := next(ctx); ok; el, ok = next(ctx) {
+		keep, ok := call(ctx, args[1], el)
+		if !ok {
+			return keep
+		}
+		if !isTruthy(keep) {
+			break
+		}
+		result = append(result, el)
+	}
+	return &object.Array{Elements: result}
+}
+
+func ArrayReplaceAt(ctx *object.EvalContext, args ...object.Object) object.Object {
+	arr, err := arrayArg(ctx, "replace_at", args)
+	if err != nil {
+		return err
+	}
+	index, ok := args[1].(*object.Integer)
+	if !ok {
+		return newError(ctx, "replace_at() requires an integer index, got %s", args[1].Type())
+	}
+
+	result := make([]object.Object, len(arr.Elements))
+	copy(result, arr.Elements)
+	if index.Value >= 0 && index.Value < int64(len(result)) {
+		result[index.Value] = args[2]
+	}
+	return &object.Array{Elements: result}
+}
+
+func ArrayWithIndex(ctx *object.EvalContext, args ...object.Object) object.Object {
+	next, err := elementsArg(ctx, "with_index", args)
+	if err != nil {
+		return err
+	}
+
+	result := []object.Object{}
+	for el, ok := next(ctx); ok; el, ok = next(ctx) {
+		i := &object.Integer{Value: int64(len(result))}
+		result = append(result, &object.Tuple{Elements: []object.Object{i, el}})
+	}
+	return &object.Array{Elements: result}
+}
+
+// zipWith combines the elements of two arrays or ranges pairwise with
+// combine, up to the length of the shorter one.
+func zipWith(ctx *object.EvalContext, name string, args []object.Object, combine func(a, b object.Object) (object.Object, bool)) object.Object {
+	next, err := elementsArg(ctx, name, args)
+	if err != nil {
+		return err
+	}
+	otherNext, err := elementsArg(ctx, name, args[1:])
+	if err != nil {
+		return newError(ctx, "%s() requires two arrays, got %s", name, args[1].Type())
+	}
+
+	result := []object.Object{}
+	for {
+		a, ok := next(ctx)
+		if !ok {
+			break
+		}
+		b, ok := otherNext(ctx)
+		if !ok {
+			break
+		}
+		value, ok := combine(a, b)
+		if !ok {
+			return value
+		}
+		result = append(result, value)
+	}
+	return &object.Array{Elements: result}
+}
+
+func ArrayZip(ctx *object.EvalContext, args ...object.Object) object.Object {
+	return zipWith(ctx, "zip", args, func(a, b object.Object) (object.Object, bool) {
+		return &object.Tuple{Elements: []object.Object{a, b}}, true
+	})
+}
+
+func ArrayZipWith(ctx *object.EvalContext, args ...object.Object) object.Object {
+	return zipWith(ctx, "zip_with", args, func(a, b object.Object) (object.Object, bool) {
+		return call(ctx, args[2], a, b)
+	})
+}
+
 func ArrayReverse(ctx *object.EvalContext, args ...object.Object) object.Object {
 	if len(args) != 1 {
 		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "reverse() takes exactly 1 argument"}
 	}
 
-	arr, ok := args[0].(*object.Array)
-	if !ok {
-		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "reverse() requires an array"}
+	arr, err := arrayArg(ctx, "reverse", args)
+	if err != nil {
+		return err
 	}
 
 	el := arr.Elements
@@ -54,14 +842,7 @@ func ArrayRange(ctx *object.EvalContext, args ...object.Object) object.Object {
 	}
 
 	if start >= stop {
-		return &object.Array{Elements: []object.Object{}}
+		return &object.Range{First: 0, Last: -1, Step: 1}
 	}
-
-	elements := make([]object.Object, stop-start)
-	for i := start; i < stop; i++ {
-		elements[i-start] = &object.Integer{Value: i}
-	}
-
-	return &object.Array{Elements: elements}
-
+	return &object.Range{First: start, Last: stop - 1, Step: 1}
 }
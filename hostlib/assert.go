@@ -0,0 +1,392 @@
+// hostlib/assert.go
+
+package hostlib
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"renelle/constants"
+	"renelle/object"
+)
+
+func init() {
+	Register("Assert",
+		Function{Name: "approx", Arities: []int{2, 3}, Fn: AssertApprox,
+			Doc: "Fails unless two numbers are within a delta of each other, 1e-9 by default."},
+		Function{Name: "contains", Arities: []int{2}, Fn: AssertContains,
+			Doc: "Fails unless an array, tuple, string or map contains the element."},
+		Function{Name: "equal", Arities: []int{2, 3}, Fn: AssertEqual,
+			Doc: "Fails unless the actual value equals the expected one."},
+		Function{Name: "like", Arities: []int{2, 3}, Fn: AssertLike,
+			Doc: "Fails unless the actual value equals the expected one, where the atom :_ stands for any value and maps may have more keys."},
+		Function{Name: "not_equal", Arities: []int{2, 3}, Fn: AssertNotEqual,
+			Doc: "Fails if the actual value equals the expected one."},
+		Function{Name: "raises", Arities: []int{1, 2}, Fn: AssertRaises,
+			Doc: "Calls the function and fails unless it returns an error."},
+	)
+}
+
+// assertion describes a failed assertion, so it can be rendered with the
+// values involved and the line of source that made it.
+type assertion struct {
+	name     string
+	message  string
+	expected object.Object
+	actual   object.Object
+	diff     []string
+}
+
+func (a *assertion) toError(ctx *object.EvalContext) *object.Error {
+	var out strings.Builder
+
+	out.WriteString(a.name + " failed")
+	if a.message != "" {
+		out.WriteString(": " + a.message)
+	}
+	if a.expected != nil {
+		out.WriteString("\n  expected: " + a.expected.Inspect())
+	}
+	if a.actual != nil {
+		out.WriteString("\n  actual:   " + a.actual.Inspect())
+	}
+	if len(a.diff) > 0 {
+		out.WriteString("\n  diff:")
+		for _, d := range a.diff {
+			out.WriteString("\n    " + d)
+		}
+	}
+	if line, ok := sourceLine(ctx.FileName, ctx.Line); ok {
+		out.WriteString(fmt.Sprintf("\n  source:\n    %d | %s", ctx.Line, line))
+	}
+
+	return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: out.String()}
+}
+
+// sourceLine returns the given 1-based line of a file, if it can be read.
+func sourceLine(filename string, line int) (string, bool) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return "", false
+	}
+
+	lines := strings.Split(string(content), "\n")
+	if line < 1 || line > len(lines) {
+		return "", false
+	}
+
+	return strings.TrimSpace(lines[line-1]), true
+}
+
+// assertMessage returns the optional message argument found at index i.
+func assertMessage(args []object.Object, i int) (string, bool) {
+	if len(args) <= i {
+		return "", true
+	}
+
+	msg, ok := args[i].(*object.String)
+	if !ok {
+		return "", false
+	}
+
+	return msg.Value, true
+}
+
+// AssertEqual fails unless the actual value equals the expected one.
+func AssertEqual(ctx *object.EvalContext, args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "equal() takes 2 or 3 arguments"}
+	}
+
+	msg, ok := assertMessage(args, 2)
+	if !ok {
+		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "equal() requires a string message"}
+	}
+
+	actual, expected := args[0], args[1]
+	if object.Equals(actual, expected) {
+		return constants.OK
+	}
+
+	failure := &assertion{name: "Assert.equal", message: msg, expected: expected, actual: actual, diff: diff(expected, actual)}
+	return failure.toError(ctx)
+}
+
+// AssertNotEqual fails if the actual value equals the expected one.
+func AssertNotEqual(ctx *object.EvalContext, args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "not_equal() takes 2 or 3 arguments"}
+	}
+
+	msg, ok := assertMessage(args, 2)
+	if !ok {
+		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "not_equal() requires a string message"}
+	}
+
+	actual, expected := args[0], args[1]
+	if !object.Equals(actual, expected) {
+		return constants.OK
+	}
+
+	failure := &assertion{name: "Assert.not_equal", message: msg, actual: actual}
+	if failure.message == "" {
+		failure.message = "values are equal"
+	}
+	return failure.toError(ctx)
+}
+
+// AssertLike fails unless the actual value equals the expected one, where the
+// atom :_ stands for any value. Tuples and arrays are compared element by
+// element, and a map is like another when every key of the expected one is
+// present with a value like its own. This is equality, not pattern matching:
+// nothing is bound, and there are no rest, pin or guard patterns.
+func AssertLike(ctx *object.EvalContext, args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "like() takes 2 or 3 arguments"}
+	}
+
+	msg, ok := assertMessage(args, 2)
+	if !ok {
+		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "like() requires a string message"}
+	}
+
+	actual, expected := args[0], args[1]
+	if like(actual, expected) {
+		return constants.OK
+	}
+
+	failure := &assertion{name: "Assert.like", message: msg, expected: expected, actual: actual, diff: diff(expected, actual)}
+	return failure.toError(ctx)
+}
+
+// AssertRaises calls the given function and fails unless it returns an error.
+// If a message is given, the error message must contain it.
+func AssertRaises(ctx *object.EvalContext, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "raises() takes 1 or 2 arguments"}
+	}
+
+	switch args[0].(type) {
+	case *object.Function, *object.Overloads, *object.Partial, *object.Builtin, object.Callable:
+	default:
+		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "raises() requires a function"}
+	}
+
+	expected, ok := assertMessage(args, 1)
+	if !ok {
+		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "raises() requires a string message"}
+	}
+
+	// calling back into Renelle moves the context, so keep the call site for the report
+	callSite := *ctx
+	result := ApplyFunction(args[0], []object.Object{}, ctx)
+	ctx.FileName, ctx.Line, ctx.Column = callSite.FileName, callSite.Line, callSite.Column
+
+	err, ok := result.(*object.Error)
+	if !ok {
+		failure := &assertion{name: "Assert.raises", message: "expected an error", actual: result}
+		return failure.toError(ctx)
+	}
+
+	if !strings.Contains(err.Message, expected) {
+		failure := &assertion{
+			name:     "Assert.raises",
+			message:  "error message did not match",
+			expected: &object.String{Value: expected},
+			actual:   &object.String{Value: err.Message},
+		}
+		return failure.toError(ctx)
+	}
+
+	return constants.OK
+}
+
+// AssertApprox fails unless two numbers are within delta of each other.
+func AssertApprox(ctx *object.EvalContext, args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "approx() takes 2 or 3 arguments"}
+	}
+
+	actual, ok := toFloat(args[0])
+	if !ok {
+		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "approx() requires numbers"}
+	}
+
+	expected, ok := toFloat(args[1])
+	if !ok {
+		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "approx() requires numbers"}
+	}
+
+	delta := 1e-9
+	if len(args) == 3 {
+		delta, ok = toFloat(args[2])
+		if !ok {
+			return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "approx() requires a numeric delta"}
+		}
+	}
+
+	difference := math.Abs(actual - expected)
+	if difference <= delta {
+		return constants.OK
+	}
+
+	failure := &assertion{
+		name:     "Assert.approx",
+		expected: args[1],
+		actual:   args[0],
+		diff:     []string{fmt.Sprintf("difference %g is greater than %g", difference, delta)},
+	}
+	return failure.toError(ctx)
+}
+
+// AssertContains fails unless the collection contains the element. Arrays and
+// tuples are searched for an equal element, strings for a substring and maps
+// for a key.
+func AssertContains(ctx *object.EvalContext, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "contains() takes exactly 2 arguments"}
+	}
+
+	collection, element := args[0], args[1]
+	found := false
+
+	switch collection := collection.(type) {
+	case *object.Array:
+		found = containsElement(collection.Elements, element)
+	case *object.Tuple:
+		found = containsElement(collection.Elements, element)
+	case *object.String:
+		substr, ok := element.(*object.String)
+		if !ok {
+			return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "contains() requires a string to search a string"}
+		}
+		found = strings.Contains(collection.Value, substr.Value)
+	case *object.Map:
+		_, found = collection.Get(element)
+	default:
+		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "contains() requires an array, tuple, string or map"}
+	}
+
+	if found {
+		return constants.OK
+	}
+
+	failure := &assertion{name: "Assert.contains", message: "element not found", expected: element, actual: collection}
+	return failure.toError(ctx)
+}
+
+func containsElement(elements []object.Object, element object.Object) bool {
+	for _, el := range elements {
+		if object.Equals(el, element) {
+			return true
+		}
+	}
+	return false
+}
+
+func like(actual, expected object.Object) bool {
+	switch expected := expected.(type) {
+	case *object.Atom:
+		return expected.Value == "_" || object.Equals(actual, expected)
+	case *object.Tuple:
+		tuple, ok := actual.(*object.Tuple)
+		return ok && allLike(tuple.Elements, expected.Elements)
+	case *object.Array:
+		array, ok := actual.(*object.Array)
+		return ok && allLike(array.Elements, expected.Elements)
+	case *object.Map:
+		m, ok := actual.(*object.Map)
+		if !ok {
+			return false
+		}
+		for _, key := range expected.Keys() {
+			want, _ := expected.Get(key)
+			got, ok := m.Get(key)
+			if !ok || !like(got, want) {
+				return false
+			}
+		}
+		return true
+	default:
+		return object.Equals(actual, expected)
+	}
+}
+
+func allLike(actual, expected []object.Object) bool {
+	if len(actual) != len(expected) {
+		return false
+	}
+	for i := range expected {
+		if !like(actual[i], expected[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// diff describes how two arrays, tuples or maps differ, one line per element.
+func diff(expected, actual object.Object) []string {
+	switch expected := expected.(type) {
+	case *object.Array:
+		if actual, ok := actual.(*object.Array); ok {
+			return diffElements(expected.Elements, actual.Elements)
+		}
+	case *object.Tuple:
+		if actual, ok := actual.(*object.Tuple); ok {
+			return diffElements(expected.Elements, actual.Elements)
+		}
+	case *object.Map:
+		if actual, ok := actual.(*object.Map); ok {
+			return diffMaps(expected, actual)
+		}
+	}
+	return nil
+}
+
+func diffElements(expected, actual []object.Object) []string {
+	lines := []string{}
+	for i := 0; i < len(expected) || i < len(actual); i++ {
+		switch {
+		case i >= len(actual):
+			lines = append(lines, fmt.Sprintf("@%d: missing %s", i, expected[i].Inspect()))
+		case i >= len(expected):
+			lines = append(lines, fmt.Sprintf("@%d: unexpected %s", i, actual[i].Inspect()))
+		case !like(actual[i], expected[i]):
+			lines = append(lines, fmt.Sprintf("@%d: expected %s, got %s", i, expected[i].Inspect(), actual[i].Inspect()))
+		}
+	}
+	return lines
+}
+
+func diffMaps(expected, actual *object.Map) []string {
+	lines := []string{}
+	for _, key := range expected.Keys() {
+		want, _ := expected.Get(key)
+		got, ok := actual.Get(key)
+		if !ok {
+			lines = append(lines, fmt.Sprintf("%s: missing, expected %s", key.Inspect(), want.Inspect()))
+		} else if !like(got, want) {
+			lines = append(lines, fmt.Sprintf("%s: expected %s, got %s", key.Inspect(), want.Inspect(), got.Inspect()))
+		}
+	}
+	for _, key := range actual.Keys() {
+		if _, ok := expected.Get(key); !ok {
+			got, _ := actual.Get(key)
+			lines = append(lines, fmt.Sprintf("%s: unexpected %s", key.Inspect(), got.Inspect()))
+		}
+	}
+	return lines
+}
+
+func toFloat(obj object.Object) (float64, bool) {
+	switch num := obj.(type) {
+	case *object.Integer:
+		return float64(num.Value), true
+	case *object.Float:
+		return num.Value, true
+	default:
+		return 0, false
+	}
+}
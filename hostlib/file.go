@@ -9,6 +9,19 @@ import (
 	"renelle/object"
 )
 
+func init() {
+	Register("File",
+		Function{Name: "open", Arities: []int{1}, Fn: FileOpen,
+			Doc: "Reads a file, returning (:ok contents) or (:error message)."},
+		Function{Name: "open!", Arities: []int{1}, Fn: FileOpenBang,
+			Doc: "Reads a file, raising an error if it cannot be read."},
+		Function{Name: "write", Arities: []int{2}, Fn: FileWrite,
+			Doc: "Writes a string to a path, returning (:ok :nil) or (:error message)."},
+		Function{Name: "write!", Arities: []int{2}, Fn: FileWriteBang,
+			Doc: "Writes a string to a path, raising an error if it cannot be written."},
+	)
+}
+
 func FileOpen(ctx *object.EvalContext, args ...object.Object) object.Object {
 	if len(args) != 1 {
 		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "open() takes exactly 1 argument"}
@@ -0,0 +1,71 @@
+// hostlib/function.go
+
+package hostlib
+
+import (
+	"renelle/object"
+)
+
+func init() {
+	Register("Function",
+		Function{Name: "curry", Arities: []int{1, 2}, Fn: FunctionCurry,
+			Doc: "Returns the function taking its arguments one or more at a time, calling it once it has them all."},
+		Function{Name: "partial", Fn: FunctionPartial,
+			Doc: "Returns the function with its first arguments given, taking the rest."},
+	)
+}
+
+// FunctionPartial gives a function its first arguments, returning a function
+// that takes the rest, so `Function.partial(add 1)` is `add(1 _)`.
+func FunctionPartial(ctx *object.EvalContext, args ...object.Object) object.Object {
+	if len(args) == 0 {
+		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "partial() takes 1 or more arguments"}
+	}
+	if !isFunction(args[0]) {
+		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "partial() requires a function"}
+	}
+
+	given := make([]object.Object, len(args)-1)
+	copy(given, args[1:])
+	return &object.Partial{Fn: args[0], Args: given}
+}
+
+// FunctionCurry returns a function gathering the arguments of a function of
+// the given arity, or the number of arguments it requires, over as many calls
+// as they are given in. It calls the function once it has them all.
+func FunctionCurry(ctx *object.EvalContext, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "curry() takes 1 or 2 arguments"}
+	}
+	if !isFunction(args[0]) {
+		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "curry() requires a function"}
+	}
+
+	var arity int
+	if len(args) == 2 {
+		n, ok := args[1].(*object.Integer)
+		if !ok || n.Value < 1 {
+			return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "curry() requires a positive arity"}
+		}
+		arity = int(n.Value)
+	} else {
+		switch args[0].(type) {
+		case *object.Function, *object.Closure:
+			arity, _ = object.ArityRange(args[0])
+		}
+		if arity < 1 {
+			return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "curry() can not tell how many arguments the function takes, give it as curry(f n)"}
+		}
+	}
+
+	return &object.Partial{Fn: args[0], Args: []object.Object{}, Curried: arity}
+}
+
+func isFunction(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Function, *object.Overloads, *object.Partial, *object.Builtin, object.Callable:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,37 @@
+// hostlib/hostlib.go
+
+package hostlib
+
+import (
+	"fmt"
+
+	"renelle/constants"
+	"renelle/object"
+)
+
+// ApplyFunction calls a Renelle function value from Go. It is set by the
+// evaluator at startup so host functions can call back into user code without
+// importing the evaluator.
+var ApplyFunction func(fn object.Object, args []object.Object, ctx *object.EvalContext) object.Object
+
+func newError(ctx *object.EvalContext, format string, a ...interface{}) *object.Error {
+	return &object.Error{
+		Message:  fmt.Sprintf(format, a...),
+		Line:     ctx.Line,
+		Column:   ctx.Column,
+		FileName: ctx.FileName,
+	}
+}
+
+// isTruthy reports whether a value returned by a predicate counts as true:
+// everything but false and :nil does.
+func isTruthy(obj object.Object) bool {
+	return obj != constants.FALSE && obj != constants.NIL
+}
+
+func nativeBool(b bool) *object.Boolean {
+	if b {
+		return constants.TRUE
+	}
+	return constants.FALSE
+}
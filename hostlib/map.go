@@ -7,6 +7,21 @@ import (
 	"renelle/object"
 )
 
+func init() {
+	Register("Map",
+		Function{Name: "get", Arities: []int{2}, Fn: MapGet,
+			Doc: "Returns the value for a key, or :nil."},
+		Function{Name: "has_key?", Arities: []int{2}, Fn: MapHasKey,
+			Doc: "Reports whether the map has the key."},
+		Function{Name: "keys", Arities: []int{1}, Fn: MapKeys,
+			Doc: "Returns the keys of the map."},
+		Function{Name: "length", Arities: []int{1}, Fn: MapLength,
+			Doc: "Returns the number of entries in the map."},
+		Function{Name: "try_get", Arities: []int{2}, Fn: MapTryGet,
+			Doc: "Returns (:some value) for a key, or :none."},
+	)
+}
+
 func MapHasKey(ctx *object.EvalContext, args ...object.Object) object.Object {
 	if len(args) != 2 {
 		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "haskey() takes exactly 2 arguments"}
@@ -19,7 +34,7 @@ func MapHasKey(ctx *object.EvalContext, args ...object.Object) object.Object {
 
 	key := args[1]
 
-	_, ok = m.Store.Get(key)
+	_, ok = m.Get(key)
 	if ok {
 		return constants.TRUE
 	}
@@ -37,7 +52,7 @@ func MapKeys(ctx *object.EvalContext, args ...object.Object) object.Object {
 		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "keys() requires a map"}
 	}
 
-	keys := m.Store.Keys()
+	keys := m.Keys()
 	arr := &object.Array{Elements: keys}
 
 	return arr
@@ -53,7 +68,7 @@ func MapLength(ctx *object.EvalContext, args ...object.Object) object.Object {
 		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "length() requires a map"}
 	}
 
-	return &object.Integer{Value: int64(m.Store.Length)}
+	return &object.Integer{Value: int64(m.Len())}
 }
 
 func MapGet(ctx *object.EvalContext, args ...object.Object) object.Object {
@@ -67,7 +82,7 @@ func MapGet(ctx *object.EvalContext, args ...object.Object) object.Object {
 	}
 
 	key := args[1]
-	value, ok := m.Store.Get(key)
+	value, ok := m.Get(key)
 	if !ok {
 		return constants.NIL
 	}
@@ -86,7 +101,7 @@ func MapTryGet(ctx *object.EvalContext, args ...object.Object) object.Object {
 	}
 
 	key := args[1]
-	value, ok := m.Store.Get(key)
+	value, ok := m.Get(key)
 	if !ok {
 		return constants.NONE
 	}
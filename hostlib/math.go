@@ -5,6 +5,33 @@ import (
 	"renelle/object"
 )
 
+func init() {
+	Register("Math",
+		Function{Name: "abs", Arities: []int{1}, Fn: MathAbs,
+			Doc: "Returns the absolute value of a number."},
+		Function{Name: "ceiling", Arities: []int{1}, Fn: MathCeil,
+			Doc: "Rounds a number up to an integer."},
+		Function{Name: "cos", Arities: []int{1}, Fn: MathCos,
+			Doc: "Returns the cosine of an angle in radians."},
+		Function{Name: "floor", Arities: []int{1}, Fn: MathFloor,
+			Doc: "Rounds a number down to an integer."},
+		Function{Name: "max", Arities: []int{2}, Fn: MathMax,
+			Doc: "Returns the larger of two numbers."},
+		Function{Name: "min", Arities: []int{2}, Fn: MathMin,
+			Doc: "Returns the smaller of two numbers."},
+		Function{Name: "pi", Arities: []int{0}, Fn: MathPi,
+			Doc: "Returns pi."},
+		Function{Name: "round", Arities: []int{1, 2}, Fn: MathRound,
+			Doc: "Rounds a number to the nearest integer, or to a number of decimal places."},
+		Function{Name: "sin", Arities: []int{1}, Fn: MathSin,
+			Doc: "Returns the sine of an angle in radians."},
+		Function{Name: "sqrt", Arities: []int{1}, Fn: MathSqrt,
+			Doc: "Returns the square root of a number."},
+		Function{Name: "tan", Arities: []int{1}, Fn: MathTan,
+			Doc: "Returns the tangent of an angle in radians."},
+	)
+}
+
 func MathAbs(ctx *object.EvalContext, args ...object.Object) object.Object {
 	if len(args) != 1 {
 		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: "abs() takes exactly 1 argument"}
@@ -0,0 +1,101 @@
+// hostlib/registry.go
+
+package hostlib
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"renelle/object"
+)
+
+// Function is a native function of a Renelle module.
+type Function struct {
+	Name    string
+	Arities []int // the numbers of arguments it takes, nil for any number
+	Doc     string
+	Fn      object.BuiltinFunction
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]map[string]Function{}
+)
+
+// Register adds native functions to the named module. The files of this
+// package register theirs in init, and programs embedding Renelle can add
+// their own at startup, before running any code. When the module is loaded
+// its native functions are set alongside those of its .rnl source, and a
+// module with no source is made of its native functions alone. A function
+// registered again under the same name replaces the earlier one.
+func Register(module string, fns ...Function) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if registry[module] == nil {
+		registry[module] = map[string]Function{}
+	}
+	for _, fn := range fns {
+		registry[module][fn.Name] = fn
+	}
+}
+
+// Module returns the native functions registered for a module, sorted by
+// name, and whether there are any.
+func Module(name string) ([]Function, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	fns := make([]Function, 0, len(registry[name]))
+	for _, fn := range registry[name] {
+		fns = append(fns, fn)
+	}
+	sort.Slice(fns, func(i, j int) bool { return fns[i].Name < fns[j].Name })
+	return fns, len(fns) > 0
+}
+
+// Lookup returns a module's native function by name.
+func Lookup(module, name string) (Function, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	fn, ok := registry[module][name]
+	return fn, ok
+}
+
+// Install sets a module's native functions in its environment.
+func Install(module string, env object.Env) {
+	fns, _ := Module(module)
+	for _, fn := range fns {
+		env.Set(fn.Name, fn.Builtin())
+	}
+}
+
+// Builtin returns the function as a builtin value, checking the number of
+// arguments it is called with.
+func (f Function) Builtin() *object.Builtin {
+	if f.Arities == nil {
+		return &object.Builtin{Fn: f.Fn}
+	}
+
+	return &object.Builtin{Fn: func(ctx *object.EvalContext, args ...object.Object) object.Object {
+		for _, n := range f.Arities {
+			if len(args) == n {
+				return f.Fn(ctx, args...)
+			}
+		}
+		return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column,
+			Message: fmt.Sprintf("wrong number of arguments. got=%d, want=%s", len(args), f.arityString())}
+	}}
+}
+
+func (f Function) arityString() string {
+	want := make([]string, len(f.Arities))
+	for i, n := range f.Arities {
+		want[i] = strconv.Itoa(n)
+	}
+	return strings.Join(want, " or ")
+}
@@ -0,0 +1,321 @@
+// hostlib/stream.go
+
+package hostlib
+
+import (
+	"renelle/constants"
+	"renelle/object"
+)
+
+func init() {
+	Register("Stream",
+		Function{Name: "chunk", Arities: []int{2}, Fn: StreamChunk,
+			Doc: "Groups the elements into arrays of the given size; the last may be shorter."},
+		Function{Name: "cycle", Arities: []int{1}, Fn: StreamCycle,
+			Doc: "Repeats the elements of an array, range or stream forever."},
+		Function{Name: "each", Arities: []int{2}, Fn: StreamEach,
+			Doc: "Calls the function with each element in turn and returns :ok."},
+		Function{Name: "filter", Arities: []int{2}, Fn: StreamFilter,
+			Doc: "Keeps the elements the predicate is true for."},
+		Function{Name: "from", Arities: []int{1}, Fn: StreamFrom,
+			Doc: "Returns a stream of the elements of an array or range."},
+		Function{Name: "iterate", Arities: []int{2}, Fn: StreamIterate,
+			Doc: "Returns the endless stream of a value, the function applied to it, to that, and so on."},
+		Function{Name: "map", Arities: []int{2}, Fn: StreamMap,
+			Doc: "Applies the function to each element as it is taken."},
+		Function{Name: "repeatedly", Arities: []int{1}, Fn: StreamRepeatedly,
+			Doc: "Returns the endless stream of the results of calling a function of no arguments."},
+		Function{Name: "take", Arities: []int{2}, Fn: StreamTake,
+			Doc: "Ends the stream after its first n elements."},
+		Function{Name: "take_while", Arities: []int{2}, Fn: StreamTakeWhile,
+			Doc: "Ends the stream at the first element the predicate is false for."},
+		Function{Name: "to_array", Arities: []int{1}, Fn: StreamToArray,
+			Doc: "Runs the stream and returns its elements in an array."},
+		Function{Name: "unfold", Arities: []int{2}, Fn: StreamUnfold,
+			Doc: "Calls the function with an accumulator, which returns (element next) to go on or :none to stop."},
+	)
+}
+
+// streamArg returns a function starting a pass over the array, range or
+// stream a Stream function takes as its first argument.
+func streamArg(ctx *object.EvalContext, name string, arg object.Object) (func() object.Iterator, *object.Error) {
+	if _, ok := object.Iterate(arg); !ok {
+		return nil, newError(ctx, "%s() requires an array, a range or a stream, got %s", name, arg.Type())
+	}
+	return func() object.Iterator {
+		next, _ := object.Iterate(arg)
+		return next
+	}, nil
+}
+
+// done is the iterator of a stream with no more elements.
+func done(*object.EvalContext) (object.Object, bool) {
+	return nil, false
+}
+
+func StreamFrom(ctx *object.EvalContext, args ...object.Object) object.Object {
+	start, err := streamArg(ctx, "from", args[0])
+	if err != nil {
+		return err
+	}
+
+	return &object.Stream{Start: start}
+}
+
+func StreamIterate(ctx *object.EvalContext, args ...object.Object) object.Object {
+	first, f := args[0], args[1]
+	return &object.Stream{Start: func() object.Iterator {
+		var current object.Object
+		return func(ctx *object.EvalContext) (object.Object, bool) {
+			if current == nil {
+				current = first
+			} else {
+				current, _ = call(ctx, f, current)
+			}
+			return current, true
+		}
+	}}
+}
+
+func StreamUnfold(ctx *object.EvalContext, args ...object.Object) object.Object {
+	initial, f := args[0], args[1]
+	return &object.Stream{Start: func() object.Iterator {
+		acc := initial
+		finished := false
+		return func(ctx *object.EvalContext) (object.Object, bool) {
+			if finished {
+				return nil, false
+			}
+			result, ok := call(ctx, f, acc)
+			if !ok {
+				finished = true
+				return result, true
+			}
+			if object.Equals(result, constants.NONE) {
+				finished = true
+				return nil, false
+			}
+			step, ok := result.(*object.Tuple)
+			if !ok || len(step.Elements) != 2 {
+				finished = true
+				return newError(ctx, "unfold() requires the function to return (element next) or :none, got %s", result.Inspect()), true
+			}
+			acc = step.Elements[1]
+			return step.Elements[0], true
+		}
+	}}
+}
+
+func StreamRepeatedly(ctx *object.EvalContext, args ...object.Object) object.Object {
+	f := args[0]
+	return &object.Stream{Start: func() object.Iterator {
+		return func(ctx *object.EvalContext) (object.Object, bool) {
+			value, _ := call(ctx, f)
+			return value, true
+		}
+	}}
+}
+
+func StreamCycle(ctx *object.EvalContext, args ...object.Object) object.Object {
+	start, err := streamArg(ctx, "cycle", args[0])
+	if err != nil {
+		return err
+	}
+
+	return &object.Stream{Start: func() object.Iterator {
+		next := start()
+		empty := true // whether the current pass has yielded nothing yet
+		return func(ctx *object.EvalContext) (object.Object, bool) {
+			for {
+				el, ok := next(ctx)
+				if ok {
+					empty = false
+					return el, true
+				}
+				if empty {
+					// an empty pass would repeat forever without yielding
+					return nil, false
+				}
+				next = start()
+				empty = true
+			}
+		}
+	}}
+}
+
+func StreamMap(ctx *object.EvalContext, args ...object.Object) object.Object {
+	start, err := streamArg(ctx, "map", args[0])
+	if err != nil {
+		return err
+	}
+
+	f := args[1]
+	return &object.Stream{Start: func() object.Iterator {
+		next := start()
+		return func(ctx *object.EvalContext) (object.Object, bool) {
+			el, ok := next(ctx)
+			if !ok || isError(el) {
+				return el, ok
+			}
+			value, _ := call(ctx, f, el)
+			return value, true
+		}
+	}}
+}
+
+func StreamFilter(ctx *object.EvalContext, args ...object.Object) object.Object {
+	start, err := streamArg(ctx, "filter", args[0])
+	if err != nil {
+		return err
+	}
+
+	predicate := args[1]
+	return &object.Stream{Start: func() object.Iterator {
+		next := start()
+		return func(ctx *object.EvalContext) (object.Object, bool) {
+			for {
+				el, ok := next(ctx)
+				if !ok || isError(el) {
+					return el, ok
+				}
+				keep, ok := call(ctx, predicate, el)
+				if !ok {
+					return keep, true
+				}
+				if isTruthy(keep) {
+					return el, true
+				}
+			}
+		}
+	}}
+}
+
+func StreamTake(ctx *object.EvalContext, args ...object.Object) object.Object {
+	start, err := streamArg(ctx, "take", args[0])
+	if err != nil {
+		return err
+	}
+	n, err := countArg(ctx, "take", args)
+	if err != nil {
+		return err
+	}
+
+	return &object.Stream{Start: func() object.Iterator {
+		next := start()
+		left := n
+		return func(ctx *object.EvalContext) (object.Object, bool) {
+			if left == 0 {
+				return nil, false
+			}
+			left--
+			return next(ctx)
+		}
+	}}
+}
+
+func StreamTakeWhile(ctx *object.EvalContext, args ...object.Object) object.Object {
+	start, err := streamArg(ctx, "take_while", args[0])
+	if err != nil {
+		return err
+	}
+
+	predicate := args[1]
+	return &object.Stream{Start: func() object.Iterator {
+		next := start()
+		return func(ctx *object.EvalContext) (object.Object, bool) {
+			el, ok := next(ctx)
+			if !ok || isError(el) {
+				return el, ok
+			}
+			keep, ok := call(ctx, predicate, el)
+			if !ok {
+				return keep, true
+			}
+			if !isTruthy(keep) {
+				next = done
+				return nil, false
+			}
+			return el, true
+		}
+	}}
+}
+
+func StreamChunk(ctx *object.EvalContext, args ...object.Object) object.Object {
+	start, err := streamArg(ctx, "chunk", args[0])
+	if err != nil {
+		return err
+	}
+	size, ok := args[1].(*object.Integer)
+	if !ok || size.Value < 1 {
+		return newError(ctx, "chunk() requires a positive integer size")
+	}
+
+	return &object.Stream{Start: func() object.Iterator {
+		next := start()
+		return func(ctx *object.EvalContext) (object.Object, bool) {
+			chunk := []object.Object{}
+			for int64(len(chunk)) < size.Value {
+				el, ok := next(ctx)
+				if !ok {
+					next = done
+					break
+				}
+				if isError(el) {
+					return el, true
+				}
+				chunk = append(chunk, el)
+			}
+			if len(chunk) == 0 {
+				return nil, false
+			}
+			return &object.Array{Elements: chunk}, true
+		}
+	}}
+}
+
+func StreamToArray(ctx *object.EvalContext, args ...object.Object) object.Object {
+	start, err := streamArg(ctx, "to_array", args[0])
+	if err != nil {
+		return err
+	}
+
+	elements := []object.Object{}
+	next := start()
+	for {
+		el, ok := next(ctx)
+		if !ok {
+			return &object.Array{Elements: elements}
+		}
+		if isError(el) {
+			return el
+		}
+		elements = append(elements, el)
+	}
+}
+
+// StreamEach runs a stream for its effects, like a for loop over it.
+func StreamEach(ctx *object.EvalContext, args ...object.Object) object.Object {
+	start, err := streamArg(ctx, "each", args[0])
+	if err != nil {
+		return err
+	}
+
+	next := start()
+	for {
+		el, ok := next(ctx)
+		if !ok {
+			return constants.OK
+		}
+		if isError(el) {
+			return el
+		}
+		if result, ok := call(ctx, args[1], el); !ok {
+			return result
+		}
+	}
+}
+
+func isError(obj object.Object) bool {
+	_, ok := obj.(*object.Error)
+	return ok
+}
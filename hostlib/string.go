@@ -9,6 +9,49 @@ import (
 	"renelle/object"
 )
 
+func init() {
+	Register("String",
+		Function{Name: "concat", Arities: []int{2}, Fn: StringConcat,
+			Doc: "Joins two strings."},
+		Function{Name: "contains?", Arities: []int{2}, Fn: StringContains,
+			Doc: "Reports whether the string contains a substring."},
+		Function{Name: "ends_with?", Arities: []int{2}, Fn: StringEndsWith,
+			Doc: "Reports whether the string ends with a suffix."},
+		Function{Name: "index_of", Arities: []int{2}, Fn: StringIndexOf,
+			Doc: "Returns the index of the first instance of a substring, or -1."},
+		Function{Name: "length", Arities: []int{1}, Fn: StringLength,
+			Doc: "Returns the length of the string."},
+		Function{Name: "lower", Arities: []int{1}, Fn: StringLower,
+			Doc: "Returns the string in lower case."},
+		Function{Name: "match?", Arities: []int{2}, Fn: StringMatch,
+			Doc: "Reports whether the string matches a regular expression."},
+		Function{Name: "pad_left", Arities: []int{2, 3}, Fn: StringPadLeft,
+			Doc: "Pads the string on the left, with spaces or the given string."},
+		Function{Name: "pad_right", Arities: []int{2, 3}, Fn: StringPadRight,
+			Doc: "Pads the string on the right, with spaces or the given string."},
+		Function{Name: "parse_num", Arities: []int{1}, Fn: StringParseNum,
+			Doc: "Parses an integer or float, returning :nil if the string is not a number."},
+		Function{Name: "replace", Arities: []int{3}, Fn: StringReplace,
+			Doc: "Replaces the first instance of a substring."},
+		Function{Name: "replace_all", Arities: []int{3}, Fn: StringReplaceAll,
+			Doc: "Replaces every instance of a substring."},
+		Function{Name: "split", Arities: []int{1, 2}, Fn: StringSplit,
+			Doc: "Splits the string by a separator, or into characters."},
+		Function{Name: "starts_with?", Arities: []int{2}, Fn: StringStartsWith,
+			Doc: "Reports whether the string starts with a prefix."},
+		Function{Name: "trim", Arities: []int{1}, Fn: StringTrim,
+			Doc: "Removes whitespace from both ends of the string."},
+		Function{Name: "trim_end", Arities: []int{1}, Fn: StringTrimEnd,
+			Doc: "Removes whitespace from the end of the string."},
+		Function{Name: "trim_start", Arities: []int{1}, Fn: StringTrimStart,
+			Doc: "Removes whitespace from the start of the string."},
+		Function{Name: "try_parse_num", Arities: []int{1}, Fn: StringTryParseNum,
+			Doc: "Parses a number, returning (:some number) or :none."},
+		Function{Name: "upper", Arities: []int{1}, Fn: StringUpper,
+			Doc: "Returns the string in upper case."},
+	)
+}
+
 // StringConcat concatenates two strings.
 func StringConcat(ctx *object.EvalContext, args ...object.Object) object.Object {
 	if len(args) != 2 {
@@ -0,0 +1,543 @@
+// interp/convert.go
+
+package interp
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"renelle/constants"
+	"renelle/evaluator"
+	"renelle/object"
+)
+
+// Atom is the Go value of a Renelle atom, named without its colon.
+type Atom string
+
+var (
+	objectType  = reflect.TypeOf((*object.Object)(nil)).Elem()
+	builtinType = reflect.TypeOf(object.BuiltinFunction(nil))
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	atomType    = reflect.TypeOf(Atom(""))
+)
+
+// ToObject converts a Go value to a Renelle value. Numbers, strings and
+// booleans convert to their Renelle types, slices and arrays to arrays, and
+// maps and structs to maps. String map keys and struct fields become atom
+// keys, so they read as `m.name`. A struct field is named by its `renelle`
+// tag, or else the snake case of its Go name, and a tag of "-" skips it.
+// Go funcs become builtins. Nil converts to :nil, and object.Object values
+// are passed through unchanged.
+func ToObject(v interface{}) (object.Object, error) {
+	switch v := v.(type) {
+	case nil:
+		return constants.NIL, nil
+	case object.Object:
+		return v, nil
+	case Atom:
+		return evaluator.Atom(string(v)), nil
+	case object.BuiltinFunction:
+		return &object.Builtin{Fn: v}, nil
+	}
+	return toObject(reflect.ValueOf(v))
+}
+
+func toObject(v reflect.Value) (object.Object, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return constants.TRUE, nil
+		}
+		return constants.FALSE, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &object.Integer{Value: int64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			elem, err := ToObject(v.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = elem
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		mapObj := &object.Map{}
+		for _, k := range sortedKeys(v) {
+			var key object.Object
+			if k.Kind() == reflect.String {
+				key = evaluator.Atom(k.String())
+			} else {
+				obj, err := ToObject(k.Interface())
+				if err != nil {
+					return nil, err
+				}
+				if _, ok := obj.(object.Hashable); !ok {
+					return nil, fmt.Errorf("unusable as hash key: %s", obj.Type())
+				}
+				key = obj
+			}
+			value, err := ToObject(v.MapIndex(k).Interface())
+			if err != nil {
+				return nil, err
+			}
+			mapObj.Put(key, value)
+		}
+		return mapObj, nil
+	case reflect.Struct:
+		t := v.Type()
+		mapObj := &object.Map{}
+		for i := 0; i < t.NumField(); i++ {
+			name, ok := fieldName(t.Field(i))
+			if !ok {
+				continue
+			}
+			value, err := ToObject(v.Field(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			mapObj.Put(evaluator.Atom(name), value)
+		}
+		return mapObj, nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return constants.NIL, nil
+		}
+		return ToObject(v.Elem().Interface())
+	case reflect.Func:
+		if v.IsNil() {
+			return constants.NIL, nil
+		}
+		if v.Type().ConvertibleTo(builtinType) {
+			return &object.Builtin{Fn: v.Convert(builtinType).Interface().(object.BuiltinFunction)}, nil
+		}
+		return wrapFunc(v)
+	}
+
+	return nil, fmt.Errorf("cannot convert %s to a Renelle value", v.Type())
+}
+
+// sortedKeys returns the keys of a Go map in order, so that converting it
+// gives a map with the same key order every time rather than Go's random
+// one. Keys of different kinds are ordered by kind.
+func sortedKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keyLess(keys[i], keys[j])
+	})
+	return keys
+}
+
+func keyLess(a, b reflect.Value) bool {
+	if a.Kind() == reflect.Interface {
+		a = a.Elem()
+	}
+	if b.Kind() == reflect.Interface {
+		b = b.Elem()
+	}
+	if a.Kind() != b.Kind() {
+		return a.Kind() < b.Kind()
+	}
+	switch a.Kind() {
+	case reflect.String:
+		return a.String() < b.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
+	case reflect.Bool:
+		return !a.Bool() && b.Bool()
+	case reflect.Invalid:
+		return false
+	default:
+		return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+	}
+}
+
+// fieldName returns the name a struct field has in Renelle, and false if the
+// field is not converted.
+func fieldName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+	tag := f.Tag.Get("renelle")
+	if tag == "-" {
+		return "", false
+	}
+	if tag != "" {
+		return tag, true
+	}
+	return snakeCase(f.Name), true
+}
+
+func snakeCase(s string) string {
+	runes := []rune(s)
+	var out strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			lowerBefore := i > 0 && !unicode.IsUpper(runes[i-1])
+			lowerAfter := i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if lowerBefore || lowerAfter {
+				out.WriteRune('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
+
+// wrapFunc makes a builtin of a Go func, converting its arguments with Decode
+// and its result with ToObject.
+func wrapFunc(fn reflect.Value) (object.Object, error) {
+	t := fn.Type()
+	if t.IsVariadic() {
+		return nil, fmt.Errorf("cannot convert variadic %s to a Renelle function", t)
+	}
+
+	results := t.NumOut()
+	returnsError := results > 0 && t.Out(results-1) == errorType
+	if returnsError {
+		results--
+	}
+	if results > 1 {
+		return nil, fmt.Errorf("cannot convert %s to a Renelle function: too many results", t)
+	}
+
+	builtin := func(ctx *object.EvalContext, args ...object.Object) object.Object {
+		if len(args) != t.NumIn() {
+			return newError(ctx, "wrong number of arguments. got=%d, want=%d", len(args), t.NumIn())
+		}
+
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			in[i] = reflect.New(t.In(i)).Elem()
+			if err := decode(arg, in[i]); err != nil {
+				return newError(ctx, "argument %d: %s", i+1, err)
+			}
+		}
+
+		out := fn.Call(in)
+		if returnsError && !out[len(out)-1].IsNil() {
+			return newError(ctx, "%s", out[len(out)-1].Interface().(error))
+		}
+		if results == 0 {
+			return constants.NIL
+		}
+
+		result, err := ToObject(out[0].Interface())
+		if err != nil {
+			return newError(ctx, "%s", err)
+		}
+		return result
+	}
+
+	return &object.Builtin{Fn: builtin}, nil
+}
+
+func newError(ctx *object.EvalContext, format string, a ...interface{}) *object.Error {
+	return &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: fmt.Sprintf(format, a...)}
+}
+
+// FromObject converts a Renelle value to a plain Go value: int64, float64,
+// string, bool, Atom, []interface{} for arrays and tuples, and
+// map[string]interface{} for structs and maps keyed by atoms or strings.
+// Other maps convert to map[interface{}]interface{}. :nil converts to nil,
+// and values with no Go equivalent, such as functions, are returned as is.
+func FromObject(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case nil:
+		return nil
+	case *object.Integer:
+		return obj.Value
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.Atom:
+		if obj.Value == "nil" {
+			return nil
+		}
+		return Atom(obj.Value)
+	case *object.Array:
+		return fromObjects(obj.Elements)
+	case *object.Tuple:
+		return fromObjects(obj.Elements)
+	case *object.Struct:
+		fields := make(map[string]interface{}, len(obj.Fields))
+		for _, f := range obj.Fields {
+			value, _ := obj.Get(f)
+			fields[f] = FromObject(value)
+		}
+		return fields
+	case *object.Map:
+		keys := obj.Keys()
+		if stringKeys(keys) {
+			m := make(map[string]interface{}, len(keys))
+			for _, k := range keys {
+				value, _ := obj.Get(k)
+				m[keyString(k)] = FromObject(value)
+			}
+			return m
+		}
+		m := make(map[interface{}]interface{}, len(keys))
+		for _, k := range keys {
+			value, _ := obj.Get(k)
+			goKey := FromObject(k)
+			if goKey != nil && !reflect.TypeOf(goKey).Comparable() {
+				goKey = k.Inspect()
+			}
+			m[goKey] = FromObject(value)
+		}
+		return m
+	}
+	return obj
+}
+
+func fromObjects(objects []object.Object) []interface{} {
+	values := make([]interface{}, len(objects))
+	for i, obj := range objects {
+		values[i] = FromObject(obj)
+	}
+	return values
+}
+
+func stringKeys(keys []object.Object) bool {
+	for _, k := range keys {
+		switch k.(type) {
+		case *object.Atom, *object.String:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func keyString(key object.Object) string {
+	switch key := key.(type) {
+	case *object.Atom:
+		return key.Value
+	case *object.String:
+		return key.Value
+	}
+	return key.Inspect()
+}
+
+// Decode stores a Renelle value in the Go value out points to. Maps and
+// structs decode into Go structs by the field names ToObject uses, and
+// fields missing from the value are left as they are.
+func Decode(obj object.Object, out interface{}) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("cannot decode into %T: not a pointer", out)
+	}
+	return decode(obj, v.Elem())
+}
+
+func decode(obj object.Object, v reflect.Value) error {
+	if v.Type() == objectType {
+		v.Set(reflect.ValueOf(&obj).Elem())
+		return nil
+	}
+
+	mismatch := func() error {
+		return fmt.Errorf("cannot decode %s into %s", obj.Type(), v.Type())
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if value := FromObject(obj); value != nil {
+			if !reflect.TypeOf(value).AssignableTo(v.Type()) {
+				return mismatch()
+			}
+			v.Set(reflect.ValueOf(value))
+		}
+		return nil
+
+	case reflect.Pointer:
+		if atom, ok := obj.(*object.Atom); ok && atom.Value == "nil" {
+			v.SetZero()
+			return nil
+		}
+		elem := reflect.New(v.Type().Elem())
+		if err := decode(obj, elem.Elem()); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+
+	case reflect.Bool:
+		b, ok := obj.(*object.Boolean)
+		if !ok {
+			return mismatch()
+		}
+		v.SetBool(b.Value)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch()
+		}
+		if v.OverflowInt(i.Value) {
+			return fmt.Errorf("%d overflows %s", i.Value, v.Type())
+		}
+		v.SetInt(i.Value)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch()
+		}
+		if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+			return fmt.Errorf("%d overflows %s", i.Value, v.Type())
+		}
+		v.SetUint(uint64(i.Value))
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		switch n := obj.(type) {
+		case *object.Float:
+			v.SetFloat(n.Value)
+		case *object.Integer:
+			v.SetFloat(float64(n.Value))
+		default:
+			return mismatch()
+		}
+		return nil
+
+	case reflect.String:
+		switch s := obj.(type) {
+		case *object.String:
+			v.SetString(s.Value)
+		case *object.Atom:
+			if v.Type() != atomType {
+				return mismatch()
+			}
+			v.SetString(s.Value)
+		default:
+			return mismatch()
+		}
+		return nil
+
+	case reflect.Slice:
+		elements, ok := collectionElements(obj)
+		if !ok {
+			return mismatch()
+		}
+		slice := reflect.MakeSlice(v.Type(), len(elements), len(elements))
+		for i, elem := range elements {
+			if err := decode(elem, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+
+	case reflect.Array:
+		elements, ok := collectionElements(obj)
+		if !ok {
+			return mismatch()
+		}
+		if len(elements) != v.Len() {
+			return fmt.Errorf("cannot decode %d elements into %s", len(elements), v.Type())
+		}
+		for i, elem := range elements {
+			if err := decode(elem, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		mapObj, ok := obj.(*object.Map)
+		if !ok {
+			return mismatch()
+		}
+		m := reflect.MakeMapWithSize(v.Type(), len(mapObj.Keys()))
+		for _, k := range mapObj.Keys() {
+			key := reflect.New(v.Type().Key()).Elem()
+			if atom, ok := k.(*object.Atom); ok && key.Kind() == reflect.String {
+				key.SetString(atom.Value)
+			} else if err := decode(k, key); err != nil {
+				return err
+			}
+			value, _ := mapObj.Get(k)
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := decode(value, elem); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, elem)
+		}
+		v.Set(m)
+		return nil
+
+	case reflect.Struct:
+		get, ok := fieldGetter(obj)
+		if !ok {
+			return mismatch()
+		}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name, ok := fieldName(t.Field(i))
+			if !ok {
+				continue
+			}
+			value, ok := get(name)
+			if !ok {
+				continue
+			}
+			if err := decode(value, v.Field(i)); err != nil {
+				return fmt.Errorf("field %s: %w", name, err)
+			}
+		}
+		return nil
+	}
+
+	return mismatch()
+}
+
+func collectionElements(obj object.Object) ([]object.Object, bool) {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return obj.Elements, true
+	case *object.Tuple:
+		return obj.Elements, true
+	case *object.Range:
+		if arr, ok := obj.Array(); ok {
+			return arr.Elements, true
+		}
+	}
+	return nil, false
+}
+
+// fieldGetter returns a lookup of a map's or struct's values by field name.
+// Map fields are keyed by atoms, or failing that strings.
+func fieldGetter(obj object.Object) (func(string) (object.Object, bool), bool) {
+	switch obj := obj.(type) {
+	case *object.Struct:
+		return obj.Get, true
+	case *object.Map:
+		return func(name string) (object.Object, bool) {
+			if value, ok := obj.Get(evaluator.Atom(name)); ok {
+				return value, true
+			}
+			return obj.Get(&object.String{Value: name})
+		}, true
+	}
+	return nil, false
+}
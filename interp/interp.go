@@ -0,0 +1,181 @@
+// interp/interp.go
+
+// Package interp runs Renelle code from Go programs. An Interpreter keeps its
+// globals and loaded modules between calls, so a script can be evaluated once
+// and its functions called as often as needed.
+package interp
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"renelle/evaluator"
+	"renelle/lexer"
+	"renelle/object"
+	"renelle/parser"
+)
+
+type Interpreter struct {
+	env *object.Environment
+	ctx *object.EvalContext
+}
+
+type Option func(*Interpreter)
+
+// WithArgs sets the command line arguments scripts see.
+func WithArgs(args ...string) Option {
+	return func(i *Interpreter) {
+		(*i.ctx.MetaData)["args"] = args
+	}
+}
+
+func New(opts ...Option) *Interpreter {
+	i := &Interpreter{
+		env: object.NewEnvironment(),
+		ctx: object.NewEvalContext(),
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// SyntaxError is returned when source code does not parse.
+type SyntaxError struct {
+	Errors []parser.ParseError
+}
+
+func (e *SyntaxError) Error() string {
+	return strings.TrimRight(parser.RenderErrors(e.Errors), "\n")
+}
+
+// RuntimeError is returned when evaluating Renelle code fails.
+type RuntimeError struct {
+	Err *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.Err.FileName, e.Err.Line, e.Err.Column, e.Err.Message)
+}
+
+// Trace renders the error with the stack of calls it was raised in.
+func (e *RuntimeError) Trace() string {
+	return e.Err.Trace()
+}
+
+// Eval evaluates src, returning the value of its last statement.
+func (i *Interpreter) Eval(src string) (object.Object, error) {
+	return i.eval(src, "<eval>")
+}
+
+// EvalFile evaluates the file at path.
+func (i *Interpreter) EvalFile(path string) (object.Object, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return i.eval(string(content), path)
+}
+
+func (i *Interpreter) eval(src string, fileName string) (object.Object, error) {
+	l := lexer.New(src, fileName)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &SyntaxError{Errors: p.Errors()}
+	}
+
+	return i.result(evaluator.Eval(program, i.env, i.ctx))
+}
+
+// Call calls the named function with args converted by ToObject. The name is
+// either a global function or one in a module, as in "String.upper".
+func (i *Interpreter) Call(name string, args ...interface{}) (object.Object, error) {
+	fn, err := i.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]object.Object, len(args))
+	for idx, arg := range args {
+		obj, err := ToObject(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d to %s: %w", idx+1, name, err)
+		}
+		objects[idx] = obj
+	}
+
+	return i.result(evaluator.ApplyFunction(fn, objects, i.ctx))
+}
+
+func (i *Interpreter) lookup(name string) (object.Object, error) {
+	dot := strings.LastIndex(name, ".")
+	if dot == -1 {
+		if fn, ok := i.env.Get(name); ok {
+			return fn, nil
+		}
+		if fn, ok := evaluator.LookupBuiltin(name); ok {
+			return fn, nil
+		}
+		return nil, fmt.Errorf("function not found: %s", name)
+	}
+
+	module := evaluator.GetModule(i.ctx, name[:dot], i.env)
+	if err, ok := module.(*object.Error); ok {
+		return nil, &RuntimeError{Err: err}
+	}
+	fn := evaluator.GetProperty(i.ctx, module, name[dot+1:])
+	if err, ok := fn.(*object.Error); ok {
+		return nil, &RuntimeError{Err: err}
+	}
+	return fn, nil
+}
+
+func (i *Interpreter) result(obj object.Object) (object.Object, error) {
+	if err, ok := obj.(*object.Error); ok {
+		return nil, &RuntimeError{Err: err}
+	}
+	if obj == nil {
+		return evaluator.Atom("nil"), nil
+	}
+	return obj, nil
+}
+
+// Get returns the value of a global.
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	return i.env.Get(name)
+}
+
+// Set binds a global to value, converted by ToObject.
+func (i *Interpreter) Set(name string, value interface{}) error {
+	obj, err := ToObject(value)
+	if err != nil {
+		return err
+	}
+	i.env.Set(name, obj)
+	return nil
+}
+
+// RegisterModule makes a module of Go functions available to scripts under
+// name, so `Name.fn(...)` calls them. Each function is either an
+// object.BuiltinFunction, or any Go func, whose arguments and results are
+// converted like those of Call. A func returning a non-nil error as its last
+// result raises that error in the script. Modules registered here belong to
+// this interpreter only; hostlib.Register adds native functions for all.
+func (i *Interpreter) RegisterModule(name string, funcs map[string]interface{}) error {
+	env := object.NewEnvironment()
+	for fnName, fn := range funcs {
+		obj, err := ToObject(fn)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", name, fnName, err)
+		}
+		if _, ok := obj.(*object.Builtin); !ok {
+			return fmt.Errorf("%s.%s: not a function", name, fnName)
+		}
+		env.Set(fnName, obj)
+	}
+
+	i.env.SetModule(name, &object.Module{Name: name, Environment: env})
+	return nil
+}
@@ -0,0 +1,280 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"renelle/object"
+)
+
+func TestEval(t *testing.T) {
+	i := New()
+
+	result, err := i.Eval("let x = 5\nx * 2")
+	if err != nil {
+		t.Fatalf("Eval error: %s", err)
+	}
+	if FromObject(result) != int64(10) {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+
+	// globals persist between calls
+	result, err = i.Eval("x + 1")
+	if err != nil {
+		t.Fatalf("Eval error: %s", err)
+	}
+	if FromObject(result) != int64(6) {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	i := New()
+
+	_, err := i.Eval("let x = add(1 2}")
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("expected *SyntaxError. got=%T (%v)", err, err)
+	}
+	if !strings.Contains(err.Error(), "expected ), got } instead") {
+		t.Errorf("wrong error. got=%q", err.Error())
+	}
+
+	_, err = i.Eval("fn f(x) { x + :a }\nf(1)")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected *RuntimeError. got=%T (%v)", err, err)
+	}
+	if err.Error() != "<eval>:1:13: type mismatch: INTEGER + ATOM" {
+		t.Errorf("wrong error. got=%q", err.Error())
+	}
+	if !strings.Contains(runtimeErr.Trace(), "at f (<eval>:1:13)\n    at <eval>:2:1") {
+		t.Errorf("trace does not show the call. got=%q", runtimeErr.Trace())
+	}
+}
+
+func TestCall(t *testing.T) {
+	i := New()
+	if _, err := i.Eval(`fn greet(person) { $"hello {person.name}" }`); err != nil {
+		t.Fatalf("Eval error: %s", err)
+	}
+
+	type person struct {
+		Name string
+		Age  int `renelle:"years"`
+	}
+
+	result, err := i.Call("greet", person{Name: "Ada", Age: 36})
+	if err != nil {
+		t.Fatalf("Call error: %s", err)
+	}
+	if FromObject(result) != "hello Ada" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+
+	result, err = i.Call("String.upper", "abc")
+	if err != nil {
+		t.Fatalf("Call error: %s", err)
+	}
+	if FromObject(result) != "ABC" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+
+	if _, err := i.Call("missing"); err == nil || err.Error() != "function not found: missing" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+func TestToObject(t *testing.T) {
+	type inner struct {
+		Tags []string
+	}
+	type record struct {
+		ID       int
+		UserName string
+		Score    float64 `renelle:"points"`
+		Secret   string  `renelle:"-"`
+		Inner    *inner
+		private  bool
+	}
+
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{nil, ":nil"},
+		{42, "42"},
+		{uint8(7), "7"},
+		{1.5, "1.5"},
+		{"hi", `"hi"`},
+		{true, "true"},
+		{Atom("ok"), ":ok"},
+		{[]int{1, 2, 3}, "[1 2 3]"},
+		{map[string]int{"a": 1}, "{:a = 1}"},
+		{map[string]int{"c": 3, "a": 1, "b": 2}, "{:a = 1, :b = 2, :c = 3}"},
+		{map[int]string{3: "c", 1: "a", 2: "b"}, `{1 = "a", 2 = "b", 3 = "c"}`},
+		{record{ID: 1, UserName: "u", Score: 2.5, Secret: "s", Inner: &inner{Tags: []string{"x"}}}, ""},
+	}
+
+	for _, tt := range tests {
+		obj, err := ToObject(tt.input)
+		if err != nil {
+			t.Errorf("ToObject(%v) error: %s", tt.input, err)
+			continue
+		}
+		if tt.expected != "" && obj.Inspect() != tt.expected {
+			t.Errorf("ToObject(%v) wrong. want=%s, got=%s", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+
+	obj, _ := ToObject(record{ID: 1, UserName: "u", Score: 2.5, Secret: "s", Inner: &inner{Tags: []string{"x"}}})
+	expected := map[string]interface{}{
+		"id":        int64(1),
+		"user_name": "u",
+		"points":    2.5,
+		"inner":     map[string]interface{}{"tags": []interface{}{"x"}},
+	}
+	if got := FromObject(obj); !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong struct conversion. want=%v, got=%v", expected, got)
+	}
+
+	if _, err := ToObject(make(chan int)); err == nil {
+		t.Errorf("expected an error converting a channel")
+	}
+}
+
+func TestConcurrentInterpreters(t *testing.T) {
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			i := New()
+			for k := 0; k < 100; k++ {
+				value := map[string]Atom{fmt.Sprintf("key_%d_%d", n, k): Atom(fmt.Sprintf("atom_%d_%d", n, k))}
+				if _, err := ToObject(value); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := i.Eval("{status: :done}.status"); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(n)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	type item struct {
+		Name  string
+		Price float64
+		Tags  []string
+	}
+	type order struct {
+		ID     int64
+		Items  []item
+		Status Atom
+		Note   *string
+		Extra  map[string]int
+	}
+
+	i := New()
+	result, err := i.Eval(`{
+		id: 7
+		items: [{name: "pen" price: 2 tags: ["office"]} {name: "ink" price: 1.5 tags: []}]
+		status: :paid
+		note: :nil
+		extra: {a: 1}
+	}`)
+	if err != nil {
+		t.Fatalf("Eval error: %s", err)
+	}
+
+	var got order
+	if err := Decode(result, &got); err != nil {
+		t.Fatalf("Decode error: %s", err)
+	}
+
+	expected := order{
+		ID: 7,
+		Items: []item{
+			{Name: "pen", Price: 2, Tags: []string{"office"}},
+			{Name: "ink", Price: 1.5, Tags: []string{}},
+		},
+		Status: "paid",
+		Extra:  map[string]int{"a": 1},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong decode.\nwant=%+v\ngot= %+v", expected, got)
+	}
+
+	var evens []int
+	if err := Decode(&object.Range{First: 0, Last: 6, Step: 2}, &evens); err != nil || !reflect.DeepEqual(evens, []int{0, 2, 4, 6}) {
+		t.Errorf("wrong range decode. got=%v (%v)", evens, err)
+	}
+
+	var n int8
+	if err := Decode(&object.Integer{Value: 300}, &n); err == nil || err.Error() != "300 overflows int8" {
+		t.Errorf("wrong overflow error. got=%v", err)
+	}
+	var s string
+	if err := Decode(&object.Integer{Value: 1}, &s); err == nil || err.Error() != "cannot decode INTEGER into string" {
+		t.Errorf("wrong mismatch error. got=%v", err)
+	}
+}
+
+func TestRegisterModule(t *testing.T) {
+	i := New()
+	err := i.RegisterModule("Host", map[string]interface{}{
+		"add": func(a, b int) int { return a + b },
+		"fail": func(msg string) (string, error) {
+			return "", errors.New(msg)
+		},
+		"count": func(ctx *object.EvalContext, args ...object.Object) object.Object {
+			return &object.Integer{Value: int64(len(args))}
+		},
+	})
+	if err != nil {
+		t.Fatalf("RegisterModule error: %s", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Host.add(2 3)", "5"},
+		{"Host.count(1 2 3)", "3"},
+		{"Host.add(1)", "wrong number of arguments. got=1, want=2"},
+		{`Host.add(1 "a")`, "argument 2: cannot decode STRING into int"},
+		{`Host.fail("boom")`, "boom"},
+	}
+
+	for _, tt := range tests {
+		result, err := i.Eval(tt.input)
+		var got string
+		if err != nil {
+			got = err.(*RuntimeError).Err.Message
+		} else {
+			got = result.Inspect()
+		}
+		if got != tt.expected {
+			t.Errorf("%s: want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	if err := i.RegisterModule("Bad", map[string]interface{}{"x": 1}); err == nil {
+		t.Errorf("expected an error registering a non-function")
+	}
+}
@@ -31,10 +31,10 @@ func (l *Lexer) NextToken() token.Token {
 
 	for {
 		l.skipWhitespace()
-		l.skipComments()
-		if l.ch != ' ' && l.ch != '#' {
+		if l.ch != '#' {
 			break
 		}
+		l.skipComments()
 	}
 
 	switch l.ch {
@@ -77,11 +77,19 @@ func (l *Lexer) NextToken() token.Token {
 	case '-':
 		tok = newToken(token.MINUS, l.ch, l)
 	case '/':
-		tok = newToken(token.SLASH, l.ch, l)
+		if l.getNextChar() == '/' {
+			col := l.column
+			l.readChar()
+			tok = token.Token{Type: token.STEP, Literal: "//", Line: l.line, Column: col, FileName: l.name}
+		} else {
+			tok = newToken(token.SLASH, l.ch, l)
+		}
 	case '\\':
 		tok = newToken(token.BACKSLASH, l.ch, l)
 	case '%':
 		tok = newToken(token.MOD, l.ch, l)
+	case '^':
+		tok = newToken(token.CARET, l.ch, l)
 	case '*':
 		if l.getNextChar() == '*' {
 			ch := l.ch
@@ -92,6 +100,8 @@ func (l *Lexer) NextToken() token.Token {
 		} else {
 			tok = newToken(token.ASTERISK, l.ch, l)
 		}
+	case ';':
+		tok = newToken(token.SEMICOLON, l.ch, l)
 	case '(':
 		tok = newToken(token.LPAREN, l.ch, l)
 	case ')':
@@ -106,6 +116,9 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.RBRACKET, l.ch, l)
 	case '@':
 		tok = newToken(token.AT, l.ch, l)
+	case '?':
+		// only at the start of a token: `empty?` is a name
+		tok = newToken(token.QUESTION, l.ch, l)
 	case '<':
 		if l.getNextChar() == '=' {
 			ch := l.ch
@@ -113,6 +126,12 @@ func (l *Lexer) NextToken() token.Token {
 			l.readChar()
 			literal := string(ch) + string(l.ch)
 			tok = token.Token{Type: token.LTE, Literal: literal, Line: l.line, Column: col, FileName: l.name}
+		} else if l.getNextChar() == '-' {
+			ch := l.ch
+			col := l.column
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.LARROW, Literal: literal, Line: l.line, Column: col, FileName: l.name}
 		} else {
 			tok = newToken(token.LT, l.ch, l)
 		}
@@ -154,7 +173,7 @@ func (l *Lexer) NextToken() token.Token {
 			literal := string(ch) + string(l.ch)
 			tok = token.Token{Type: token.PIPE, Literal: literal, Line: l.line, Column: col, FileName: l.name}
 		} else {
-			tok = newToken(token.ILLEGAL, l.ch, l)
+			tok = newToken(token.BAR, l.ch, l)
 		}
 	case ':':
 		if l.getNextChar() == ':' {
@@ -162,7 +181,7 @@ func (l *Lexer) NextToken() token.Token {
 			col := l.column
 			l.readChar()
 			literal := string(ch) + string(l.ch)
-			tok = token.Token{Type: token.DOTDOT, Literal: literal, Line: l.line, Column: col, FileName: l.name}
+			tok = token.Token{Type: token.COLONCOLON, Literal: literal, Line: l.line, Column: col, FileName: l.name}
 		} else {
 			tok.Line = l.line
 			tok.Column = l.column
@@ -171,7 +190,18 @@ func (l *Lexer) NextToken() token.Token {
 			return tok
 		}
 	case '.':
-		tok = newToken(token.DOT, l.ch, l)
+		if l.getNextChar() == '.' && l.getSecondNextChar() == '.' {
+			col := l.column
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "...", Line: l.line, Column: col, FileName: l.name}
+		} else if l.getNextChar() == '.' {
+			col := l.column
+			l.readChar()
+			tok = token.Token{Type: token.DOTDOT, Literal: "..", Line: l.line, Column: col, FileName: l.name}
+		} else {
+			tok = newToken(token.DOT, l.ch, l)
+		}
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -233,6 +263,16 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
+// SourceLine returns line n (counting from 1) of the input, for showing
+// where an error is.
+func (l *Lexer) SourceLine(n int) string {
+	lines := strings.Split(l.input, "\n")
+	if n < 1 || n > len(lines) {
+		return ""
+	}
+	return strings.TrimRight(lines[n-1], "\r")
+}
+
 func (l *Lexer) readChar() {
 	isNewLine := l.ch == '\n'
 	if l.readPosition >= len(l.input) {
@@ -44,6 +44,7 @@ with
 $"hello {name}"
 === !==
 1::2
+n in 1..10//2
 `
 
 	tests := []struct {
@@ -151,9 +152,16 @@ $"hello {name}"
 		{token.ARRAY_EQ, "===", 34, 1},
 		{token.ARRAY_NEQ, "!==", 34, 5},
 		{token.INT, "1", 35, 1},
-		{token.DOTDOT, "::", 35, 2},
+		{token.COLONCOLON, "::", 35, 2},
 		{token.INT, "2", 35, 4},
-		{token.EOF, "", 36, 1},
+		{token.IDENT, "n", 36, 1},
+		{token.IN, "in", 36, 3},
+		{token.INT, "1", 36, 6},
+		{token.DOTDOT, "..", 36, 7},
+		{token.INT, "10", 36, 9},
+		{token.STEP, "//", 36, 11},
+		{token.INT, "2", 36, 13},
+		{token.EOF, "", 37, 1},
 	}
 
 	l := New(input, "test")
@@ -230,6 +238,80 @@ func TestFunctionLiteralLexing(t *testing.T) {
 	}
 }
 
+func TestLeftArrowLexing(t *testing.T) {
+	input := `(:ok x) <- f() a < -1`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LPAREN, "("},
+		{token.ATOM, "ok"},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.LARROW, "<-"},
+		{token.FUNCCALL, "f"},
+		{token.LPAREN, "("},
+		{token.RPAREN, ")"},
+		{token.IDENT, "a"},
+		{token.LT, "<"},
+		{token.MINUS, "-"},
+		{token.INT, "1"},
+		{token.EOF, ""},
+	}
+
+	l := New(input, "test")
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestQuestionLexing(t *testing.T) {
+	input := `f(x)? empty?(xs) (r)? ok?`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FUNCCALL, "f"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.QUESTION, "?"},
+		{token.FUNCCALL, "empty?"},
+		{token.LPAREN, "("},
+		{token.IDENT, "xs"},
+		{token.RPAREN, ")"},
+		{token.LPAREN, "("},
+		{token.IDENT, "r"},
+		{token.RPAREN, ")"},
+		{token.QUESTION, "?"},
+		{token.IDENT, "ok?"},
+		{token.EOF, ""},
+	}
+
+	l := New(input, "test")
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
 func TestNextToken_FUNCCALL(t *testing.T) {
 	input := `function(arg_one arg_two)`
 
@@ -9,14 +9,19 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"renelle/analysis"
+	"renelle/ast"
+	"renelle/compiler"
 	"renelle/evaluator"
 	"renelle/lexer"
 	"renelle/object"
 	"renelle/parser"
 	"renelle/repl"
-	"strings"
+	"renelle/vm"
 )
 
+var useVM = flag.Bool("vm", false, "run files with the bytecode vm instead of the tree-walking evaluator")
+
 func main() {
 	flag.Parse()
 
@@ -54,7 +59,6 @@ func main() {
 			filename := filepath.Join(dir, "src", "main.rnl")
 			runFile(filename, moduleName, args[1:])
 		case "test":
-			fmt.Printf("Test command not implemented yet\n")
 			var dir string
 			if len(args) > 1 {
 				dir = args[1]
@@ -62,24 +66,8 @@ func main() {
 				dir = "./test"
 			}
 
-			err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-				if err != nil {
-					return err
-				}
-
-				if !info.IsDir() && strings.HasSuffix(path, "_test.rnl") {
-					// Run the tests in the file
-					fmt.Printf("Running tests in %s\n", path)
-					// TODO: Implement the function to run the tests
-					// runTests(path)
-				}
-
-				return nil
-			})
-
-			if err != nil {
-				fmt.Printf("Error walking the path %v: %v\n", dir, err)
-				os.Exit(1)
+			if status := testCommand(os.Stdout, dir); status != 0 {
+				os.Exit(status)
 			}
 		default:
 			filename := args[0]
@@ -96,12 +84,19 @@ func main() {
 				printParserErrors(os.Stderr, p.Errors())
 				os.Exit(1)
 			}
+			printWarnings(os.Stderr, analysis.Check(program))
 
 			env := object.NewEnvironment()
 			ctx := object.NewEvalContext()
 			(*ctx.MetaData)["args"] = args[1:]
 
-			evaluator.Eval(program, env, ctx)
+			var result object.Object
+			if *useVM {
+				result = runVM(program, env, ctx)
+			} else {
+				result = evaluator.Eval(program, env, ctx)
+			}
+			exitOnError(result)
 		}
 	} else if len(args) == 0 {
 		repl.Start()
@@ -112,8 +107,29 @@ func main() {
 }
 
 func printParserErrors(out io.Writer, errors []parser.ParseError) {
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg.Message+"\n")
+	io.WriteString(out, parser.RenderErrors(errors))
+}
+
+func printWarnings(out io.Writer, warnings []analysis.Warning) {
+	io.WriteString(out, analysis.RenderWarnings(warnings))
+}
+
+func runVM(program *ast.Program, env *object.Environment, ctx *object.EvalContext) object.Object {
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	machine := vm.New(comp.Bytecode(), env, ctx)
+	return machine.Run()
+}
+
+// exitOnError prints the stack trace of a runtime error and exits.
+func exitOnError(result object.Object) {
+	if e, ok := result.(*object.Error); ok {
+		fmt.Fprint(os.Stderr, e.Trace())
+		os.Exit(1)
 	}
 }
 
@@ -151,12 +167,13 @@ func runFile(filename string, moduleName string, args []string) {
 		printParserErrors(os.Stderr, p.Errors())
 		os.Exit(1)
 	}
+	printWarnings(os.Stderr, analysis.Check(program))
 
 	env := object.NewEnvironment()
 	ctx := object.NewEvalContext()
 	(*ctx.MetaData)["args"] = args
 
-	evaluator.Eval(program, env, ctx)
+	exitOnError(evaluator.Eval(program, env, ctx))
 	module, ok := env.GetModule(moduleName)
 	if !ok {
 		fmt.Println("Module not found:", moduleName)
@@ -170,12 +187,7 @@ func runFile(filename string, moduleName string, args []string) {
 		os.Exit(1)
 	}
 
-	ret := evaluator.ApplyFunction(mainFunc, []object.Object{}, ctx)
-	if e, ok := ret.(*object.Error); ok {
-		fmt.Println(e.Message)
-		os.Exit(1)
-	}
-
+	exitOnError(evaluator.ApplyFunction(mainFunc, []object.Object{}, ctx))
 }
 
 func getModuleName(dir string, args []string) (string, error) {
@@ -7,7 +7,7 @@ import "fmt"
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
 	m := make(map[string]*Module)
-	return &Environment{store: s, modules: m, outer: nil}
+	return &Environment{store: s, modules: m, functions: make(map[string]map[int]Object), outer: nil}
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
@@ -20,6 +20,9 @@ type Environment struct {
 	store   map[string]Object
 	modules map[string]*Module
 	outer   *Environment
+
+	// the functions defined under each name, by arity
+	functions map[string]map[int]Object
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -31,10 +34,41 @@ func (e *Environment) Get(name string) (Object, bool) {
 }
 
 func (e *Environment) Set(name string, val Object) Object {
+	delete(e.functions, name)
 	e.store[name] = val
 	return val
 }
 
+// SetFunction binds a function taking arity arguments to name, keeping the
+// functions of other arities defined under the name in this environment. The
+// name is then bound to an Overloads of them all, resolved when called.
+func (e *Environment) SetFunction(name string, arity int, fn Object) Object {
+	arities, ok := e.functions[name]
+	if !ok {
+		arities = make(map[int]Object)
+		e.functions[name] = arities
+	}
+	arities[arity] = fn
+
+	if len(arities) == 1 {
+		e.store[name] = fn
+	} else {
+		overloads := &Overloads{Name: name, Arities: make(map[int]Object, len(arities))}
+		for n, f := range arities {
+			overloads.Arities[n] = f
+		}
+		e.store[name] = overloads
+	}
+	return fn
+}
+
+// Function returns the function of the given arity defined under name in
+// this environment.
+func (e *Environment) Function(name string, arity int) (Object, bool) {
+	fn, ok := e.functions[name][arity]
+	return fn, ok
+}
+
 func (e *Environment) GetModule(name string) (*Module, bool) {
 	module, ok := e.modules[name]
 	if !ok && e.outer != nil {
@@ -52,6 +86,13 @@ func (e *Environment) SetModule(name string, module *Module) *Module {
 	return module
 }
 
+// DefineModule binds a module in this environment alone, where SetModule
+// registers it for the whole program.
+func (e *Environment) DefineModule(name string, module *Module) *Module {
+	e.modules[name] = module
+	return module
+}
+
 func (e *Environment) PrintModules() {
 	for k := range e.modules {
 		fmt.Printf("mod: %s\n", k)
@@ -73,6 +114,7 @@ type EvalContext struct {
 	Line     int
 	Column   int
 	FileName string
+	Stack    []StackFrame
 }
 
 func (e *EvalContext) Copy() EvalContext {
@@ -86,9 +128,36 @@ func (e *EvalContext) Copy() EvalContext {
 		Line:     e.Line,
 		Column:   e.Column,
 		FileName: e.FileName,
+		Stack:    e.StackTrace(),
 	}
 }
 
+// PushFrame records a call to the named function, made from the current
+// position.
+func (e *EvalContext) PushFrame(function string) {
+	e.Stack = append(e.Stack, StackFrame{Function: function, FileName: e.FileName, Line: e.Line, Column: e.Column})
+}
+
+// ReplaceFrame replaces the innermost call, for a call made in tail position.
+func (e *EvalContext) ReplaceFrame(function string) {
+	e.Stack[len(e.Stack)-1] = StackFrame{Function: function, FileName: e.FileName, Line: e.Line, Column: e.Column}
+}
+
+// PopFrame removes the innermost call, moving the position back to where it
+// was made.
+func (e *EvalContext) PopFrame() {
+	frame := e.Stack[len(e.Stack)-1]
+	e.Stack = e.Stack[:len(e.Stack)-1]
+	e.FileName, e.Line, e.Column = frame.FileName, frame.Line, frame.Column
+}
+
+// StackTrace returns a copy of the calls in progress, outermost first.
+func (e *EvalContext) StackTrace() []StackFrame {
+	stack := make([]StackFrame, len(e.Stack))
+	copy(stack, e.Stack)
+	return stack
+}
+
 func NewEvalContext() *EvalContext {
 	return &EvalContext{
 		MetaData: &MetaData{
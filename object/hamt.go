@@ -0,0 +1,197 @@
+// object/hamt.go
+
+package object
+
+import (
+	"math/bits"
+	"sort"
+)
+
+const (
+	hamtBits = 5
+	hamtMask = 1<<hamtBits - 1
+)
+
+// HashTable is a persistent hash array mapped trie. Put returns a new table
+// sharing all but the path to the changed entry with the old one, so an
+// update takes O(log n) time and leaves the old table as it was. A nil
+// *HashTable is an empty table.
+type HashTable struct {
+	root   *hamtNode
+	length int
+	next   int // the order of the next key put
+}
+
+// hamtNode holds a child for each 5 bit chunk of a hash set in its bitmap,
+// packed in chunk order.
+type hamtNode struct {
+	bitmap   uint32
+	children []*hamtChild
+}
+
+// hamtChild is either a subtree, or the entries whose keys have the same
+// hash, of which there is more than one only when full hashes collide.
+type hamtChild struct {
+	node    *hamtNode
+	hash    uint64
+	entries []hamtEntry
+}
+
+type hamtEntry struct {
+	key   Object
+	value Object
+	order int // when the key was first put, for iterating in insertion order
+}
+
+// hashOf hashes a key, mixing the bits of its HashKey so that keys differing
+// only in their high bits still spread across the trie.
+func hashOf(key Object) uint64 {
+	x := key.(Hashable).HashKey().Value
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// Len returns the number of entries in the table.
+func (h *HashTable) Len() int {
+	if h == nil {
+		return 0
+	}
+	return h.length
+}
+
+// Get returns the value for key, and whether the table has it.
+func (h *HashTable) Get(key Object) (Object, bool) {
+	if h == nil || h.root == nil {
+		return nil, false
+	}
+
+	hash := hashOf(key)
+	node := h.root
+	for shift := uint(0); ; shift += hamtBits {
+		bit := uint32(1) << ((hash >> shift) & hamtMask)
+		if node.bitmap&bit == 0 {
+			return nil, false
+		}
+		child := node.children[bits.OnesCount32(node.bitmap&(bit-1))]
+		if child.node != nil {
+			node = child.node
+			continue
+		}
+		if child.hash != hash {
+			return nil, false
+		}
+		for _, entry := range child.entries {
+			if Equals(entry.key, key) {
+				return entry.value, true
+			}
+		}
+		return nil, false
+	}
+}
+
+// Put returns a table with key set to value. A key already in the table
+// keeps its place in the order of iteration.
+func (h *HashTable) Put(key, value Object) *HashTable {
+	table := &HashTable{root: &hamtNode{}}
+	if h != nil && h.root != nil {
+		*table = *h
+	}
+
+	var added bool
+	table.root, added = table.root.put(0, hashOf(key), hamtEntry{key: key, value: value, order: table.next})
+	if added {
+		table.length++
+		table.next++
+	}
+	return table
+}
+
+func (n *hamtNode) put(shift uint, hash uint64, entry hamtEntry) (*hamtNode, bool) {
+	bit := uint32(1) << ((hash >> shift) & hamtMask)
+	i := bits.OnesCount32(n.bitmap & (bit - 1))
+
+	if n.bitmap&bit == 0 {
+		children := make([]*hamtChild, len(n.children)+1)
+		copy(children, n.children[:i])
+		children[i] = &hamtChild{hash: hash, entries: []hamtEntry{entry}}
+		copy(children[i+1:], n.children[i:])
+		return &hamtNode{bitmap: n.bitmap | bit, children: children}, true
+	}
+
+	child := *n.children[i]
+	added := true
+	switch {
+	case child.node != nil:
+		child.node, added = child.node.put(shift+hamtBits, hash, entry)
+
+	case child.hash == hash:
+		entries := make([]hamtEntry, len(child.entries), len(child.entries)+1)
+		copy(entries, child.entries)
+		for j, existing := range entries {
+			if Equals(existing.key, entry.key) {
+				entry.order = existing.order
+				entries[j] = entry
+				added = false
+				break
+			}
+		}
+		if added {
+			entries = append(entries, entry)
+		}
+		child.entries = entries
+
+	default:
+		// the hashes only share their chunks so far, so both go a level down
+		next := shift + hamtBits
+		sub := &hamtNode{bitmap: uint32(1) << ((child.hash >> next) & hamtMask), children: []*hamtChild{n.children[i]}}
+		sub, _ = sub.put(next, hash, entry)
+		child = hamtChild{node: sub}
+	}
+
+	children := make([]*hamtChild, len(n.children))
+	copy(children, n.children)
+	children[i] = &child
+	return &hamtNode{bitmap: n.bitmap, children: children}, added
+}
+
+func (n *hamtNode) collect(entries []hamtEntry) []hamtEntry {
+	for _, child := range n.children {
+		if child.node != nil {
+			entries = child.node.collect(entries)
+		} else {
+			entries = append(entries, child.entries...)
+		}
+	}
+	return entries
+}
+
+// Pairs returns the entries of the table in the order their keys were first
+// put.
+func (h *HashTable) Pairs() []Pair {
+	if h == nil || h.root == nil {
+		return nil
+	}
+
+	entries := h.root.collect(make([]hamtEntry, 0, h.length))
+	sort.Slice(entries, func(i, j int) bool { return entries[i].order < entries[j].order })
+
+	pairs := make([]Pair, len(entries))
+	for i, entry := range entries {
+		pairs[i] = Pair{Key: entry.key, Value: entry.value}
+	}
+	return pairs
+}
+
+// Keys returns the keys of the table in the order they were first put.
+func (h *HashTable) Keys() []Object {
+	pairs := h.Pairs()
+	keys := make([]Object, len(pairs))
+	for i, pair := range pairs {
+		keys[i] = pair.Key
+	}
+	return keys
+}
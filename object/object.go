@@ -4,11 +4,11 @@ package object
 
 import (
 	"bytes"
-	"container/list"
 	"fmt"
 	"hash/fnv"
 	"math"
 	"renelle/ast"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -22,6 +22,7 @@ const (
 	BOOLEAN_OBJ      = "BOOLEAN"
 	ATOM_OBJ         = "ATOM"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	TAIL_CALL_OBJ    = "TAIL_CALL"
 	ERROR_OBJ        = "ERROR"
 	FUNCTION_OBJ     = "FUNCTION"
 	BUILTIN_OBJ      = "BUILTIN"
@@ -29,6 +30,9 @@ const (
 	TUPLE_OBJ        = "TUPLE"
 	MAP_OBJ          = "MAP"
 	SLICE_OBJ        = "SLICE"
+	STRUCT_OBJ       = "STRUCT"
+	STREAM_OBJ       = "STREAM"
+	RANGE_OBJ        = "RANGE"
 )
 
 type Object interface {
@@ -50,69 +54,10 @@ type HashKey struct {
 	Value uint64
 }
 
-type HashTable struct {
-	Buckets []*list.List
-	Size    int
-	Length  int
-}
-
-func NewHashTable(size int) *HashTable {
-	return &HashTable{
-		Buckets: make([]*list.List, size),
-		Size:    size,
-		Length:  0,
-	}
-}
-
 func (h *HashKey) Equals(other *HashKey) bool {
 	return h.Type == other.Type && h.Value == other.Value
 }
 
-func (h *HashTable) Put(pair Pair) {
-	hashKey := pair.Key.(Hashable).HashKey()
-	index := int(hashKey.Value % uint64(h.Size))
-	if h.Buckets[index] == nil {
-		h.Buckets[index] = list.New()
-	} else {
-		// If the key already exists, update its value
-		for e := h.Buckets[index].Front(); e != nil; e = e.Next() {
-			if Equals(pair.Key, e.Value.(Pair).Key) {
-				e.Value = pair
-				return
-			}
-		}
-	}
-	h.Length++
-	// If the key does not exist, add a new entry
-	h.Buckets[index].PushBack(pair)
-}
-
-func (h *HashTable) Get(key Object) (Object, bool) {
-	hashKey := key.(Hashable).HashKey()
-	index := int(hashKey.Value % uint64(h.Size))
-	if h.Buckets[index] == nil {
-		return nil, false
-	}
-	for e := h.Buckets[index].Front(); e != nil; e = e.Next() {
-		if Equals(key, e.Value.(Pair).Key) {
-			return e.Value.(Pair).Value, true
-		}
-	}
-	return nil, false
-}
-
-func (h *HashTable) Keys() []Object {
-	var keys []Object
-	for _, bucket := range h.Buckets {
-		if bucket != nil {
-			for e := bucket.Front(); e != nil; e = e.Next() {
-				keys = append(keys, e.Value.(Pair).Key)
-			}
-		}
-	}
-	return keys
-}
-
 type Integer struct {
 	Value int64
 }
@@ -178,11 +123,23 @@ type ReturnValue struct {
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 
+// TailCall is a call in tail position that has not been made yet. The
+// evaluator returns it from a function body so the caller can make the call
+// in place of the current one.
+type TailCall struct {
+	Fn   *Function
+	Args []Object
+}
+
+func (tc *TailCall) Inspect() string  { return "tail call" }
+func (tc *TailCall) Type() ObjectType { return TAIL_CALL_OBJ }
+
 type Error struct {
 	Message  string
 	Line     int
 	Column   int
 	FileName string
+	Stack    []StackFrame // the calls in progress when the error was raised, outermost first
 }
 
 func (e *Error) Inspect() string {
@@ -190,19 +147,109 @@ func (e *Error) Inspect() string {
 }
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 
+// Trace renders the error with the functions it was raised in, innermost
+// first. Each function is shown with the position reached in it: where the
+// error was raised for the innermost one, and where the next call was made
+// for the others.
+func (e *Error) Trace() string {
+	var out bytes.Buffer
+
+	out.WriteString(fmt.Sprintf("error: %s\n", e.Message))
+
+	fileName, line, column := e.FileName, e.Line, e.Column
+	for i := len(e.Stack) - 1; i >= 0; i-- {
+		frame := e.Stack[i]
+		out.WriteString(fmt.Sprintf("    at %s (%s:%d:%d)\n", frame.Function, fileName, line, column))
+		fileName, line, column = frame.FileName, frame.Line, frame.Column
+	}
+	out.WriteString(fmt.Sprintf("    at %s:%d:%d\n", fileName, line, column))
+
+	return out.String()
+}
+
+// StackFrame is a function call in progress: the function, and where it was
+// called from.
+type StackFrame struct {
+	Function string
+	FileName string
+	Line     int
+	Column   int
+}
+
 type Function struct {
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
+	Clauses    []*ast.FunctionClause // in place of Parameters and Body for functions matching their arguments
+	Defaults   []ast.Expression      // the default value of each parameter, nil for those without one
+	Rest       *ast.Identifier       // bound to an array of the arguments after Parameters, if any
 	Env        *Environment
+	Name       string // empty for anonymous functions
+	Module     string // the module defining the function, if any
+}
+
+// Arity is the number of arguments the function takes.
+func (f *Function) Arity() int {
+	if f.Clauses != nil {
+		return len(f.Clauses[0].Patterns)
+	}
+	return len(f.Parameters)
+}
+
+// Required is the number of arguments that must be given, the parameters
+// after them having default values.
+func (f *Function) Required() int {
+	for i, d := range f.Defaults {
+		if d != nil {
+			return i
+		}
+	}
+	return f.Arity()
+}
+
+// ParameterNames returns the names keyword arguments can be given by, which
+// functions matching their arguments against patterns do not have.
+func (f *Function) ParameterNames() []string {
+	names := make([]string, len(f.Parameters))
+	for i, p := range f.Parameters {
+		names[i] = p.Value
+	}
+	return names
+}
+
+// QualifiedName is the name the function is shown by in stack traces.
+func (f *Function) QualifiedName() string {
+	name := f.Name
+	if name == "" {
+		name = "anonymous"
+	}
+	if f.Module != "" {
+		return f.Module + "." + name
+	}
+	return name
 }
 
 func (f *Function) Inspect() string {
+	if f.Clauses != nil {
+		clauses := []string{}
+		for _, c := range f.Clauses {
+			clauses = append(clauses, c.String(f.Name))
+		}
+		return strings.Join(clauses, "\n")
+	}
+
 	var out bytes.Buffer
 
 	params := []string{}
 
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
+	for i, p := range f.Parameters {
+		if f.Defaults != nil && f.Defaults[i] != nil {
+			params = append(params, p.String()+" = "+f.Defaults[i].String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
 	}
 
 	out.WriteString("fn")
@@ -222,8 +269,259 @@ func (f *Function) HashKey() HashKey {
 	return HashKey{Type: f.Type(), Value: hasher.Sum64()}
 }
 
+// Callable is implemented by function values that are not run by the
+// evaluator itself, so host code can call them like any other function.
+type Callable interface {
+	Object
+	Call(ctx *EvalContext, args []Object) Object
+}
+
+// SourcePos maps the instruction at Offset back to the source it came from.
+type SourcePos struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+type CompiledFunction struct {
+	Instructions  []byte
+	NumLocals     int
+	NumParameters int
+	NumDefaults   int      // the last parameters, which may be left out
+	Variadic      bool     // whether the arguments past the parameters are gathered into an array
+	Parameters    []string // the names of the parameters, for keyword arguments
+	Name          string
+	FileName      string
+	Positions     []SourcePos
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return "COMPILED_FUNCTION" }
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("compiled function %s[%p]", cf.Name, cf)
+}
+
+// Accepts reports whether the function can be called with n arguments.
+func (cf *CompiledFunction) Accepts(n int) bool {
+	return n >= cf.NumParameters-cf.NumDefaults && (cf.Variadic || n <= cf.NumParameters)
+}
+
+// Position returns the source position of the instruction at ip.
+func (cf *CompiledFunction) Position(ip int) (int, int) {
+	// the first position past ip follows the one we want
+	i := sort.Search(len(cf.Positions), func(i int) bool { return cf.Positions[i].Offset > ip })
+	if i == 0 {
+		return 0, 0
+	}
+	return cf.Positions[i-1].Line, cf.Positions[i-1].Column
+}
+
+// ClosureRunner runs closures on behalf of host code. It is implemented by
+// the vm that created the closure.
+type ClosureRunner interface {
+	RunClosure(cl *Closure, args []Object, ctx *EvalContext) Object
+}
+
+type Closure struct {
+	Fn     *CompiledFunction
+	Free   []Object
+	Runner ClosureRunner
+}
+
+func (c *Closure) Type() ObjectType { return FUNCTION_OBJ }
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("fn %s/%d", c.Fn.Name, c.Fn.NumParameters)
+}
+func (c *Closure) HashKey() HashKey {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(fmt.Sprintf("%p", c)))
+	return HashKey{Type: c.Type(), Value: hasher.Sum64()}
+}
+func (c *Closure) Call(ctx *EvalContext, args []Object) Object {
+	return c.Runner.RunClosure(c, args, ctx)
+}
+
 type BuiltinFunction func(ctx *EvalContext, args ...Object) Object
 
+// Partial is a function with some of its arguments given already, made by a
+// call with `_` placeholders for the others, as in `add(_ 5)`, or by the
+// Function module. Calling it fills the placeholders with the arguments in
+// order, and passes any left over after the ones given.
+type Partial struct {
+	Fn   Object
+	Args []Object // the arguments given, nil where a placeholder is
+
+	// Curried is the number of arguments a curried function gathers before
+	// calling Fn, returning a Partial holding them until then. It is 0 for
+	// other partial applications, which call Fn straight away.
+	Curried int
+}
+
+// Placeholders is the number of arguments the partial application must be
+// called with.
+func (p *Partial) Placeholders() int {
+	n := 0
+	for _, a := range p.Args {
+		if a == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Fill returns the arguments the function is called with when the partial
+// application is called with args, or false if there are too few of them to
+// fill the placeholders.
+func (p *Partial) Fill(args []Object) ([]Object, bool) {
+	if len(args) < p.Placeholders() {
+		return nil, false
+	}
+	filled := make([]Object, 0, len(p.Args)+len(args))
+	for _, a := range p.Args {
+		if a == nil {
+			a, args = args[0], args[1:]
+		}
+		filled = append(filled, a)
+	}
+	return append(filled, args...), true
+}
+
+func (p *Partial) Inspect() string {
+	args := []string{}
+	for _, a := range p.Args {
+		if a == nil {
+			args = append(args, "_")
+		} else {
+			args = append(args, a.Inspect())
+		}
+	}
+
+	name := "fn"
+	switch fn := p.Fn.(type) {
+	case *Function:
+		name = fn.QualifiedName()
+	case *Closure:
+		name = fn.Fn.Name
+	case *Overloads:
+		name = fn.Name
+	}
+	return fmt.Sprintf("%s(%s)", name, strings.Join(args, " "))
+}
+func (p *Partial) Type() ObjectType { return FUNCTION_OBJ }
+func (p *Partial) HashKey() HashKey {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(fmt.Sprintf("%p", p)))
+	return HashKey{Type: p.Type(), Value: hasher.Sum64()}
+}
+
+// Overloads is a function defined for several numbers of arguments, as by
+// `fn range(n)` and `fn range(a b)`. A call runs the one taking as many
+// arguments as it passes.
+type Overloads struct {
+	Name    string
+	Arities map[int]Object
+}
+
+// Lookup returns the function taking n arguments, or one that can be called
+// with n by leaving out parameters with default values.
+func (o *Overloads) Lookup(n int) (Object, bool) {
+	if fn, ok := o.Arities[n]; ok {
+		return fn, true
+	}
+	for _, arity := range o.sortedArities() {
+		fn := o.Arities[arity]
+		if min, max := ArityRange(fn); n >= min && n <= max {
+			return fn, true
+		}
+	}
+	return nil, false
+}
+
+// ArityString lists the numbers of arguments the functions take, as "1 or 2".
+func (o *Overloads) ArityString() string {
+	arities := make([]string, 0, len(o.Arities))
+	for _, n := range o.sortedArities() {
+		arities = append(arities, strconv.Itoa(n))
+	}
+	return strings.Join(arities, " or ")
+}
+
+func (o *Overloads) sortedArities() []int {
+	arities := make([]int, 0, len(o.Arities))
+	for n := range o.Arities {
+		arities = append(arities, n)
+	}
+	sort.Ints(arities)
+	return arities
+}
+
+func (o *Overloads) Inspect() string {
+	fns := make([]string, 0, len(o.Arities))
+	for _, n := range o.sortedArities() {
+		fns = append(fns, fmt.Sprintf("fn %s/%d", o.Name, n))
+	}
+	return strings.Join(fns, ", ")
+}
+func (o *Overloads) Type() ObjectType { return FUNCTION_OBJ }
+func (o *Overloads) HashKey() HashKey {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(fmt.Sprintf("%p", o)))
+	return HashKey{Type: o.Type(), Value: hasher.Sum64()}
+}
+
+// ArityRange returns the least and most arguments a function defined in
+// Renelle can be called with. The most is math.MaxInt for functions with a
+// rest parameter.
+func ArityRange(fn Object) (int, int) {
+	switch fn := fn.(type) {
+	case *Function:
+		if fn.Rest != nil {
+			return fn.Required(), math.MaxInt
+		}
+		return fn.Required(), fn.Arity()
+	case *Closure:
+		if fn.Fn.Variadic {
+			return fn.Fn.NumParameters - fn.Fn.NumDefaults, math.MaxInt
+		}
+		return fn.Fn.NumParameters - fn.Fn.NumDefaults, fn.Fn.NumParameters
+	}
+	return 0, 0
+}
+
+// ArityString describes the numbers of arguments between min and max, as "2",
+// "2 to 3" or "2 or more", for errors about calls given the wrong number.
+func ArityString(min, max int) string {
+	switch max {
+	case min:
+		return strconv.Itoa(min)
+	case math.MaxInt:
+		return fmt.Sprintf("%d or more", min)
+	}
+	return fmt.Sprintf("%d to %d", min, max)
+}
+
+// Overload combines fn, which takes arity arguments, with the function
+// existing already bound to the same name. A function of the same arity is
+// replaced, and any other value than a function is.
+func Overload(name string, existing Object, fn Object, arity int) Object {
+	arities := map[int]Object{}
+	switch existing := existing.(type) {
+	case *Overloads:
+		for n, f := range existing.Arities {
+			arities[n] = f
+		}
+	case *Function:
+		arities[existing.Arity()] = existing
+	case *Closure:
+		arities[existing.Fn.NumParameters] = existing
+	}
+	arities[arity] = fn
+
+	if len(arities) == 1 {
+		return fn
+	}
+	return &Overloads{Name: name, Arities: arities}
+}
+
 type Builtin struct {
 	Fn BuiltinFunction
 }
@@ -238,6 +536,37 @@ func (b *Builtin) HashKey() HashKey {
 
 type Array struct {
 	Elements []Object
+
+	// the storage Elements is a prefix of, when the array was made by Append
+	buf *arrayBuffer
+}
+
+// arrayBuffer is storage shared by arrays appended one to another. Each holds
+// a prefix of elements, and used is the length of the longest. Only an array
+// of that length may append in place, into a part no other array can see.
+type arrayBuffer struct {
+	elements []Object
+	used     int
+}
+
+// Append returns a new array of the elements of ao followed by values,
+// leaving ao as it is. Appending to the array returned by the last Append
+// reuses its storage, so building an array one element at a time takes
+// amortized constant time per element.
+func (ao *Array) Append(values ...Object) *Array {
+	n := len(ao.Elements)
+	total := n + len(values)
+
+	buf := ao.buf
+	if buf == nil || buf.used != n || total > len(buf.elements) {
+		buf = &arrayBuffer{elements: make([]Object, 2*total)}
+		copy(buf.elements, ao.Elements)
+	}
+	copy(buf.elements[n:], values)
+	buf.used = total
+
+	// capping the capacity keeps appends from outside from writing into buf
+	return &Array{Elements: buf.elements[:total:total], buf: buf}
 }
 
 func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
@@ -309,6 +638,9 @@ func (s *Slice) Inspect() string {
 	return fmt.Sprintf("%s::%s", s.Start.Inspect(), s.End.Inspect())
 }
 
+// Map is an immutable map from hashable keys to values. Put changes the map
+// in place, for building a new one before it is used; With returns an
+// updated map, leaving the original as it was.
 type Map struct {
 	Store *HashTable
 }
@@ -318,14 +650,8 @@ func (m *Map) Inspect() string {
 	var out bytes.Buffer
 
 	elements := []string{}
-	for _, bucket := range m.Store.Buckets {
-		if bucket == nil {
-			continue
-		}
-		for e := bucket.Front(); e != nil; e = e.Next() {
-			pair := e.Value.(Pair)
-			elements = append(elements, pair.Key.Inspect()+" = "+pair.Value.Inspect())
-		}
+	for _, pair := range m.Store.Pairs() {
+		elements = append(elements, pair.Key.Inspect()+" = "+pair.Value.Inspect())
 	}
 
 	out.WriteString("{")
@@ -335,61 +661,51 @@ func (m *Map) Inspect() string {
 	return out.String()
 }
 
+// HashKey does not depend on the order of the entries, so equal maps hash
+// the same.
 func (m *Map) HashKey() HashKey {
-	hasher := fnv.New64a()
-	for _, bucket := range m.Store.Buckets {
-		if bucket != nil {
-
-			for e := bucket.Front(); e != nil; e = e.Next() {
-				pair := e.Value.(Pair)
-				key, keyOk := pair.Key.(Hashable)
-				value, valueOk := pair.Value.(Hashable)
-				if keyOk && valueOk {
-					keyHash := key.HashKey().Value
-					valueHash := value.HashKey().Value
-					hasher.Write([]byte(fmt.Sprintf("%s%d%s%d", key.HashKey().Type, keyHash, value.HashKey().Type, valueHash)))
-				}
-			}
+	var sum uint64
+	for _, pair := range m.Store.Pairs() {
+		key, keyOk := pair.Key.(Hashable)
+		value, valueOk := pair.Value.(Hashable)
+		if keyOk && valueOk {
+			hasher := fnv.New64a()
+			keyHash := key.HashKey()
+			valueHash := value.HashKey()
+			hasher.Write([]byte(fmt.Sprintf("%s%d%s%d", keyHash.Type, keyHash.Value, valueHash.Type, valueHash.Value)))
+			sum += hasher.Sum64()
 		}
 	}
-	return HashKey{Type: m.Type(), Value: hasher.Sum64()}
+	return HashKey{Type: m.Type(), Value: sum}
 }
 
 func (m *Map) Get(key Object) (Object, bool) {
-	if len(m.Store.Buckets) == 0 {
-		return nil, false
-	}
-	hashKey := key.(Hashable).HashKey()
-	index := int(hashKey.Value % uint64(len(m.Store.Buckets)))
-	if m.Store.Buckets[index] == nil {
-		return nil, false
-	}
-	for e := m.Store.Buckets[index].Front(); e != nil; e = e.Next() {
-		if Equals(key, e.Value.(Pair).Key) {
-			return e.Value.(Pair).Value, true
-		}
-	}
-	return nil, false
+	return m.Store.Get(key)
 }
 
 func (m *Map) Put(key, value Object) {
-	m.Store.Put(Pair{Key: key, Value: value})
+	m.Store = m.Store.Put(key, value)
 }
 
-func (m *Map) Copy(newItems int) *Map {
-	hashTableSize := m.Store.Size + newItems
-	hashTable := NewHashTable(hashTableSize)
-	for _, key := range m.Store.Keys() {
-		value, _ := m.Store.Get(key)
-		hashTable.Put(Pair{Key: key, Value: value})
-	}
-	return &Map{Store: hashTable}
+// With returns a map with key set to value, sharing the rest of m.
+func (m *Map) With(key, value Object) *Map {
+	return &Map{Store: m.Store.Put(key, value)}
 }
 
+func (m *Map) Len() int {
+	return m.Store.Len()
+}
+
+// Keys returns the keys of the map in the order they were added.
 func (m *Map) Keys() []Object {
 	return m.Store.Keys()
 }
 
+// Pairs returns the entries of the map in the order they were added.
+func (m *Map) Pairs() []Pair {
+	return m.Store.Pairs()
+}
+
 type Env interface {
 	Get(name string) (Object, bool)
 	Set(name string, val Object) Object
@@ -398,6 +714,7 @@ type Env interface {
 type Module struct {
 	Name        string
 	Environment Env
+	Struct      *StructDefinition // nil if the module declares no struct
 }
 
 func (m *Module) Type() ObjectType { return "MODULE" }
@@ -405,6 +722,72 @@ func (m *Module) Inspect() string {
 	return fmt.Sprintf("module %s", m.Name)
 }
 
+// StructDefinition holds the fields a module's struct was declared with.
+type StructDefinition struct {
+	Module string
+	Fields []string
+}
+
+func (sd *StructDefinition) HasField(name string) bool {
+	for _, f := range sd.Fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+type Struct struct {
+	Module string
+	Fields []string // declaration order, shared with the definition
+	Values map[string]Object
+}
+
+func (s *Struct) Type() ObjectType { return STRUCT_OBJ }
+func (s *Struct) Inspect() string {
+	var out bytes.Buffer
+
+	fields := []string{}
+	for _, f := range s.Fields {
+		fields = append(fields, f+" = "+s.Values[f].Inspect())
+	}
+
+	out.WriteString(s.Module)
+	out.WriteString("{")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+func (s *Struct) HashKey() HashKey {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(s.Module))
+	for _, f := range s.Fields {
+		if value, ok := s.Values[f].(Hashable); ok {
+			hasher.Write([]byte(fmt.Sprintf("%s%d", f, value.HashKey().Value)))
+		}
+	}
+	return HashKey{Type: s.Type(), Value: hasher.Sum64()}
+}
+
+func (s *Struct) Get(field string) (Object, bool) {
+	value, ok := s.Values[field]
+	return value, ok
+}
+
+// With returns a copy of the struct with the given fields replaced.
+func (s *Struct) With(values map[string]Object) *Struct {
+	newValues := make(map[string]Object, len(s.Values))
+	for k, v := range s.Values {
+		newValues[k] = v
+	}
+	for k, v := range values {
+		newValues[k] = v
+	}
+	return &Struct{Module: s.Module, Fields: s.Fields, Values: newValues}
+}
+
 func Equals(a, b Object) bool {
 	switch a := a.(type) {
 	case *Integer:
@@ -426,6 +809,9 @@ func Equals(a, b Object) bool {
 		b, ok := b.(*ReturnValue)
 		return ok && Equals(a.Value, b.Value)
 	case *Array:
+		if r, ok := b.(*Range); ok {
+			return r.equalsArray(a)
+		}
 		b, ok := b.(*Array)
 		if !ok || len(a.Elements) != len(b.Elements) {
 			return false
@@ -442,6 +828,12 @@ func Equals(a, b Object) bool {
 	case *Builtin:
 		b, ok := b.(*Builtin)
 		return ok && a == b
+	case *Closure:
+		b, ok := b.(*Closure)
+		return ok && a == b
+	case *Partial:
+		b, ok := b.(*Partial)
+		return ok && a == b
 	case *Tuple:
 		b, ok := b.(*Tuple)
 		if !ok || len(a.Elements) != len(b.Elements) {
@@ -454,30 +846,44 @@ func Equals(a, b Object) bool {
 		}
 		return true
 
-	case *Map:
-		b, ok := b.(*Map)
-		if !ok || len(a.Store.Buckets) != len(b.Store.Buckets) {
+	case *Struct:
+		b, ok := b.(*Struct)
+		if !ok || a.Module != b.Module || len(a.Values) != len(b.Values) {
 			return false
 		}
-		for i, bucketA := range a.Store.Buckets {
-			bucketB := b.Store.Buckets[i]
-			if (bucketA == nil && bucketB != nil) || (bucketA != nil && bucketB == nil) {
+		for field, value := range a.Values {
+			if !Equals(value, b.Values[field]) {
 				return false
 			}
-			if bucketA != nil && bucketB != nil && bucketA.Len() != bucketB.Len() {
+		}
+		return true
+	case *Map:
+		b, ok := b.(*Map)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		for _, pair := range a.Pairs() {
+			value, ok := b.Get(pair.Key)
+			if !ok || !Equals(pair.Value, value) {
 				return false
 			}
-			if bucketA != nil && bucketB != nil {
-				for eA, eB := bucketA.Front(), bucketB.Front(); eA != nil && eB != nil; eA, eB = eA.Next(), eB.Next() {
-					pairA := eA.Value.(Pair)
-					pairB := eB.Value.(Pair)
-					if !Equals(pairA.Key, pairB.Key) || !Equals(pairA.Value, pairB.Value) {
-						return false
-					}
-				}
-			}
 		}
 		return true
+	case *Range:
+		if arr, ok := b.(*Array); ok {
+			return a.equalsArray(arr)
+		}
+		b, ok := b.(*Range)
+		if !ok {
+			return false
+		}
+		// ranges are equal when they have the same elements
+		last, nonEmpty := a.lastIndex()
+		bLast, bNonEmpty := b.lastIndex()
+		if !nonEmpty || !bNonEmpty {
+			return nonEmpty == bNonEmpty
+		}
+		return last == bLast && a.First == b.First && (last == 0 || a.Step == b.Step)
 	default:
 		return false
 	}
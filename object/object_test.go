@@ -7,7 +7,7 @@ import (
 )
 
 func TestMap(t *testing.T) {
-	m := &Map{Store: NewHashTable(10)}
+	m := &Map{}
 
 	key1 := &String{Value: "key1"}
 	value1 := &Integer{Value: 1}
@@ -29,9 +29,7 @@ func TestMap(t *testing.T) {
 }
 
 func TestHashIndependence(t *testing.T) {
-	m := &Map{
-		Store: NewHashTable(1000),
-	}
+	m := &Map{}
 
 	// Integer and String with similar value
 	integer123 := &String{Value: "Integer 123"}
@@ -84,3 +82,161 @@ func TestHashIndependence(t *testing.T) {
 		t.Errorf("Expected 'String true', got '%v'", val)
 	}
 }
+
+func TestErrorTrace(t *testing.T) {
+	err := &Error{
+		Message:  "type mismatch: INTEGER + STRING",
+		FileName: "math.rnl",
+		Line:     4,
+		Column:   7,
+		Stack: []StackFrame{
+			{Function: "main", FileName: "main.rnl", Line: 10, Column: 1},
+			{Function: "Math.add", FileName: "main.rnl", Line: 2, Column: 13},
+		},
+	}
+
+	expected := `error: type mismatch: INTEGER + STRING
+    at Math.add (math.rnl:4:7)
+    at main (main.rnl:2:13)
+    at main.rnl:10:1
+`
+	if err.Trace() != expected {
+		t.Errorf("wrong trace.\nwant=%q\ngot=%q", expected, err.Trace())
+	}
+}
+
+func TestEvalContextFrames(t *testing.T) {
+	ctx := NewEvalContext()
+	ctx.FileName, ctx.Line, ctx.Column = "main.rnl", 3, 5
+	ctx.PushFrame("f")
+
+	ctx.FileName, ctx.Line, ctx.Column = "main.rnl", 8, 2
+	ctx.ReplaceFrame("g")
+
+	trace := ctx.StackTrace()
+	if len(trace) != 1 || trace[0] != (StackFrame{Function: "g", FileName: "main.rnl", Line: 8, Column: 2}) {
+		t.Fatalf("wrong stack. got=%+v", trace)
+	}
+
+	ctx.Line, ctx.Column = 20, 20
+	ctx.PopFrame()
+	if len(ctx.Stack) != 0 || ctx.Line != 8 || ctx.Column != 2 {
+		t.Errorf("popping a frame should return to its call site. got=%d:%d", ctx.Line, ctx.Column)
+	}
+}
+
+func TestArrayAppend(t *testing.T) {
+	one := (&Array{}).Append(&Integer{Value: 1})
+	two := one.Append(&Integer{Value: 2})
+	three := one.Append(&Integer{Value: 3})
+	four := two.Append(&Integer{Value: 4}, &Integer{Value: 5})
+
+	// appending from outside must copy rather than write into shared storage
+	_ = append(one.Elements, &Integer{Value: 9})
+
+	tests := []struct {
+		array    *Array
+		expected string
+	}{
+		{one, "[1]"},
+		{two, "[1 2]"},
+		{three, "[1 3]"},
+		{four, "[1 2 4 5]"},
+	}
+
+	for _, tt := range tests {
+		if tt.array.Inspect() != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, tt.array.Inspect())
+		}
+	}
+}
+
+func BenchmarkArrayAppend(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		arr := &Array{}
+		for j := 0; j < 100_000; j++ {
+			arr = arr.Append(&Integer{Value: int64(j)})
+		}
+	}
+}
+
+func TestHashTablePersistence(t *testing.T) {
+	var versions []*HashTable
+	var table *HashTable
+	for i := 0; i < 5000; i++ {
+		table = table.Put(&Integer{Value: int64(i)}, &Integer{Value: int64(i * 2)})
+		versions = append(versions, table)
+	}
+	updated := table.Put(&Integer{Value: 10}, &String{Value: "ten"})
+
+	if table.Len() != 5000 || updated.Len() != 5000 {
+		t.Fatalf("wrong lengths. got=%d and %d", table.Len(), updated.Len())
+	}
+	for i, version := range versions {
+		if version.Len() != i+1 {
+			t.Fatalf("version %d has length %d", i, version.Len())
+		}
+		if _, ok := version.Get(&Integer{Value: int64(i + 1)}); ok {
+			t.Fatalf("version %d has a key added after it", i)
+		}
+	}
+	for i := 0; i < 5000; i++ {
+		v, ok := table.Get(&Integer{Value: int64(i)})
+		if !ok || v.(*Integer).Value != int64(i*2) {
+			t.Fatalf("wrong value for %d. got=%v", i, v)
+		}
+	}
+	if v, _ := table.Get(&Integer{Value: 10}); v.Inspect() != "20" {
+		t.Errorf("update changed the old table. got=%s", v.Inspect())
+	}
+	if v, _ := updated.Get(&Integer{Value: 10}); v.Inspect() != `"ten"` {
+		t.Errorf("wrong updated value. got=%s", v.Inspect())
+	}
+}
+
+func TestHashTableCollisions(t *testing.T) {
+	// Integer 1 and true have the same HashKey value
+	table := (*HashTable)(nil).
+		Put(&Integer{Value: 1}, &String{Value: "one"}).
+		Put(&Boolean{Value: true}, &String{Value: "true"})
+
+	if table.Len() != 2 {
+		t.Fatalf("wrong length. got=%d", table.Len())
+	}
+	if v, _ := table.Get(&Integer{Value: 1}); v.Inspect() != `"one"` {
+		t.Errorf("wrong value for 1. got=%s", v.Inspect())
+	}
+	if v, _ := table.Get(&Boolean{Value: true}); v.Inspect() != `"true"` {
+		t.Errorf("wrong value for true. got=%s", v.Inspect())
+	}
+}
+
+func TestMapOrder(t *testing.T) {
+	a := &Map{}
+	a.Put(&Atom{Value: "b"}, &Integer{Value: 1})
+	a.Put(&Atom{Value: "a"}, &Integer{Value: 2})
+	a = a.With(&Atom{Value: "b"}, &Integer{Value: 3})
+
+	b := &Map{}
+	b.Put(&Atom{Value: "a"}, &Integer{Value: 2})
+	b.Put(&Atom{Value: "b"}, &Integer{Value: 3})
+
+	if a.Inspect() != "{:b = 3, :a = 2}" {
+		t.Errorf("wrong order. got=%s", a.Inspect())
+	}
+	if !Equals(a, b) {
+		t.Errorf("maps with the same entries are not equal")
+	}
+	if a.HashKey() != b.HashKey() {
+		t.Errorf("maps with the same entries hash differently")
+	}
+}
+
+func BenchmarkMapWith(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		m := &Map{}
+		for j := 0; j < 100_000; j++ {
+			m = m.With(&Integer{Value: int64(j)}, &Integer{Value: int64(j)})
+		}
+	}
+}
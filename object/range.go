@@ -0,0 +1,158 @@
+// object/range.go
+
+package object
+
+import (
+	"fmt"
+	"math"
+)
+
+// MaxRangeArray is the most elements of a range that are copied into an
+// array, for the operations that need every element at once. It is well
+// short of what could exhaust memory or overflow a slice.
+const MaxRangeArray = math.MaxInt32
+
+// Range is the integers from First to Last inclusive, counting by Step,
+// written `first..last` or `first..last//step`. Its elements are never
+// stored, so its length and whether it holds a number take constant time.
+type Range struct {
+	First int64
+	Last  int64
+	Step  int64 // never 0
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string {
+	if r.Step == 1 {
+		return fmt.Sprintf("%d..%d", r.First, r.Last)
+	}
+	return fmt.Sprintf("%d..%d//%d", r.First, r.Last, r.Step)
+}
+
+// Len returns the number of elements in the range, reporting false if
+// there are more than an int64 holds, as in the range of every int64.
+func (r *Range) Len() (int64, bool) {
+	last, ok := r.lastIndex()
+	switch {
+	case !ok:
+		return 0, true
+	case last >= math.MaxInt64:
+		return 0, false
+	}
+	return int64(last) + 1, true
+}
+
+// lastIndex returns the index of the last element, reporting false if the
+// range is empty. The distance between two int64s always fits in a uint64,
+// so unlike Len it cannot overflow.
+func (r *Range) lastIndex() (uint64, bool) {
+	switch {
+	case r.Step > 0 && r.First <= r.Last:
+		return (uint64(r.Last) - uint64(r.First)) / uint64(r.Step), true
+	case r.Step < 0 && r.First >= r.Last:
+		return (uint64(r.First) - uint64(r.Last)) / -uint64(r.Step), true
+	}
+	return 0, false
+}
+
+// At returns the element at index i, which must be at most the last index.
+func (r *Range) At(i uint64) int64 {
+	// wraps around in uint64, where it is still exact for elements that fit
+	return int64(uint64(r.First) + i*uint64(r.Step))
+}
+
+// Index returns the element at index i, counting back from the end if i is
+// negative as in an array, reporting false if there is none.
+func (r *Range) Index(i int64) (int64, bool) {
+	last, ok := r.lastIndex()
+	if !ok {
+		return 0, false
+	}
+	u := uint64(i)
+	if i < 0 {
+		back := -u - 1 // -1 is the last element
+		if back > last {
+			return 0, false
+		}
+		u = last - back
+	}
+	if u > last {
+		return 0, false
+	}
+	return r.At(u), true
+}
+
+// Contains reports whether n is one of the elements of the range.
+func (r *Range) Contains(n int64) bool {
+	switch {
+	case r.Step > 0 && r.First <= n && n <= r.Last:
+		return (uint64(n)-uint64(r.First))%uint64(r.Step) == 0
+	case r.Step < 0 && r.Last <= n && n <= r.First:
+		return (uint64(r.First)-uint64(n))%-uint64(r.Step) == 0
+	}
+	return false
+}
+
+// Array returns the elements of the range in an array, reporting false if
+// there are more than MaxRangeArray of them.
+func (r *Range) Array() (*Array, bool) {
+	n, ok := r.Len()
+	if !ok || n > MaxRangeArray {
+		return nil, false
+	}
+	elements := make([]Object, n)
+	for i := range elements {
+		elements[i] = &Integer{Value: r.At(uint64(i))}
+	}
+	return &Array{Elements: elements}, true
+}
+
+// equalsArray reports whether an array holds the elements of the range.
+func (r *Range) equalsArray(arr *Array) bool {
+	n, ok := r.Len()
+	if !ok || n != int64(len(arr.Elements)) {
+		return false
+	}
+	for i, el := range arr.Elements {
+		if n, ok := el.(*Integer); !ok || n.Value != r.At(uint64(i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Split returns the first n elements of an array or range and the array or
+// range of those after them, which is nil if there are none. It reports
+// false if obj is neither or has fewer than n elements. The rest of a range
+// is a range, so splitting one copies only the first n.
+func Split(obj Object, n int) ([]Object, Object, bool) {
+	switch obj := obj.(type) {
+	case *Array:
+		if len(obj.Elements) < n {
+			return nil, nil, false
+		}
+		if len(obj.Elements) == n {
+			return obj.Elements, nil, true
+		}
+		rest := make([]Object, len(obj.Elements)-n)
+		copy(rest, obj.Elements[n:])
+		return obj.Elements[:n], &Array{Elements: rest}, true
+	case *Range:
+		last, ok := obj.lastIndex()
+		switch {
+		case !ok:
+			return nil, nil, n == 0
+		case n > 0 && last < uint64(n-1):
+			return nil, nil, false
+		}
+		head := make([]Object, n)
+		for i := range head {
+			head[i] = &Integer{Value: obj.At(uint64(i))}
+		}
+		if n > 0 && last == uint64(n-1) {
+			return head, nil, true
+		}
+		return head, &Range{First: obj.At(uint64(n)), Last: obj.Last, Step: obj.Step}, true
+	}
+	return nil, nil, false
+}
@@ -0,0 +1,54 @@
+// object/stream.go
+
+package object
+
+// Iterator yields the elements of a sequence one at a time. It returns the
+// next element and true, or false once there are none left. If producing an
+// element fails, the element is the *Error and iteration should stop there.
+type Iterator func(ctx *EvalContext) (Object, bool)
+
+// Stream is a lazily evaluated sequence. Nothing is computed until it is
+// iterated, and then only as many elements as are asked for. Start begins a
+// new pass over the elements, so a stream can be iterated more than once.
+type Stream struct {
+	Start func() Iterator
+}
+
+func (s *Stream) Type() ObjectType { return STREAM_OBJ }
+func (s *Stream) Inspect() string  { return "#Stream" }
+
+// Iterate starts iterating over an array, a range or a stream, reporting
+// false for other values.
+func Iterate(obj Object) (Iterator, bool) {
+	switch obj := obj.(type) {
+	case *Array:
+		elements := obj.Elements
+		return func(*EvalContext) (Object, bool) {
+			if len(elements) == 0 {
+				return nil, false
+			}
+			el := elements[0]
+			elements = elements[1:]
+			return el, true
+		}, true
+	case *Range:
+		// counting up to the last index, as the length can overflow
+		last, ok := obj.lastIndex()
+		var i uint64
+		done := !ok
+		return func(*EvalContext) (Object, bool) {
+			if done {
+				return nil, false
+			}
+			el := &Integer{Value: obj.At(i)}
+			if i == last {
+				done = true
+			}
+			i++
+			return el, true
+		}, true
+	case *Stream:
+		return obj.Start(), true
+	}
+	return nil, false
+}
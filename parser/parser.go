@@ -5,6 +5,7 @@ package parser
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"unicode"
 
 	"renelle/ast"
@@ -19,6 +20,7 @@ const (
 	AND         // and
 	EQUALS      // ==
 	LESSGREATER // > or <
+	RANGE       // 1..10
 	SUM         // +
 	PRODUCT     // *
 	EXPONENT    // **
@@ -29,29 +31,33 @@ const (
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:        EQUALS,
-	token.NEQ:       EQUALS,
-	token.ARRAY_EQ:  EQUALS,
-	token.ARRAY_NEQ: EQUALS,
-	token.LT:        LESSGREATER,
-	token.GT:        LESSGREATER,
-	token.LTE:       LESSGREATER,
-	token.GTE:       LESSGREATER,
-	token.PLUS:      SUM,
-	token.MINUS:     SUM,
-	token.CONCAT:    SUM,
-	token.SLASH:     PRODUCT,
-	token.ASTERISK:  PRODUCT,
-	token.MOD:       PRODUCT,
-	token.POW:       EXPONENT,
-	token.PIPE:      CALL,
-	token.LPAREN:    CALL,
-	token.FUNCCALL:  CALL,
-	token.OR:        OR,
-	token.AND:       AND,
-	token.AT:        INDEX,
-	token.DOTDOT:    INDEX,
-	token.DOT:       ACCESS,
+	token.EQ:         EQUALS,
+	token.NEQ:        EQUALS,
+	token.ARRAY_EQ:   EQUALS,
+	token.ARRAY_NEQ:  EQUALS,
+	token.LT:         LESSGREATER,
+	token.GT:         LESSGREATER,
+	token.LTE:        LESSGREATER,
+	token.GTE:        LESSGREATER,
+	token.IN:         LESSGREATER,
+	token.DOTDOT:     RANGE,
+	token.STEP:       RANGE,
+	token.PLUS:       SUM,
+	token.MINUS:      SUM,
+	token.CONCAT:     SUM,
+	token.SLASH:      PRODUCT,
+	token.ASTERISK:   PRODUCT,
+	token.MOD:        PRODUCT,
+	token.POW:        EXPONENT,
+	token.PIPE:       CALL,
+	token.LPAREN:     CALL,
+	token.FUNCCALL:   CALL,
+	token.OR:         OR,
+	token.AND:        AND,
+	token.AT:         INDEX,
+	token.COLONCOLON: INDEX,
+	token.DOT:        ACCESS,
+	token.QUESTION:   ACCESS,
 }
 
 type (
@@ -60,15 +66,65 @@ type (
 )
 
 type ParseError struct {
-	Message string
-	Line    int
-	Column  int
+	Message  string
+	FileName string
+	Line     int
+	Column   int
+	Source   string // the source line the error is on
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.FileName, e.Line, e.Column, e.Message)
+}
+
+// Render formats the error as a diagnostic showing the offending source line
+// with a caret under the column the error is at.
+func (e ParseError) Render() string {
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("%s:%d:%d: error: %s\n", e.FileName, e.Line, e.Column, e.Message))
+	if e.Source == "" {
+		return out.String()
+	}
+
+	gutter := strconv.Itoa(e.Line)
+	pad := strings.Repeat(" ", len(gutter))
+	out.WriteString(fmt.Sprintf(" %s |\n", pad))
+	out.WriteString(fmt.Sprintf(" %s | %s\n", gutter, e.Source))
+
+	// keep tabs so the caret lines up however wide they are shown
+	caret := []byte{}
+	for i := 0; i < e.Column-1 && i < len(e.Source); i++ {
+		if e.Source[i] == '\t' {
+			caret = append(caret, '\t')
+		} else {
+			caret = append(caret, ' ')
+		}
+	}
+	out.WriteString(fmt.Sprintf(" %s | %s^\n", pad, caret))
+
+	return out.String()
+}
+
+// RenderErrors renders each error, separated by blank lines.
+func RenderErrors(errors []ParseError) string {
+	rendered := make([]string, len(errors))
+	for i, e := range errors {
+		rendered[i] = e.Render()
+	}
+	return strings.Join(rendered, "\n")
 }
 
 type Parser struct {
 	l      *lexer.Lexer
 	errors []ParseError
 
+	// panicking is set once a statement fails to parse, so the errors that
+	// follow from it are not reported until the parser has synchronized.
+	panicking bool
+	// blocks counts the blocks being parsed, so synchronize knows whether a
+	// } it comes to closes one or is left over from the broken statement.
+	blocks int
+
 	curToken     token.Token
 	peekToken    token.Token
 	peekTokenTwo token.Token
@@ -94,9 +150,12 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.COND, p.parseCondExpression)
 	p.registerPrefix(token.CASE, p.parseCaseExpression)
+	p.registerPrefix(token.WITH, p.parseWithExpression)
 	p.registerPrefix(token.BACKSLASH, p.parseFunctionLiteral)
 	p.registerPrefix(token.FUNCCALL, p.parseCallExpression)
 	p.registerPrefix(token.ATOM, p.parseAtom)
+	p.registerPrefix(token.CARET, p.parsePinExpression)
+	p.registerPrefix(token.ELLIPSIS, p.parseSpreadExpression)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseMapLiteral)
 
@@ -121,7 +180,11 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.OR, p.parseInfixExpression)
 	p.registerInfix(token.AT, p.parseIndexExpression)
 	p.registerInfix(token.DOT, p.parsePropertyAccessExpression)
+	p.registerInfix(token.COLONCOLON, p.parseInfixExpression)
 	p.registerInfix(token.DOTDOT, p.parseInfixExpression)
+	p.registerInfix(token.STEP, p.parseInfixExpression)
+	p.registerInfix(token.IN, p.parseInfixExpression)
+	p.registerInfix(token.QUESTION, p.parseTryExpression)
 
 	p.nextToken()
 	p.nextToken()
@@ -154,8 +217,9 @@ func (p *Parser) ParseProgram() *ast.Program {
 	for p.curToken.Type != token.EOF {
 		stmt := p.parseStatement()
 		if stmt != nil {
-			program.Statements = append(program.Statements, stmt)
+			program.Statements = appendStatement(program.Statements, stmt)
 		}
+		p.synchronize()
 		p.nextToken()
 	}
 
@@ -172,6 +236,12 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseFunctionStatement()
 	case token.MODULE:
 		return p.parseModule()
+	case token.STRUCT:
+		p.syntaxError(p.curToken, "struct can only be declared inside a module")
+		return nil
+	case token.SEMICOLON:
+		// an optional end to the statement before it
+		return nil
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -184,7 +254,11 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	switch p.curToken.Type {
 	case token.IDENT:
-		stmt.Left = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if unicode.IsUpper(rune(p.curToken.Literal[0])) {
+			stmt.Left = p.parseIdentifier()
+		} else {
+			stmt.Left = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		}
 	case token.LPAREN:
 		stmt.Left = p.parseGroupedExpression()
 	case token.LBRACKET:
@@ -198,6 +272,11 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
+	if startsStatement(p.peekToken.Type) {
+		// leave the next statement for synchronize to resume at
+		p.syntaxError(p.peekToken, "expected a value after =, got %s instead", p.peekToken.Type)
+		return nil
+	}
 
 	p.nextToken()
 
@@ -226,6 +305,7 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
+		p.syntaxError(p.curToken, "no prefix parse function for %s", p.curToken.Type)
 		return nil
 	}
 	leftExp := prefix()
@@ -263,9 +343,48 @@ func (p *Parser) parseIdentifier() ast.Expression {
 		}
 	}
 	identifier := &ast.Identifier{Token: initialToken, Value: identifierValue}
+
+	// A module name followed by braces is a struct literal, e.g. MyApp.Dog{name = "Fido"}
+	if unicode.IsUpper(rune(identifierValue[0])) && p.peekTokenIs(token.LBRACE) &&
+		(p.peekTokenTwoIs(token.IDENT) || p.peekTokenTwoIs(token.ATOM) || p.peekTokenTwoIs(token.RBRACE)) {
+		return p.parseStructLiteral(identifier)
+	}
+
 	return identifier
 }
 
+func (p *Parser) parseStructLiteral(name *ast.Identifier) ast.Expression {
+	lit := &ast.StructLiteral{Token: name.Token, Name: name}
+	p.nextToken() // {
+
+	for !p.peekTokenIs(token.RBRACE) && !p.peekTokenIs(token.EOF) {
+		p.nextToken()
+
+		field := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		switch p.curToken.Type {
+		case token.ATOM:
+			// name: value
+		case token.IDENT:
+			if !p.expectPeek(token.ASSIGN) {
+				return nil
+			}
+		default:
+			p.syntaxError(p.curToken, "expected field name in %s, got %s instead", name.Value, p.curToken.Type)
+			return nil
+		}
+
+		p.nextToken()
+		lit.Fields = append(lit.Fields, field)
+		lit.Values = append(lit.Values, p.parseExpression(LOWEST))
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return lit
+}
+
 func (p *Parser) parseAtom() ast.Expression {
 	return &ast.AtomLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
@@ -275,8 +394,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, ParseError{Message: msg, Line: p.curToken.Line, Column: p.curToken.Column})
+		p.syntaxError(p.curToken, "could not parse %q as integer", p.curToken.Literal)
 		return nil
 	}
 
@@ -361,8 +479,7 @@ func (p *Parser) parseFloatLitearl() ast.Expression {
 
 	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
-		p.errors = append(p.errors, ParseError{Message: msg, Line: p.curToken.Line, Column: p.curToken.Column})
+		p.syntaxError(p.curToken, "could not parse %q as float", p.curToken.Literal)
 		return nil
 	}
 
@@ -372,17 +489,25 @@ func (p *Parser) parseFloatLitearl() ast.Expression {
 
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
-	p.nextToken()
 
 	elements := []ast.Expression{}
 
-	for !p.curTokenIs(token.RBRACKET) && !p.curTokenIs(token.EOF) {
-		element := p.parseExpression(LOWEST)
-		elements = append(elements, element)
+	for !p.peekTokenIs(token.RBRACKET) && !p.peekTokenIs(token.BAR) && !p.peekEndsList() {
 		p.nextToken()
+		elements = append(elements, p.parseExpression(LOWEST))
 	}
 	array.Elements = elements
 
+	if p.peekTokenIs(token.BAR) {
+		p.nextToken()
+		p.nextToken()
+		array.Rest = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectClosing(array.Token, token.RBRACKET) {
+		return nil
+	}
+
 	return array
 
 }
@@ -395,13 +520,14 @@ func (p *Parser) parseMapLiteral() ast.Expression {
 	mapLiteral := &ast.MapLiteral{Token: p.curToken}
 	mapLiteral.Pairs = make(map[ast.Expression]ast.Expression)
 
-	for !p.peekTokenIs(token.RBRACE) && !p.peekTokenIs(token.EOF) {
+	for !p.peekEndsList() {
 		p.nextToken()
 		key := p.parseExpression(LOWEST)
 		if _, ok := key.(*ast.AtomLiteral); ok && !p.peekTokenIs(token.ASSIGN) {
 			// If the key is an AtomLiteral and the next token is not ASSIGN, parse the key as an Atom
 			p.nextToken()
 			val := p.parseExpression(LOWEST)
+			mapLiteral.Keys = append(mapLiteral.Keys, key)
 			mapLiteral.Pairs[key] = val
 		} else {
 			// If the key is not an AtomLiteral or the next token is ASSIGN, parse the key as a String
@@ -410,6 +536,7 @@ func (p *Parser) parseMapLiteral() ast.Expression {
 			}
 			p.nextToken()
 			value := p.parseExpression(LOWEST)
+			mapLiteral.Keys = append(mapLiteral.Keys, key)
 			mapLiteral.Pairs[key] = value
 		}
 	}
@@ -437,6 +564,7 @@ func (p *Parser) parseMapUpdateLiteral() ast.Expression {
 		}
 		p.nextToken()
 		val := p.parseExpression(LOWEST)
+		mapUpdate.Keys = append(mapUpdate.Keys, key)
 		pairs[key] = val
 	}
 	p.nextToken()
@@ -457,6 +585,26 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parsePinExpression() ast.Expression {
+	pin := &ast.PinExpression{Token: p.curToken}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	pin.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return pin
+}
+
+func (p *Parser) parseSpreadExpression() ast.Expression {
+	spread := &ast.SpreadExpression{Token: p.curToken}
+	p.nextToken()
+	spread.Value = p.parseExpression(PREFIX)
+	return spread
+}
+
+func (p *Parser) parseTryExpression(left ast.Expression) ast.Expression {
+	return &ast.TryExpression{Token: p.curToken, Value: left}
+}
+
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	expression := &ast.InfixExpression{
 		Token:    p.curToken,
@@ -484,12 +632,12 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 	}
 
 	elements := []ast.Expression{expression}
-	for !p.peekTokenIs(token.RPAREN) && !p.peekTokenIs(token.EOF) {
+	for !p.peekTokenIs(token.RPAREN) && !p.peekEndsList() {
 		p.nextToken()
 		elements = append(elements, p.parseExpression(LOWEST))
 	}
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectClosing(initialToken, token.RPAREN) {
 		return nil
 	}
 
@@ -501,7 +649,13 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 
 	p.nextToken()
 
-	expression.Index = p.parseExpression(p.curPrecedence())
+	// a negative index reads like a number, so `xs @ -1..0//-1` takes the
+	// whole range just as `xs @ 1..3` does
+	precedence := p.curPrecedence()
+	if p.curTokenIs(token.MINUS) {
+		precedence = LOWEST
+	}
+	expression.Index = p.parseExpression(precedence)
 
 	return expression
 }
@@ -580,38 +734,132 @@ func (p *Parser) parseCaseExpression() ast.Expression {
 		return nil
 	}
 
+	conditions, guards, consequences, ok := p.parseCaseClauses()
+	if !ok {
+		return nil
+	}
+	expression.Conditions = conditions
+	expression.Guards = guards
+	expression.Consequences = consequences
+
+	return expression
+
+}
+
+// parseCaseClauses parses `pattern [when guard] => consequence` clauses up to
+// the closing brace, starting on the opening one.
+func (p *Parser) parseCaseClauses() ([]ast.Expression, []ast.Expression, []*ast.BlockStatement, bool) {
+	var conditions, guards []ast.Expression
+	var consequences []*ast.BlockStatement
+
 	for !p.peekTokenIs(token.RBRACE) && !p.peekTokenIs(token.EOF) {
 		p.nextToken()
 		condition := p.parseExpression(LOWEST)
-		expression.Conditions = append(expression.Conditions, condition)
+		conditions = append(conditions, condition)
+
+		var guard ast.Expression
+		if p.peekTokenIs(token.WHEN) {
+			p.nextToken()
+			p.nextToken()
+			guard = p.parseExpression(LOWEST)
+		}
+		guards = append(guards, guard)
 
 		if !p.expectPeek(token.ARROW) {
-			return nil
+			return nil, nil, nil, false
 		}
 
 		p.nextToken()
 
 		if p.curTokenIs(token.LBRACE) {
 			consequence := p.parseBlockStatement()
-			expression.Consequences = append(expression.Consequences, consequence)
+			consequences = append(consequences, consequence)
 		} else {
 			consequence := p.parseExpression(LOWEST)
 			expr := &ast.ExpressionStatement{Token: p.curToken, Expression: consequence}
-			expression.Consequences = append(expression.Consequences, &ast.BlockStatement{Statements: []ast.Statement{expr}})
+			consequences = append(consequences, &ast.BlockStatement{Statements: []ast.Statement{expr}})
 
 		}
 
 	}
 
 	if !p.expectPeek(token.RBRACE) {
+		return nil, nil, nil, false
+	}
+
+	return conditions, guards, consequences, true
+}
+
+func (p *Parser) parseWithExpression() ast.Expression {
+	expression := &ast.WithExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
+	expression.Body = p.parseWithBody()
+
+	if p.peekTokenIs(token.ELSE) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		conditions, guards, consequences, ok := p.parseCaseClauses()
+		if !ok {
+			return nil
+		}
+		if conditions == nil {
+			p.syntaxError(p.curToken, "else of a with needs at least one clause")
+			return nil
+		}
+		expression.Conditions = conditions
+		expression.Guards = guards
+		expression.Consequences = consequences
+	}
+
 	return expression
+}
+
+// parseWithBody parses a block in which a statement followed by `<-` is the
+// pattern of a match step.
+func (p *Parser) parseWithBody() *ast.BlockStatement {
+	p.blocks++
+	defer func() { p.blocks-- }()
+
+	block := &ast.BlockStatement{Token: p.curToken}
+	block.Statements = []ast.Statement{}
+
+	p.nextToken()
+
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		stmt := p.parseStatement()
+		if es, ok := stmt.(*ast.ExpressionStatement); ok && p.peekTokenIs(token.LARROW) {
+			p.nextToken()
+			step := &ast.MatchStep{Token: p.curToken, Pattern: es.Expression}
+			p.nextToken()
+			step.Value = p.parseExpression(LOWEST)
+			stmt = step
+		}
+		if stmt != nil {
+			block.Statements = appendStatement(block.Statements, stmt)
+		}
+		p.synchronize()
+		p.nextToken()
+	}
+
+	if !p.curTokenIs(token.RBRACE) {
+		p.syntaxError(p.curToken, "expected }, got %s instead", p.curToken.Type)
+	}
 
+	return block
 }
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	p.blocks++
+	defer func() { p.blocks-- }()
+
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
 
@@ -620,11 +868,16 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
 		if stmt != nil {
-			block.Statements = append(block.Statements, stmt)
+			block.Statements = appendStatement(block.Statements, stmt)
 		}
+		p.synchronize()
 		p.nextToken()
 	}
 
+	if !p.curTokenIs(token.RBRACE) {
+		p.syntaxError(p.curToken, "expected }, got %s instead", p.curToken.Type)
+	}
+
 	return block
 }
 
@@ -647,7 +900,7 @@ func (p *Parser) parsePropertyAccessExpression(left ast.Expression) ast.Expressi
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.curToken}
 
-	lit.Parameters = p.parseFunctionParameters()
+	lit.Parameters = p.parseFunctionParameters(lit)
 
 	p.nextToken()
 
@@ -662,7 +915,9 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+// parseFunctionParameters parses the parameters of a lambda, setting the
+// rest parameter given as `...name` on lit.
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) []*ast.Identifier {
 	identifiers := []*ast.Identifier{}
 
 	if p.peekTokenIs(token.ARROW) {
@@ -670,13 +925,16 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 		return identifiers
 	}
 
-	p.nextToken()
-
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
-
 	for !p.peekTokenIs(token.ARROW) && !p.peekTokenIs(token.EOF) {
 		p.nextToken()
+		if p.curTokenIs(token.ELLIPSIS) {
+			// the rest parameter is the last, so the arrow must follow
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			lit.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			break
+		}
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 		identifiers = append(identifiers, ident)
 	}
@@ -703,41 +961,182 @@ func (p *Parser) parseFunctionStatement() *ast.FunctionStatement {
 		return nil
 	}
 
-	stmt.Parameters = p.parseFunctionStatementParameters()
+	patterns, defaults := p.parseFunctionStatementParameters()
+	if patterns == nil {
+		return nil
+	}
+	rest, patterns, ok := p.restParameter(patterns)
+	if !ok {
+		return nil
+	}
+	if rest != nil && defaults != nil {
+		defaults = defaults[:len(patterns)]
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
-	stmt.Body = p.parseBlockStatement()
+	body := p.parseBlockStatement()
+
+	if params, ok := plainParameters(patterns); ok {
+		stmt.Parameters = params
+		stmt.Body = body
+		stmt.Defaults = defaults
+		stmt.Rest = rest
+	} else if defaults != nil {
+		p.syntaxError(stmt.Token, "default values can only be given to plain parameters, not patterns")
+		return nil
+	} else if rest != nil {
+		p.syntaxError(rest.Token, "a rest parameter can only follow plain parameters, not patterns")
+		return nil
+	} else {
+		stmt.Clauses = []*ast.FunctionClause{{Token: stmt.Token, Patterns: patterns, Body: body}}
+	}
 
 	return stmt
 }
 
-func (p *Parser) parseFunctionStatementParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// parseFunctionStatementParameters parses the parameters of a function
+// statement, which are patterns its arguments are matched against, and the
+// default values given as `name = value`. The defaults are nil if there are
+// none.
+func (p *Parser) parseFunctionStatementParameters() ([]ast.Expression, []ast.Expression) {
+	patterns := []ast.Expression{}
+	var defaults []ast.Expression
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return patterns, nil
 	}
 
-	p.nextToken()
+	for {
+		p.nextToken()
+		pattern := p.parseExpression(LOWEST)
+		if pattern == nil {
+			return nil, nil
+		}
+		if !isPattern(pattern) {
+			p.syntaxError(pattern.T(), "parameter %s is not a pattern", pattern.String())
+			return nil, nil
+		}
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+		var value ast.Expression
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			if value = p.parseExpression(LOWEST); value == nil {
+				return nil, nil
+			}
+			if defaults == nil {
+				defaults = make([]ast.Expression, len(patterns))
+			}
+		} else if _, rest := pattern.(*ast.SpreadExpression); defaults != nil && !rest {
+			p.syntaxError(pattern.T(), "parameter %s without a default can not follow one with a default", pattern.String())
+			return nil, nil
+		}
 
-	for p.peekTokenIs(token.IDENT) {
-		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+		patterns = append(patterns, pattern)
+		if defaults != nil {
+			defaults = append(defaults, value)
+		}
+		if p.peekTokenIs(token.RPAREN) || p.peekEndsList() {
+			break
+		}
 	}
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return nil, nil
 	}
 
-	return identifiers
+	return patterns, defaults
+}
+
+// restParameter takes the rest parameter written as `...name` off the end of
+// the parameters of a function statement.
+func (p *Parser) restParameter(patterns []ast.Expression) (*ast.Identifier, []ast.Expression, bool) {
+	for i, pattern := range patterns {
+		spread, ok := pattern.(*ast.SpreadExpression)
+		if !ok {
+			continue
+		}
+		name, ok := spread.Value.(*ast.Identifier)
+		if !ok || i != len(patterns)-1 {
+			p.syntaxError(spread.Token, "a rest parameter must be a name, and the last parameter")
+			return nil, nil, false
+		}
+		return name, patterns[:i], true
+	}
+	return nil, patterns, true
+}
+
+// plainParameters returns the patterns as parameters if they are all names.
+func plainParameters(patterns []ast.Expression) ([]*ast.Identifier, bool) {
+	params := make([]*ast.Identifier, len(patterns))
+	for i, pattern := range patterns {
+		ident, ok := pattern.(*ast.Identifier)
+		if !ok {
+			return nil, false
+		}
+		params[i] = ident
+	}
+	return params, true
+}
+
+// isPattern reports whether a parameter is a pattern: a name, a literal, a
+// pinned name, or a tuple, array, map or struct of patterns. It catches
+// parameters such as `a -1`, which reads as `a - 1` rather than as two.
+func isPattern(exp ast.Expression) bool {
+	switch exp := exp.(type) {
+	case *ast.Identifier, *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.Boolean, *ast.AtomLiteral, *ast.PinExpression, *ast.SpreadExpression:
+		return true
+	case *ast.PrefixExpression:
+		switch exp.Right.(type) {
+		case *ast.IntegerLiteral, *ast.FloatLiteral:
+			return exp.Operator == "-"
+		}
+		return false
+	case *ast.InfixExpression:
+		return exp.Operator == "in" && isPattern(exp.Left)
+	case *ast.TupleLiteral:
+		return allPatterns(exp.Elements)
+	case *ast.ArrayLiteral:
+		return allPatterns(exp.Elements) && (exp.Rest == nil || isPattern(exp.Rest))
+	case *ast.MapLiteral:
+		for _, key := range exp.Keys {
+			if !isPattern(exp.Pairs[key]) {
+				return false
+			}
+		}
+		return true
+	case *ast.StructLiteral:
+		return allPatterns(exp.Values)
+	}
+	return false
+}
+
+func allPatterns(exps []ast.Expression) bool {
+	for _, exp := range exps {
+		if !isPattern(exp) {
+			return false
+		}
+	}
+	return true
+}
+
+// appendStatement appends a statement to a list of them. A function statement
+// following one of the same name and arity is added to it as a clause when
+// either has a parameter that is a pattern rather than a name, and neither has
+// default values or a rest parameter. Otherwise it redefines the function.
+func appendStatement(stmts []ast.Statement, stmt ast.Statement) []ast.Statement {
+	if next, ok := stmt.(*ast.FunctionStatement); ok && next != nil && next.Defaults == nil && next.Rest == nil && len(stmts) > 0 {
+		prev, ok := stmts[len(stmts)-1].(*ast.FunctionStatement)
+		if ok && prev != nil && prev.Defaults == nil && prev.Rest == nil && prev.Name.Value == next.Name.Value && prev.Arity() == next.Arity() && (prev.Clauses != nil || next.Clauses != nil) {
+			prev.AddClauses(next)
+			return stmts
+		}
+	}
+	return append(stmts, stmt)
 }
 
 func (p *Parser) parseCallExpression() ast.Expression {
@@ -747,31 +1146,72 @@ func (p *Parser) parseCallExpression() ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: identifier}
 
 	p.nextToken()
+	open := p.curToken
 	p.nextToken()
 
 	if p.curTokenIs(token.RPAREN) {
 		exp.Arguments = []ast.Expression{}
 		return exp
 	}
-	exp.Arguments = p.parseCallArguments()
+	exp.Arguments = p.parseCallArguments(open, exp)
 	return exp
 }
 
-func (p *Parser) parseCallArguments() []ast.Expression {
+// parseCallArguments parses the arguments of a call after the ( at open,
+// adding those given as `name = value` to its keyword arguments.
+func (p *Parser) parseCallArguments(open token.Token, call *ast.CallExpression) []ast.Expression {
 	args := []ast.Expression{}
 
 	for {
-		args = append(args, p.parseExpression(LOWEST))
-		if p.peekTokenIs(token.RPAREN) || p.peekTokenIs(token.EOF) {
+		if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.ASSIGN) {
+			name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			p.nextToken()
+			p.nextToken()
+			call.KeywordNames = append(call.KeywordNames, name)
+			call.KeywordValues = append(call.KeywordValues, p.parseExpression(LOWEST))
+		} else if call.KeywordNames != nil {
+			p.syntaxError(p.curToken, "positional argument %s can not follow keyword arguments", p.curToken.Literal)
+			return nil
+		} else {
+			args = append(args, p.parseExpression(LOWEST))
+		}
+		if p.peekTokenIs(token.RPAREN) || p.peekEndsList() {
 			break
 		}
 		p.nextToken() // Move to the next argument
 	}
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectClosing(open, token.RPAREN) {
 		return nil
 	}
 
+	if call.KeywordNames != nil {
+		for _, arg := range args {
+			if spread, ok := arg.(*ast.SpreadExpression); ok {
+				p.syntaxError(spread.Token, "spread arguments can not be combined with keyword arguments")
+				return nil
+			}
+		}
+	}
+
+	// the arguments a partial application takes are told by their position
+	for _, arg := range args {
+		if !ast.IsPlaceholder(arg) {
+			continue
+		}
+		for _, other := range args {
+			if spread, ok := other.(*ast.SpreadExpression); ok {
+				p.syntaxError(spread.Token, "spread arguments can not be combined with _ placeholders")
+				return nil
+			}
+		}
+		if call.KeywordNames != nil {
+			p.syntaxError(call.KeywordNames[0].Token, "keyword arguments can not be combined with _ placeholders")
+			return nil
+		}
+		break
+	}
+
 	return args
 }
 
@@ -792,17 +1232,54 @@ func (p *Parser) parseModule() *ast.Module {
 
 	// Parse the body of the module
 	moduleBody := []ast.Statement{}
+	hasStruct := false
 	for !p.peekTokenIs(token.EOF) {
-		stmt := p.parseStatement()
+		var stmt ast.Statement
+		if p.curTokenIs(token.STRUCT) {
+			if hasStruct {
+				p.addError(p.curToken, "module %s already has a struct", moduleName.Value)
+			}
+			hasStruct = true
+			if s := p.parseStructStatement(); s != nil {
+				stmt = s
+			}
+		} else {
+			stmt = p.parseStatement()
+		}
 		if stmt != nil {
-			moduleBody = append(moduleBody, stmt)
+			moduleBody = appendStatement(moduleBody, stmt)
 		}
+		p.synchronize()
 		p.nextToken()
 	}
 
 	return &ast.Module{Token: tok, Name: moduleName, Body: moduleBody}
 }
 
+func (p *Parser) parseStructStatement() *ast.StructStatement {
+	stmt := &ast.StructStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	for p.peekTokenIs(token.IDENT) {
+		p.nextToken()
+		if seen[p.curToken.Literal] {
+			p.addError(p.curToken, "duplicate struct field %s", p.curToken.Literal)
+		}
+		seen[p.curToken.Literal] = true
+		stmt.Fields = append(stmt.Fields, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return stmt
+}
+
 func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
 }
@@ -815,6 +1292,18 @@ func (p *Parser) peekTokenTwoIs(t token.TokenType) bool {
 	return p.peekTokenTwo.Type == t
 }
 
+// peekEndsList reports whether the peek token cannot continue a list of
+// arguments or elements: a closing bracket, a statement keyword or the end
+// of the input.
+func (p *Parser) peekEndsList() bool {
+	switch p.peekToken.Type {
+	case token.RPAREN, token.RBRACKET, token.RBRACE, token.EOF,
+		token.LET, token.FUNCTION, token.RETURN, token.MODULE, token.STRUCT:
+		return true
+	}
+	return false
+}
+
 func (p *Parser) expectPeek(t token.TokenType) bool {
 	if p.peekTokenIs(t) {
 		p.nextToken()
@@ -825,9 +1314,87 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
+// expectClosing is expectPeek for the bracket closing one opened at open.
+// An unclosed bracket can swallow the lines after it before the parser
+// notices, so the error points back at where it was opened.
+func (p *Parser) expectClosing(open token.Token, t token.TokenType) bool {
+	if p.peekTokenIs(t) {
+		p.nextToken()
+		return true
+	}
+	p.syntaxError(open, "%s is never closed: expected %s, got %s instead", open.Literal, t, p.peekToken.Type)
+	return false
+}
+
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("line %d, col%d: expected %s, got %s instead", p.peekToken.Line, p.peekToken.Column, t, p.peekToken.Type)
-	p.errors = append(p.errors, ParseError{Message: msg, Line: p.peekToken.Line, Column: p.peekToken.Column})
+	p.syntaxError(p.peekToken, "expected %s, got %s instead", t, p.peekToken.Type)
+}
+
+// addError records an error at tok that the parser can carry on from.
+func (p *Parser) addError(tok token.Token, format string, a ...interface{}) {
+	p.errors = append(p.errors, ParseError{
+		Message:  fmt.Sprintf(format, a...),
+		FileName: tok.FileName,
+		Line:     tok.Line,
+		Column:   tok.Column,
+		Source:   p.l.SourceLine(tok.Line),
+	})
+}
+
+// syntaxError records an error at tok that leaves the current statement
+// unparseable. Anything reported after it, before the parser synchronizes,
+// follows from the same mistake and is dropped.
+func (p *Parser) syntaxError(tok token.Token, format string, a ...interface{}) {
+	if p.panicking {
+		return
+	}
+	p.addError(tok, format, a...)
+	p.panicking = true
+}
+
+// synchronize recovers after a statement that failed to parse. The rest of
+// it is skipped, up to the start of the next statement, the next line that
+// starts an expression, or the brace closing the enclosing block, so parsing
+// can carry on and report later errors.
+func (p *Parser) synchronize() {
+	if !p.panicking {
+		return
+	}
+
+	depth := 0
+	line := p.curToken.Line
+	for !p.peekTokenIs(token.EOF) {
+		if depth == 0 && p.peekToken.Line > line && p.prefixParseFns[p.peekToken.Type] != nil {
+			p.panicking = false
+			return
+		}
+		switch p.peekToken.Type {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth == 0 && p.blocks > 0 {
+				p.panicking = false
+				return
+			}
+			depth = max(depth-1, 0)
+		default:
+			if depth == 0 && startsStatement(p.peekToken.Type) {
+				p.panicking = false
+				return
+			}
+		}
+		p.nextToken()
+	}
+	p.panicking = false
+}
+
+// startsStatement reports whether a token can only begin a statement.
+func startsStatement(t token.TokenType) bool {
+	switch t {
+	case token.LET, token.FUNCTION, token.RETURN, token.MODULE, token.STRUCT:
+		return true
+	}
+	return false
 }
 
 func (p *Parser) peekPrecedence() int {
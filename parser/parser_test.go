@@ -377,6 +377,13 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b@2 b@1 2 * [1, 2]@1)",
 			"add((a * (b @ 2)) (b @ 1) (2 * ([1 2] @ 1)))",
 		}, {"!(true == true)", "(!(true == true))"},
+		{"1..n - 1", "(1 .. (n - 1))"},
+		{"1..10 // 2", "((1 .. 10) // 2)"},
+		{"x in 1..9 and y", "((x in (1 .. 9)) and y)"},
+		{"a @ 1::2", "(a @ (1 :: 2))"},
+		{"a @ (1..3) + 1", "((a @ (1 .. 3)) + 1)"},
+		{"a @ 1..3", "(a @ (1 .. 3))"},
+		{"a @ -1..0 // -1", "(a @ (((-1) .. 0) // (-1)))"},
 	}
 
 	for _, tt := range tests {
@@ -1491,6 +1498,399 @@ func TestCaseExpression(t *testing.T) {
 		t.Fatalf("consequences does not contain 2 consequences. got=%d", len(caseExpr.Consequences))
 	}
 }
+
+func TestCaseGuard(t *testing.T) {
+	input := `
+    case result {
+        (:ok n) when n > 0 and n < 10 => n
+        (:ok n) => 0
+    }
+    `
+
+	l := lexer.New(input, "test")
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	caseExpr, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CaseExpression)
+	if !ok {
+		t.Fatalf("expression is not ast.CaseExpression. got=%T", program.Statements[0])
+	}
+
+	if len(caseExpr.Guards) != 2 {
+		t.Fatalf("guards does not contain 2 entries. got=%d", len(caseExpr.Guards))
+	}
+	if caseExpr.Guards[0] == nil || caseExpr.Guards[0].String() != "((n > 0) and (n < 10))" {
+		t.Errorf("wrong guard. got=%v", caseExpr.Guards[0])
+	}
+	if caseExpr.Guards[1] != nil {
+		t.Errorf("second clause should have no guard. got=%s", caseExpr.Guards[1])
+	}
+}
+
+func TestArrayRestPattern(t *testing.T) {
+	l := lexer.New("case xs { [h | t] => h }", "test")
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	caseExpr, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CaseExpression)
+	if !ok {
+		t.Fatalf("expression is not ast.CaseExpression. got=%T", program.Statements[0])
+	}
+
+	array, ok := caseExpr.Conditions[0].(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("condition is not ast.ArrayLiteral. got=%T", caseExpr.Conditions[0])
+	}
+	if len(array.Elements) != 1 || array.Rest == nil {
+		t.Fatalf("wrong array pattern. got=%s", array)
+	}
+	if array.String() != "[h | t]" {
+		t.Errorf("wrong string. got=%s", array.String())
+	}
+}
+
+func TestPinExpression(t *testing.T) {
+	l := lexer.New("case x { (:ok ^y) => y }", "test")
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	caseExpr, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CaseExpression)
+	if !ok {
+		t.Fatalf("expression is not ast.CaseExpression. got=%T", program.Statements[0])
+	}
+
+	tuple, ok := caseExpr.Conditions[0].(*ast.TupleLiteral)
+	if !ok {
+		t.Fatalf("condition is not ast.TupleLiteral. got=%T", caseExpr.Conditions[0])
+	}
+	pin, ok := tuple.Elements[1].(*ast.PinExpression)
+	if !ok {
+		t.Fatalf("element is not ast.PinExpression. got=%T", tuple.Elements[1])
+	}
+	if pin.Name.Value != "y" || pin.String() != "^y" {
+		t.Errorf("wrong pin. got=%s", pin)
+	}
+}
+
+func TestFunctionClauses(t *testing.T) {
+	input := `
+    fn fact(0) { 1 }
+    fn fact(n) { n * fact(n - 1) }
+    fn fact(a b) { a }
+    fn other((:ok v)) { v }
+    fn other(w) { w }
+    fn again(x) { 1 }
+    fn again(y) { 2 }
+    `
+
+	l := lexer.New(input, "test")
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 5 {
+		t.Fatalf("program does not contain 5 statements. got=%d", len(program.Statements))
+	}
+
+	fact := program.Statements[0].(*ast.FunctionStatement)
+	if len(fact.Clauses) != 2 || fact.Parameters != nil || fact.Body != nil {
+		t.Fatalf("fact/1 is not made of 2 clauses. got=%s", fact)
+	}
+	if _, ok := fact.Clauses[0].Patterns[0].(*ast.IntegerLiteral); !ok {
+		t.Errorf("first pattern is not ast.IntegerLiteral. got=%T", fact.Clauses[0].Patterns[0])
+	}
+	if _, ok := fact.Clauses[1].Patterns[0].(*ast.Identifier); !ok {
+		t.Errorf("second pattern is not ast.Identifier. got=%T", fact.Clauses[1].Patterns[0])
+	}
+
+	// a different arity is a different function
+	fact2 := program.Statements[1].(*ast.FunctionStatement)
+	if fact2.Clauses != nil || len(fact2.Parameters) != 2 {
+		t.Errorf("fact/2 should have plain parameters. got=%s", fact2)
+	}
+
+	other := program.Statements[2].(*ast.FunctionStatement)
+	if len(other.Clauses) != 2 || other.Arity() != 1 {
+		t.Errorf("other should be 2 clauses of arity 1. got=%s", other)
+	}
+
+	// with only names for parameters, a second fn redefines the first
+	for i, name := range []string{"x", "y"} {
+		again := program.Statements[3+i].(*ast.FunctionStatement)
+		if again.Clauses != nil || again.Parameters[0].Value != name {
+			t.Errorf("again should have the plain parameter %s. got=%s", name, again)
+		}
+	}
+}
+
+func TestDefaultAndKeywordArguments(t *testing.T) {
+	input := `
+    fn pad(s n times = 1) { s }
+    pad("a" 1 times = 2)
+    `
+
+	l := lexer.New(input, "test")
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	fn := program.Statements[0].(*ast.FunctionStatement)
+	if len(fn.Parameters) != 3 || len(fn.Defaults) != 3 || fn.Defaults[0] != nil || fn.Defaults[2] == nil {
+		t.Fatalf("only times should have a default. got=%s", fn)
+	}
+	if fn.String() != "fn pad(s n times = 1) s" {
+		t.Errorf("fn.String() wrong. got=%q", fn.String())
+	}
+
+	call := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if len(call.Arguments) != 2 || len(call.KeywordNames) != 1 || call.KeywordNames[0].Value != "times" {
+		t.Fatalf("call should have 2 arguments and the keyword times. got=%s", call)
+	}
+	if call.String() != "pad(a 1 times = 2)" {
+		t.Errorf("call.String() wrong. got=%q", call.String())
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"fn f(a = 1 b) { a }", "parameter b without a default can not follow one with a default"},
+		{"fn f((:ok v) = 1) { v }", "default values can only be given to plain parameters, not patterns"},
+		{"f(a = 1 2)", "positional argument 2 can not follow keyword arguments"},
+		{"fn h(a -1) { a }", "parameter (a - 1) is not a pattern"},
+		{"fn h([x f(1)]) { x }", "parameter [x f(1)] is not a pattern"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input, "test"))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser error for %q", tt.input)
+			continue
+		}
+
+		if p.Errors()[0].Message != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, p.Errors()[0].Message)
+		}
+	}
+}
+
+func TestRestParametersAndSpread(t *testing.T) {
+	input := `
+    fn log(level ...parts) { parts }
+    let first = \x ...more => x
+    log(:info ...args 1)
+    `
+
+	l := lexer.New(input, "test")
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("program does not contain 3 statements. got=%d", len(program.Statements))
+	}
+
+	fn := program.Statements[0].(*ast.FunctionStatement)
+	if len(fn.Parameters) != 1 || fn.Rest == nil || fn.Rest.Value != "parts" {
+		t.Fatalf("log should take level and the rest parameter parts. got=%s", fn)
+	}
+	if fn.String() != "fn log(level ...parts) parts" {
+		t.Errorf("fn.String() wrong. got=%q", fn.String())
+	}
+
+	lambda := program.Statements[1].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if len(lambda.Parameters) != 1 || lambda.Rest == nil || lambda.Rest.Value != "more" {
+		t.Fatalf("lambda should take x and the rest parameter more. got=%s", lambda)
+	}
+
+	call := program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if len(call.Arguments) != 3 {
+		t.Fatalf("call should have 3 arguments. got=%d", len(call.Arguments))
+	}
+	if spread, ok := call.Arguments[1].(*ast.SpreadExpression); !ok || spread.String() != "...args" {
+		t.Errorf("second argument is not ...args. got=%T (%s)", call.Arguments[1], call.Arguments[1])
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"fn f(...rest a) { a }", "a rest parameter must be a name, and the last parameter"},
+		{"fn f(...[a]) { a }", "a rest parameter must be a name, and the last parameter"},
+		{"fn f((:ok v) ...rest) { v }", "a rest parameter can only follow plain parameters, not patterns"},
+		{"f(...xs a = 1)", "spread arguments can not be combined with keyword arguments"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input, "test"))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser error for %q", tt.input)
+			continue
+		}
+
+		if p.Errors()[0].Message != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, p.Errors()[0].Message)
+		}
+	}
+}
+
+func TestPartialApplication(t *testing.T) {
+	input := `
+    add(_ 5)
+    x |> sub(10 _)
+    `
+
+	l := lexer.New(input, "test")
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	call := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if call.Placeholders() != 1 || !ast.IsPlaceholder(call.Arguments[0]) {
+		t.Errorf("first argument of add should be a placeholder. got=%s", call)
+	}
+
+	pipe := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	piped := pipe.Right.(*ast.CallExpression).Piped(pipe.Left)
+	if piped.String() != "sub(10 x)" {
+		t.Errorf("piped call wrong. got=%q", piped.String())
+	}
+	if pipe.Right.String() != "sub(10 _)" {
+		t.Errorf("piping changed the call. got=%q", pipe.Right.String())
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"f(_ ...xs)", "spread arguments can not be combined with _ placeholders"},
+		{"f(_ a = 1)", "keyword arguments can not be combined with _ placeholders"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input, "test"))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser error for %q", tt.input)
+			continue
+		}
+
+		if p.Errors()[0].Message != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, p.Errors()[0].Message)
+		}
+	}
+}
+
+func TestWithExpression(t *testing.T) {
+	input := `
+    with {
+        (:ok f) <- File.open(path)
+        let name = f.name
+        (:ok text) <- File.read(f)
+        String.upper(text)
+    } else {
+        (:error e) when e == :enoent => "missing"
+        (:error e) => e
+    }
+    with { (:ok x) <- f() }
+    `
+
+	l := lexer.New(input, "test")
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	withExpr, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.WithExpression)
+	if !ok {
+		t.Fatalf("expression is not ast.WithExpression. got=%T", program.Statements[0])
+	}
+
+	if len(withExpr.Body.Statements) != 4 {
+		t.Fatalf("body does not contain 4 statements. got=%d", len(withExpr.Body.Statements))
+	}
+
+	step, ok := withExpr.Body.Statements[0].(*ast.MatchStep)
+	if !ok {
+		t.Fatalf("body.Statements[0] is not ast.MatchStep. got=%T", withExpr.Body.Statements[0])
+	}
+	if step.Pattern.String() != "(ok f)" {
+		t.Errorf("wrong pattern. got=%s", step.Pattern)
+	}
+	if _, ok := withExpr.Body.Statements[1].(*ast.LetStatement); !ok {
+		t.Errorf("body.Statements[1] is not ast.LetStatement. got=%T", withExpr.Body.Statements[1])
+	}
+	if _, ok := withExpr.Body.Statements[2].(*ast.MatchStep); !ok {
+		t.Errorf("body.Statements[2] is not ast.MatchStep. got=%T", withExpr.Body.Statements[2])
+	}
+	if _, ok := withExpr.Body.Statements[3].(*ast.ExpressionStatement); !ok {
+		t.Errorf("body.Statements[3] is not ast.ExpressionStatement. got=%T", withExpr.Body.Statements[3])
+	}
+
+	if len(withExpr.Conditions) != 2 {
+		t.Fatalf("else does not contain 2 clauses. got=%d", len(withExpr.Conditions))
+	}
+	if withExpr.Guards[0] == nil || withExpr.Guards[1] != nil {
+		t.Errorf("wrong guards. got=%v", withExpr.Guards)
+	}
+
+	bare := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.WithExpression)
+	if bare.Conditions != nil {
+		t.Errorf("with without else has clauses. got=%v", bare.Conditions)
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"File.open(path)?", "(File.open(path)?)"},
+		{"-f(x)?", "(-(f(x)?))"},
+		{"a + (b)?", "(a + (b?))"},
+		{"xs @ f(i)?", "(xs @ (f(i)?))"},
+		{"empty?(xs)", "empty?(xs)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input, "test")
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
 func TestMultilineCaseExpression(t *testing.T) {
 	input := `
     case x {
@@ -1725,3 +2125,196 @@ func testMapUpdateLiteral(t *testing.T, ml *ast.MapUpdateLiteral) {
 		}
 	}
 }
+
+func TestParseStructStatement(t *testing.T) {
+	input := `
+    module MyApp.Dog
+
+    struct {
+        name
+        age
+    }
+
+    fn bark(dog) { dog.name }
+    `
+
+	l := lexer.New(input, "test")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	module, ok := program.Statements[0].(*ast.Module)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.Module. got=%T", program.Statements[0])
+	}
+
+	if len(module.Body) != 2 {
+		t.Fatalf("module.Body does not contain 2 statements. got=%d", len(module.Body))
+	}
+
+	stmt, ok := module.Body[0].(*ast.StructStatement)
+	if !ok {
+		t.Fatalf("module.Body[0] is not ast.StructStatement. got=%T", module.Body[0])
+	}
+
+	if stmt.String() != "struct {name age}" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
+
+func TestParseStructStatementErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct { name }", "struct can only be declared inside a module"},
+		{"module Dog\nstruct { name name }\n", "duplicate struct field name"},
+		{"module Dog\nstruct { name }\nstruct { age }\n", "module Dog already has a struct"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input, "test")
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser error for %q", tt.input)
+			continue
+		}
+
+		if p.Errors()[0].Message != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, p.Errors()[0].Message)
+		}
+	}
+}
+
+func TestParseStructLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`MyApp.Dog{name = "Fido" age = 3}`, "MyApp.Dog{name = Fido age = 3}"},
+		{`MyApp.Dog{name: "Fido", age: 3}`, "MyApp.Dog{name = Fido age = 3}"},
+		{`Point{}`, "Point{}"},
+		{`Point{x = Point{x = 1}}`, "Point{x = Point{x = 1}}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input, "test")
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		lit, ok := stmt.Expression.(*ast.StructLiteral)
+		if !ok {
+			t.Fatalf("exp is not ast.StructLiteral. got=%T", stmt.Expression)
+		}
+
+		if lit.String() != tt.expected {
+			t.Errorf("lit.String() wrong. expected=%q, got=%q", tt.expected, lit.String())
+		}
+	}
+}
+
+func TestParseErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = add(1 2}\nlet y = 2\n", []string{"1:12: ( is never closed: expected ), got } instead"}},
+		{"let x = add(1 2\nlet y = [1 2\nlet z = 3\n", []string{
+			"1:12: ( is never closed: expected ), got LET instead",
+			"2:9: [ is never closed: expected ], got LET instead",
+		}},
+		{"fn f() {\n  let a = (1 2\n}\nfn g() {\n  h(1 2]\n}\n", []string{
+			"2:11: ( is never closed: expected ), got } instead",
+			"5:4: ( is never closed: expected ), got ] instead",
+		}},
+		{"fn f() {\n  1 + 2\n", []string{"3:1: expected }, got EOF instead"}},
+		{"module Dog\nlet x = add(1\nstruct { name }\n", []string{"2:12: ( is never closed: expected ), got STRUCT instead"}},
+		{"let x = 1 +\n", []string{"2:1: no prefix parse function for EOF"}},
+		{"let x = (1 +\n  y\n  z\nlet w = 2\n", []string{"1:9: ( is never closed: expected ), got LET instead"}},
+		{"1 + * 2\n3 + ) 4\nlet x = 5\n", []string{
+			"1:5: no prefix parse function for *",
+			"2:5: no prefix parse function for )",
+		}},
+		{"fn f() {\n  1 + * 2\n  [1 2 3] @ ]\n}\n", []string{
+			"2:7: no prefix parse function for *",
+			"3:13: no prefix parse function for ]",
+		}},
+		{"\"hello\"; 1 + 2;\n", []string{}},
+		{"let x =\nfn f() {\n  1 + * 2\n}\n", []string{
+			"2:1: expected a value after =, got FUNCTION instead",
+			"3:7: no prefix parse function for *",
+		}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input, "test")
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("wrong number of errors for %q. want=%d, got=%d: %v", tt.input, len(tt.expected), len(errors), errors)
+			continue
+		}
+
+		for i, expected := range tt.expected {
+			got := fmt.Sprintf("%d:%d: %s", errors[i].Line, errors[i].Column, errors[i].Message)
+			if got != expected {
+				t.Errorf("wrong error. want=%q, got=%q", expected, got)
+			}
+		}
+	}
+}
+
+func TestParseErrorRecoveryKeepsStatements(t *testing.T) {
+	input := `
+let x = add(1 2}
+fn f() {
+  let y = [1 2
+  y
+}
+let z = 3
+`
+	l := lexer.New(input, "test")
+	p := New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 2 {
+		t.Fatalf("expected 2 errors. got=%d: %v", len(p.Errors()), p.Errors())
+	}
+
+	last, ok := program.Statements[len(program.Statements)-1].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("last statement is not *ast.LetStatement. got=%T", program.Statements[len(program.Statements)-1])
+	}
+	if last.String() != "let z = 3" {
+		t.Errorf("last statement wrong. got=%q", last.String())
+	}
+}
+
+func TestParseErrorRender(t *testing.T) {
+	input := "let a = 1\n\tlet x = add(1 2}\n"
+	l := lexer.New(input, "main.rnl")
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) != 1 {
+		t.Fatalf("expected 1 error. got=%d: %v", len(p.Errors()), p.Errors())
+	}
+
+	expected := "main.rnl:2:13: error: ( is never closed: expected ), got } instead\n" +
+		"   |\n" +
+		" 2 | \tlet x = add(1 2}\n" +
+		"   | \t           ^\n"
+	if rendered := p.Errors()[0].Render(); rendered != expected {
+		t.Errorf("wrong rendering.\nwant=%q\ngot= %q", expected, rendered)
+	}
+
+	if p.Errors()[0].Error() != "main.rnl:2:13: ( is never closed: expected ), got } instead" {
+		t.Errorf("wrong Error(). got=%q", p.Errors()[0].Error())
+	}
+}
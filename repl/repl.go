@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 
+	"renelle/analysis"
 	"renelle/evaluator"
 	"renelle/lexer"
 	"renelle/object"
@@ -34,6 +35,7 @@ func Start() {
 			printParserErrors(os.Stdout, p.Errors())
 			continue
 		}
+		io.WriteString(os.Stdout, analysis.RenderWarnings(analysis.Check(program)))
 
 		evaluated := evaluator.Eval(program, env, ctx)
 
@@ -45,7 +47,5 @@ func Start() {
 }
 
 func printParserErrors(out io.Writer, errors []parser.ParseError) {
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg.Message+"\n")
-	}
+	io.WriteString(out, parser.RenderErrors(errors))
 }
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"renelle/ast"
+	"renelle/evaluator"
+	"renelle/lexer"
+	"renelle/object"
+	"renelle/parser"
+)
+
+type testResult struct {
+	name     string
+	duration time.Duration
+	err      *object.Error
+}
+
+// testCommand runs the tests under dir for `renelle test`, reporting to out,
+// and returns the status to exit with.
+func testCommand(out io.Writer, dir string) int {
+	ok, err := runTests(out, dir)
+	if err != nil {
+		fmt.Fprintf(out, "Error walking the path %v: %v\n", dir, err)
+		return 1
+	}
+	if !ok {
+		return 1
+	}
+	return 0
+}
+
+// runTests runs every `fn test_*` found in the *_test.rnl files under dir and
+// writes a report to out. It returns false if any test failed.
+func runTests(out io.Writer, dir string) (bool, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() && strings.HasSuffix(path, "_test.rnl") {
+			files = append(files, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	start := time.Now()
+	passed, failed := 0, 0
+
+	for _, file := range files {
+		fmt.Fprintln(out, file)
+
+		results, parseErrs, fileErr := runTestFile(file)
+		if len(parseErrs) != 0 {
+			fmt.Fprintf(out, "  FAIL %s\n", file)
+			fmt.Fprintln(out, indent(parser.RenderErrors(parseErrs), "       "))
+			failed++
+			continue
+		}
+		if fileErr != nil {
+			fmt.Fprintf(out, "  FAIL %s\n", file)
+			printTestError(out, fileErr)
+			failed++
+			continue
+		}
+
+		for _, result := range results {
+			if result.err != nil {
+				fmt.Fprintf(out, "  FAIL %s (%s)\n", result.name, result.duration)
+				printTestError(out, result.err)
+				failed++
+			} else {
+				fmt.Fprintf(out, "  ok   %s (%s)\n", result.name, result.duration)
+				passed++
+			}
+		}
+	}
+
+	fmt.Fprintf(out, "\n%d tests, %d passed, %d failed in %s\n", passed+failed, passed, failed, time.Since(start))
+
+	return failed == 0, nil
+}
+
+// runTestFile evaluates the file in a fresh environment, then runs each of its
+// top-level test functions in turn. The file's syntax errors, or the error
+// that stopped it loading, are returned if it could not be loaded.
+func runTestFile(filename string) ([]testResult, []parser.ParseError, *object.Error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, &object.Error{FileName: filename, Message: err.Error()}
+	}
+
+	l := lexer.New(string(content), filename)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, p.Errors(), nil
+	}
+
+	env := object.NewEnvironment()
+	ctx := object.NewEvalContext()
+	if result, ok := evaluator.Eval(program, env, ctx).(*object.Error); ok {
+		return nil, nil, result
+	}
+
+	results := []testResult{}
+	for _, stmt := range program.Statements {
+		fn, ok := stmt.(*ast.FunctionStatement)
+		if !ok || !strings.HasPrefix(fn.Name.Value, "test_") {
+			continue
+		}
+
+		results = append(results, runTest(fn, env))
+	}
+
+	return results, nil, nil
+}
+
+// runTest calls a single test function with its own context, recovering from
+// any panic so that one broken test does not stop the rest of the run.
+func runTest(fn *ast.FunctionStatement, env *object.Environment) (result testResult) {
+	result.name = fn.Name.Value
+	ctx := object.NewEvalContext()
+	start := time.Now()
+
+	defer func() {
+		result.duration = time.Since(start)
+		if r := recover(); r != nil {
+			result.err = &object.Error{FileName: ctx.FileName, Line: ctx.Line, Column: ctx.Column, Message: fmt.Sprintf("panic: %v", r)}
+		}
+	}()
+
+	if fn.Arity() != 0 {
+		result.err = &object.Error{FileName: fn.Token.FileName, Line: fn.Token.Line, Column: fn.Token.Column, Message: "test functions must not take arguments"}
+		return result
+	}
+
+	testFn, ok := env.Get(fn.Name.Value)
+	if !ok {
+		result.err = &object.Error{FileName: fn.Token.FileName, Line: fn.Token.Line, Column: fn.Token.Column, Message: "test function not found: " + fn.Name.Value}
+		return result
+	}
+
+	if e, ok := evaluator.ApplyFunction(testFn, []object.Object{}, ctx).(*object.Error); ok {
+		result.err = e
+	}
+
+	return result
+}
+
+func printTestError(out io.Writer, e *object.Error) {
+	message := strings.ReplaceAll(e.Message, "\n", "\n       ")
+	fmt.Fprintf(out, "       %s:%d:%d: %s\n", e.FileName, e.Line, e.Column, message)
+}
+
+// indent puts prefix before each non-empty line of s.
+func indent(s, prefix string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
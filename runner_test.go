@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestFiles writes the files, named by their paths under a new temporary
+// directory, and returns the directory.
+func writeTestFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRunTests(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"math_test.rnl": `fn test_add() {
+    Assert.equal(1 + 1, 2)
+}
+
+fn helper() { 1 }
+`,
+		"nested/list_test.rnl": `fn test_head() {
+    Assert.equal(head([1 2]), 1)
+}
+
+fn test_len() {
+    Assert.equal(len([1 2]), 3)
+}
+`,
+		"notes.rnl": "fn test_ignored() { Assert.equal(1, 2) }\n",
+	})
+
+	var out bytes.Buffer
+	if status := testCommand(&out, dir); status != 1 {
+		t.Errorf("wrong exit status. want=1, got=%d", status)
+	}
+	report := out.String()
+
+	for _, want := range []string{
+		filepath.Join(dir, "math_test.rnl") + "\n",
+		"  ok   test_add (",
+		filepath.Join(dir, "nested", "list_test.rnl") + "\n",
+		"  ok   test_head (",
+		"  FAIL test_len (",
+		"list_test.rnl:6:12: Assert.equal failed\n         expected: 3\n         actual:   2\n",
+		"\n3 tests, 2 passed, 1 failed in ",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report does not contain %q. got:\n%s", want, report)
+		}
+	}
+
+	for _, unwanted := range []string{"helper", "test_ignored", "notes.rnl"} {
+		if strings.Contains(report, unwanted) {
+			t.Errorf("report should not mention %s. got:\n%s", unwanted, report)
+		}
+	}
+}
+
+func TestRunTestsPassing(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"a_test.rnl": "fn test_a() { Assert.equal(:a, :a) }\n",
+	})
+
+	var out bytes.Buffer
+	if status := testCommand(&out, dir); status != 0 {
+		t.Errorf("wrong exit status. want=0, got=%d\n%s", status, out.String())
+	}
+	if !strings.Contains(out.String(), "\n1 tests, 1 passed, 0 failed in ") {
+		t.Errorf("wrong summary. got:\n%s", out.String())
+	}
+}
+
+func TestRunTestsFileErrors(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"syntax_test.rnl": "fn test_a() { 1 + }\n",
+		"load_test.rnl":   "let x = 1 + true\nfn test_b() { x }\n",
+	})
+
+	var out bytes.Buffer
+	if status := testCommand(&out, dir); status != 1 {
+		t.Errorf("wrong exit status. want=1, got=%d", status)
+	}
+	report := out.String()
+
+	for _, want := range []string{
+		"  FAIL " + filepath.Join(dir, "syntax_test.rnl") + "\n",
+		"  FAIL " + filepath.Join(dir, "load_test.rnl") + "\n",
+		"load_test.rnl:1:11: type mismatch: INTEGER + BOOLEAN\n",
+		"\n2 tests, 0 passed, 2 failed in ",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report does not contain %q. got:\n%s", want, report)
+		}
+	}
+}
+
+func TestRunTestsMissingDir(t *testing.T) {
+	var out bytes.Buffer
+	if status := testCommand(&out, filepath.Join(t.TempDir(), "missing")); status != 1 {
+		t.Errorf("wrong exit status. want=1, got=%d", status)
+	}
+	if !strings.HasPrefix(out.String(), "Error walking the path ") {
+		t.Errorf("wrong report. got:\n%s", out.String())
+	}
+}
@@ -18,6 +18,7 @@ const (
 	FUNCCALL = "FUNCCALL"
 
 	MODULE = "MODULE"
+	STRUCT = "STRUCT"
 
 	IF           = "IF"
 	ELSE         = "ELSE"
@@ -31,6 +32,8 @@ const (
 	AND          = "AND"
 	OR           = "OR"
 	WITH         = "WITH"
+	WHEN         = "WHEN"
+	IN           = "IN"
 	ASSIGN       = "="
 	PLUS         = "+"
 	MINUS        = "-"
@@ -43,17 +46,25 @@ const (
 	AT           = "@"
 	BACKSLASH    = "\\"
 	ARROW        = "=>"
+	LARROW       = "<-"
 	EQ           = "=="
 	LTE          = "<="
 	GTE          = ">="
 	NEQ          = "!="
 	POW          = "**"
 	PIPE         = "|>"
+	BAR          = "|"
+	CARET        = "^"
+	QUESTION     = "?"
 	DOT          = "."
 	DOTDOT       = ".."
+	COLONCOLON   = "::"
+	STEP         = "//"
+	ELLIPSIS     = "..."
 	CONCAT       = "++"
 	ARRAY_EQ     = "==="
 	ARRAY_NEQ    = "!=="
+	SEMICOLON    = ";"
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -75,6 +86,7 @@ type Token struct {
 
 var TokenMap = map[string]TokenType{
 	"module": MODULE,
+	"struct": STRUCT,
 	"let":    LET,
 	"fn":     FUNCTION,
 	"if":     IF,
@@ -87,6 +99,8 @@ var TokenMap = map[string]TokenType{
 	"and":    AND,
 	"or":     OR,
 	"with":   WITH,
+	"when":   WHEN,
+	"in":     IN,
 }
 
 func LookupIdent(ident string) TokenType {
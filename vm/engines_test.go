@@ -0,0 +1,278 @@
+// vm/engines_test.go
+
+package vm
+
+import (
+	"testing"
+
+	"renelle/evaluator"
+	"renelle/lexer"
+	"renelle/object"
+	"renelle/parser"
+)
+
+// The tests in this file run the same programs on the tree-walking
+// evaluator and on the vm, checking that the two backends agree. Each
+// backend's own behaviour is tested in its package.
+
+// engine runs programs on one backend, in the given environment.
+type engine struct {
+	name string
+	run  func(input string, env *object.Environment, ctx *object.EvalContext) object.Object
+}
+
+var engines = []engine{
+	{"evaluator", func(input string, env *object.Environment, ctx *object.EvalContext) object.Object {
+		program := parser.New(lexer.New(input, "test")).ParseProgram()
+		return evaluator.Eval(program, env, ctx)
+	}},
+	{"vm", testRun},
+}
+
+// forEachEngine runs a test as a subtest for each engine.
+func forEachEngine(t *testing.T, test func(t *testing.T, e engine)) {
+	for _, e := range engines {
+		t.Run(e.name, func(t *testing.T) { test(t, e) })
+	}
+}
+
+// eval runs input in a new environment.
+func (e engine) eval(input string) object.Object {
+	return e.run(input, object.NewEnvironment(), object.NewEvalContext())
+}
+
+// evalWithModule loads the module source, then runs input in the same
+// environment.
+func (e engine) evalWithModule(module, input string) object.Object {
+	env := object.NewEnvironment()
+	ctx := object.NewEvalContext()
+
+	if result, ok := e.run(module, env, ctx).(*object.Error); ok {
+		return result
+	}
+
+	return e.run(input, env, ctx)
+}
+
+// testEngines runs the input on each engine and checks the result is the
+// integer, or the error with the message or value with the Inspect, that is
+// expected.
+func testEngines(t *testing.T, input string, expected interface{}) {
+	t.Helper()
+	for _, e := range engines {
+		testEngine(t, e, e.eval(input), input, expected)
+	}
+}
+
+func testEngine(t *testing.T, e engine, result object.Object, input string, expected interface{}) {
+	t.Helper()
+	if result == nil {
+		t.Errorf("%s: %q: expected %v. got=nil", e.name, input, expected)
+		return
+	}
+	switch expected := expected.(type) {
+	case int:
+		if n, ok := result.(*object.Integer); !ok || n.Value != int64(expected) {
+			t.Errorf("%s: %q: expected %d. got=%T (%+v)", e.name, input, expected, result, result)
+		}
+	case string:
+		if err, ok := result.(*object.Error); ok {
+			if err.Message != expected {
+				t.Errorf("%s: %q: expected %q. got error %q", e.name, input, expected, err.Message)
+			}
+		} else if result.Inspect() != expected {
+			t.Errorf("%s: %q: expected %s. got=%s", e.name, input, expected, result.Inspect())
+		}
+	}
+}
+
+// engineTests are a few programs for each part of the language, with the
+// result both engines should give.
+var engineTests = []struct {
+	input    string
+	expected interface{}
+}{
+	// arithmetic and comparisons
+	{"5 + 5 * 2 - 10 / 2", 10},
+	{"2 ** 10 % 1000", 24},
+	{"1.5 + 2", "3.5"},
+	{"[1 < 2, 2 >= 3, :a != :b, !!5]", "[true false true true]"},
+	{"true and false or true", "true"},
+	{"-true", "unknown operator: -BOOLEAN"},
+	{"1 + \"a\"", "type mismatch: INTEGER + STRING"},
+
+	// bindings, conditionals and functions
+	{"let a = 5 let b = a * 2 a + b", 15},
+	{"if 1 > 2 { 10 } else { 20 }", 20},
+	{"if false { 10 }", ":nil"},
+	{"cond { 1 > 2 => :a\n true => :b }", ":b"},
+	{"fn f(x) { if x > 5 { return x } 0 } f(9) + f(1)", 9},
+	{"let add = \\x y => x + y add(2 3)", 5},
+	{"let adder = \\x => \\y => x + y let add2 = adder(2) add2(3)", 5},
+	{"fn fact(n) { if n < 2 { 1 } else { n * fact(n - 1) } } fact(10)", 3628800},
+	{"fn count(n acc) { if n == 0 { acc } else { count(n - 1 acc + 1) } } count(100000 0)", 100000},
+	{"fn f(x) { x } f(1 2)", "wrong number of arguments. got=2, want=1"},
+	{"y", "identifier not found: y"},
+
+	// strings, arrays, tuples and maps
+	{`"a" + "b"`, `"ab"`},
+	{`let n = 2 $"n is {n + 1}"`, `"n is 3"`},
+	{"let xs = [1 2 3] [xs @ 0, xs @ -1, xs @ 3, len(xs)]", "[1 3 :nil 3]"},
+	{"push([1 2] 3) ++ [4]", "[1 2 3 4]"},
+	{"[1 2 3 4] @ 1::3", "[2 3]"},
+	{"[1 2 3] * 2", "[2 4 6]"},
+	{"let (a b) = (1 2) a + b", 3},
+	{"let [h | t] = [1 2 3] t", "[2 3]"},
+	{"let (a 2) = (1 3)", "cannot destructure tuple: value mismatch"},
+	{"let m = {a: 1 \"b\" = 2} [m @ :a, m @ \"b\", m.a]", "[1 2 1]"},
+	{"let m = {a: 1} { m with :b = 2 }", "{:a = 1, :b = 2}"},
+	{"[1 2 3] |> Array.map(\\x => x * 2) |> Array.sum()", 12},
+
+	// case, patterns, with and ?
+	{"case (:ok 5) { (:error e) => 0\n (:ok n) when n > 3 => n\n _ => 1 }", 5},
+	{"case {status: 200 body: 5} { {status: 404} => 0, {status: 200 body: b} => b }", 5},
+	{"case [1 2 3] { [] => 0, [h | t] => h }", 1},
+	{"let x = 3 case 3 { ^x => :same, _ => :other }", ":same"},
+	{"case 3 { 1 => 1 }", "no matching case for 3"},
+	{"with { (:ok a) <- (:ok 1)\n (:ok b) <- (:error 2)\n a + b } else { (:error e) => e }", 2},
+	{"fn f(r) { let v = (r)? \n (:ok v + 1) } [f((:ok 1)), f((:error :no))]", "[(:ok 2) (:error :no)]"},
+	{"fn f(r) { let v = r? \n (:ok v + 1) } [f((:ok 1)), f((:error :no))]", "[(:ok 2) (:error :no)]"},
+
+	// clauses, overloads, defaults, rest parameters and placeholders
+	{"fn sum([]) { 0 }\nfn sum([h | t]) { h + sum(t) }\nsum([1 2 3])", 6},
+	{"fn f(x) { 1 }\nfn f(x) { 2 }\nf(0)", 2},
+	{"fn r(n) { r(0 n) }\nfn r(a b) { b - a }\n[r(3) r(1 3)]", "[3 2]"},
+	{"fn pad(s n char = \" \") { $\"{char}{s}{n}\" }\n[pad(\"a\" 1) pad(\"a\" char = \"-\" n = 2)]", `[" a1" "-a2"]`},
+	{"fn count(...xs) { len(xs) }\nlet args = [1 2]\ncount(0 ...args)", 3},
+	{"fn sub(a b) { a - b }\nlet f = sub(_ 1)\n[f(10) 10 |> sub(100 _)]", "[9 90]"},
+
+	// ranges
+	{"[len(1..10//3), 7 in 1..9, Array.range(2 5)]", "[4 true 2..4]"},
+	{"let xs = [10 20 30 40 50]\n[xs @ 1..3, xs @ -1..0//-2]", "[[20 30 40] [50 30 10]]"},
+	{"Array.take(0..4_000_000_000_000_000_000, 3)", "[0 1 2]"},
+	{"let [a | r] = Array.range(3)\n(a r push(r 3))", "(0 1..2 [1 2 3])"},
+	{"case 5 { n in 1..9 when n > 6 => :high\n n in 1..9 => n }", 5},
+	{"Stream.from(1..1000000000) |> Stream.filter(\\x => x % 2 == 0) |> Stream.take(3) |> Stream.to_array()", "[2 4 6]"},
+}
+
+func TestEngines(t *testing.T) {
+	for _, tt := range engineTests {
+		testEngines(t, tt.input, tt.expected)
+	}
+}
+
+func TestEngineModules(t *testing.T) {
+	module := `
+    module MyApp.Dog
+
+    struct {
+        name
+        age
+    }
+
+    fn older(dog) { { dog with age = dog.age + 1 } }
+    fn describe(MyApp.Dog{name = "Rex"}) { "it's Rex" }
+    fn describe(MyApp.Dog{age = age}) { $"{age} years old" }
+    `
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let d = MyApp.Dog{name = "Fido" age = 3}` + "\n" + `MyApp.Dog.older(d).age`, 4},
+		{`MyApp.Dog.describe(MyApp.Dog{name = "Rex" age = 1})`, `"it's Rex"`},
+		{`MyApp.Dog.describe(MyApp.Dog{name = "Fido" age = 3})`, `"3 years old"`},
+		{`MyApp.Dog{name = "Fido"}`, "missing field age for struct MyApp.Dog"},
+	}
+
+	forEachEngine(t, func(t *testing.T, e engine) {
+		for _, tt := range tests {
+			testEngine(t, e, e.evalWithModule(module, tt.input), tt.input, tt.expected)
+		}
+	})
+}
+
+func TestPatternErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"case (:ok 2) { (:ok ^nope) => 1, _ => 2 }", "identifier not found: nope"},
+		{"case 2 { ^nope => 1, _ => 2 }", "identifier not found: nope"},
+		{"case {a: 1} { {a: ^nope} => 1, _ => 2 }", "identifier not found: nope"},
+		{"case [1 2] { [^nope | _] => 1, _ => 2 }", "identifier not found: nope"},
+		{"case 3 { n in nope => n, _ => 2 }", "identifier not found: nope"},
+		{"fn f((:ok ^nope)) { 1 }\nfn f(_) { 2 }\nf((:ok 1))", "identifier not found: nope"},
+		{"with { (:ok ^nope) <- (:ok 1)\n1 } else { _ => 2 }", "identifier not found: nope"},
+		// a pinned value that differs is only a mismatch
+		{"let x = 3\ncase (:ok 2) { (:ok ^x) => 1, _ => 2 }", 2},
+		{"let x = 3\nfn f((:ok ^x)) { 1 }\nfn f(_) { 2 }\nf((:ok 1))", 2},
+	}
+
+	for _, tt := range tests {
+		testEngines(t, tt.input, tt.expected)
+	}
+}
+
+func TestNoMatchingClauseMessage(t *testing.T) {
+	testEngines(t, "fn f((:ok v)) { v }\nf(:x)", "no matching clause for f(:x), tried the clause on line 1")
+	testEngines(t, "fn f(:a) { 1 }\nfn f(:b) { 2 }\nf(:c)", "no matching clause for f(:c), tried the clauses on lines 1, 2")
+}
+
+func TestTailCalls(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, e engine) {
+		tests := []struct {
+			input    string
+			expected int64
+		}{
+			{"fn count(n acc) { if n == 0 { acc } else { count(n - 1 acc + 1) } } count(1000000 0)", 1000000},
+			{"fn count(n) { cond { n == 0 => 0, true => count(n - 1) } } count(1000000)", 0},
+			{"fn count(n) { case n { 0 => 7, _ => count(n - 1) } } count(1000000)", 7},
+			{"fn count(n) { if n == 0 { return 3 } return count(n - 1) } count(1000000)", 3},
+			{"fn even(n) { if n == 0 { 1 } else { odd(n - 1) } } fn odd(n) { if n == 0 { 0 } else { even(n - 1) } } even(1000000)", 1},
+			{"let count = \\n acc => if n == 0 { acc } else { count(n - 1 acc + 2) } count(1000000 0)", 2000000},
+		}
+
+		for _, tt := range tests {
+			testIntegerObject(t, e.eval(tt.input), tt.expected)
+		}
+	})
+}
+
+func TestEngineStackTraces(t *testing.T) {
+	input := `fn check(x) {
+    x + "px"
+}
+
+fn main() {
+    let f = \x => check(x) * 2
+    f(1) + 1
+}`
+
+	expected := []object.StackFrame{
+		{Function: "main", FileName: "test", Line: 5, Column: 1},
+		{Function: "f", FileName: "test", Line: 7, Column: 5},
+		{Function: "check", FileName: "test", Line: 6, Column: 19},
+	}
+
+	forEachEngine(t, func(t *testing.T, e engine) {
+		errObj, ok := e.eval(input).(*object.Error)
+		if !ok {
+			t.Fatalf("no error object returned")
+		}
+
+		if len(errObj.Stack) != len(expected) {
+			t.Fatalf("wrong stack depth. want=%d, got=%d (%+v)", len(expected), len(errObj.Stack), errObj.Stack)
+		}
+
+		for i, frame := range expected {
+			if errObj.Stack[i] != frame {
+				t.Errorf("wrong frame %d. want=%+v, got=%+v", i, frame, errObj.Stack[i])
+			}
+		}
+
+		if errObj.Line != 2 || errObj.Column != 7 {
+			t.Errorf("wrong error position. got=%d:%d", errObj.Line, errObj.Column)
+		}
+	})
+}
@@ -0,0 +1,171 @@
+// vm/match.go
+
+package vm
+
+import (
+	"renelle/compiler"
+	"renelle/evaluator"
+	"renelle/object"
+)
+
+type binding struct {
+	symbol compiler.Symbol
+	value  object.Object
+}
+
+// executeMatch matches the value below the pattern's expression values
+// against the pattern. Bindings are only made if the whole pattern matches.
+// In a let (mustMatch) a mismatch is an error, otherwise whether it matched
+// is pushed.
+func (vm *VM) executeMatch(pattern *compiler.Pattern, mustMatch bool) *object.Error {
+	values := vm.popArgs(pattern.NumValues)
+	subject := vm.pop()
+
+	m := &matcher{vm: vm, values: values}
+	if err := m.match(pattern, subject); err != nil {
+		if mustMatch {
+			return err
+		}
+		vm.push(nativeBoolToBooleanObject(false))
+		return nil
+	}
+
+	for _, b := range m.bindings {
+		vm.bind(b.symbol, b.value)
+	}
+
+	if !mustMatch {
+		vm.push(nativeBoolToBooleanObject(true))
+	}
+	return nil
+}
+
+func (vm *VM) bind(symbol compiler.Symbol, value object.Object) {
+	if symbol.Scope == compiler.GlobalScope {
+		vm.globals[symbol.Index] = value
+	} else {
+		vm.stack[vm.currentFrame().basePointer+symbol.Index] = value
+	}
+}
+
+type matcher struct {
+	vm       *VM
+	values   []object.Object
+	bindings []binding
+}
+
+func (m *matcher) match(p *compiler.Pattern, val object.Object) *object.Error {
+	switch p.Kind {
+	case compiler.PatternWildcard:
+		return nil
+
+	case compiler.PatternBind:
+		m.bindings = append(m.bindings, binding{symbol: p.Symbol, value: val})
+		return nil
+
+	case compiler.PatternValue:
+		if !object.Equals(m.values[p.Value], val) {
+			return m.vm.newError("value mismatch")
+		}
+		return nil
+
+	case compiler.PatternTuple:
+		tuple, ok := val.(*object.Tuple)
+		if !ok {
+			return m.vm.newError("right-hand side of assignment is not a tuple")
+		}
+		if len(tuple.Elements) != len(p.Elements) {
+			return m.vm.newError("cannot destructure tuple: size mismatch")
+		}
+		return m.matchElements(p.Elements, tuple.Elements, "tuple")
+
+	case compiler.PatternArray:
+		switch val.(type) {
+		case *object.Array, *object.Range:
+		default:
+			return m.vm.newError("right-hand side of assignment is not an array")
+		}
+		elements, rest, ok := object.Split(val, len(p.Elements))
+		if !ok || p.Rest == nil && rest != nil {
+			return m.vm.newError("cannot destructure array: size mismatch")
+		}
+		if err := m.matchElements(p.Elements, elements, "array"); err != nil || p.Rest == nil {
+			return err
+		}
+		if rest == nil {
+			rest = &object.Array{Elements: []object.Object{}}
+		}
+		return m.matchElement(p.Rest, rest, "array")
+
+	case compiler.PatternMap:
+		mapObj, ok := val.(*object.Map)
+		if !ok {
+			return m.vm.newError("expected map, got %s", val.Type())
+		}
+		for i, keyIndex := range p.Keys {
+			key := m.values[keyIndex]
+			if _, ok := key.(object.Hashable); !ok {
+				return m.vm.newError("unusable as hash key: %s", key.Type())
+			}
+			value, ok := mapObj.Get(key)
+			if !ok {
+				return m.vm.newError("key not found: %s", key.Inspect())
+			}
+			if err := m.matchElement(p.Elements[i], value, "map"); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case compiler.PatternStruct:
+		structObj, ok := val.(*object.Struct)
+		if !ok || structObj.Module != p.Module {
+			return m.vm.newError("expected %s struct, got %s", p.Module, val.Type())
+		}
+		for i, field := range p.Fields {
+			value, ok := structObj.Get(field)
+			if !ok {
+				return m.vm.newError("unknown field %s for struct %s", field, structObj.Module)
+			}
+			if err := m.matchElement(p.Elements[i], value, "struct"); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case compiler.PatternMember:
+		collection := m.values[p.Value]
+		m.vm.syncPos()
+		member := evaluator.EvalInfix(m.vm.ctx, "in", val, collection)
+		if err, ok := member.(*object.Error); ok {
+			return err
+		}
+		if !evaluator.IsTruthy(member) {
+			return m.vm.newError("%s is not in %s", val.Inspect(), collection.Inspect())
+		}
+		return m.match(p.Elements[0], val)
+	}
+
+	return m.vm.newError("invalid pattern")
+}
+
+func (m *matcher) matchElements(patterns []*compiler.Pattern, values []object.Object, kind string) *object.Error {
+	for i, p := range patterns {
+		if err := m.matchElement(p, values[i], kind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchElement matches a value nested in a collection of the given kind, so
+// that value mismatches are reported against the collection.
+func (m *matcher) matchElement(p *compiler.Pattern, val object.Object, kind string) *object.Error {
+	if p.Kind == compiler.PatternValue {
+		if !object.Equals(m.values[p.Value], val) {
+			return m.vm.newError("cannot destructure %s: value mismatch", kind)
+		}
+		return nil
+	}
+	return m.match(p, val)
+}
@@ -0,0 +1,784 @@
+// vm/vm.go
+
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	"renelle/code"
+	"renelle/compiler"
+	"renelle/constants"
+	"renelle/evaluator"
+	"renelle/object"
+)
+
+const StackSize = 2048
+const MaxFrames = 1 << 16
+
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+
+	stack []object.Object
+	sp    int // always points to the next free slot; the top of the stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	env *object.Environment
+	ctx *object.EvalContext
+}
+
+// New creates a vm for the compiled program. Modules are loaded into env by
+// the evaluator, so it should be the environment the program runs in.
+func New(bytecode *compiler.Bytecode, env *object.Environment, ctx *object.EvalContext) *VM {
+	vm := &VM{
+		constants:   bytecode.Constants,
+		globals:     make([]object.Object, bytecode.NumGlobals),
+		globalNames: bytecode.GlobalNames,
+		stack:       make([]object.Object, StackSize),
+		frames:      make([]*Frame, 0, 64),
+		env:         env,
+		ctx:         ctx,
+	}
+
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		Name:         "main",
+		FileName:     bytecode.FileName,
+		Positions:    bytecode.Positions,
+	}
+	mainClosure := &object.Closure{Fn: mainFn, Runner: vm}
+
+	vm.stack[0] = mainClosure
+	vm.sp = 1
+	vm.pushFrame(NewFrame(mainClosure, 1))
+
+	return vm
+}
+
+// Run runs the program and returns the value it evaluates to, or the error
+// that stopped it.
+func (vm *VM) Run() object.Object {
+	stackSize := len(vm.ctx.Stack)
+	defer func() { vm.ctx.Stack = vm.ctx.Stack[:stackSize] }()
+
+	return vm.run(0)
+}
+
+// RunClosure calls a closure created by this vm from host code, such as a
+// builtin that takes a callback. It can be called while the vm is running.
+func (vm *VM) RunClosure(cl *object.Closure, args []object.Object, ctx *object.EvalContext) object.Object {
+	sp, framesIndex, stackSize := vm.sp, vm.framesIndex, len(ctx.Stack)
+	defer func() {
+		vm.sp = sp
+		vm.framesIndex = framesIndex
+		vm.frames = vm.frames[:framesIndex]
+		ctx.Stack = ctx.Stack[:stackSize]
+	}()
+
+	vm.push(cl)
+	for _, arg := range args {
+		vm.push(arg)
+	}
+
+	if err := vm.callClosure(cl, len(args)); err != nil {
+		return err
+	}
+
+	return vm.run(framesIndex)
+}
+
+// run executes instructions until the frame count drops back to depth.
+func (vm *VM) run(depth int) object.Object {
+	for {
+		frame := vm.currentFrame()
+		frame.ip++
+		ip := frame.ip
+		ins := frame.Instructions()
+		op := code.Opcode(ins[ip])
+
+		var err *object.Error
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			vm.push(vm.constants[constIndex])
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpDup:
+			vm.push(vm.stack[vm.sp-1])
+
+		case code.OpTrue:
+			vm.push(constants.TRUE)
+
+		case code.OpFalse:
+			vm.push(constants.FALSE)
+
+		case code.OpNil:
+			vm.push(constants.NIL)
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow,
+			code.OpEqual, code.OpNotEqual, code.OpLessThan, code.OpGreaterThan,
+			code.OpLessEqual, code.OpGreaterEqual, code.OpConcat, code.OpArrayEqual, code.OpArrayNotEqual,
+			code.OpRange, code.OpStep, code.OpIn:
+			err = vm.executeInfix(op)
+
+		case code.OpMinus, code.OpBang:
+			def, _ := code.Lookup(byte(op))
+			right := vm.pop()
+			vm.syncPos()
+			err = vm.pushResult(evaluator.EvalPrefix(vm.ctx, def.Operator, right))
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip = pos - 1
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			if !evaluator.IsTruthy(vm.pop()) {
+				frame.ip = pos - 1
+			}
+
+		case code.OpJumpIfFalsy, code.OpJumpIfTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			if evaluator.IsTruthy(vm.stack[vm.sp-1]) == (op == code.OpJumpIfTruthy) {
+				frame.ip = pos - 1
+			} else {
+				vm.pop()
+			}
+
+		case code.OpToBool:
+			vm.stack[vm.sp-1] = nativeBoolToBooleanObject(evaluator.IsTruthy(vm.stack[vm.sp-1]))
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			val := vm.globals[globalIndex]
+			if val == nil {
+				err = vm.newError("identifier not found: %s", vm.globalNames[globalIndex])
+				break
+			}
+			vm.push(val)
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpGetLocal:
+			localIndex := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			val := vm.stack[frame.basePointer+localIndex]
+			if val == nil {
+				val = constants.NIL
+			}
+			vm.push(val)
+
+		case code.OpSetLocal:
+			localIndex := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			vm.stack[frame.basePointer+localIndex] = vm.pop()
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+			vm.push(frame.cl.Free[freeIndex])
+
+		case code.OpCurrentClosure:
+			vm.push(frame.cl)
+
+		case code.OpGetModule:
+			nameIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			name := vm.constants[nameIndex].(*object.String).Value
+			vm.syncPos()
+			err = vm.pushResult(evaluator.GetModule(vm.ctx, name, vm.env))
+
+		case code.OpModule:
+			defIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			def := vm.constants[defIndex].(*compiler.ModuleDefinition)
+			vm.syncPos()
+			err = vm.pushResult(evaluator.EvalModule(def.Node, vm.env, vm.ctx))
+
+		case code.OpArray, code.OpTuple:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp -= numElements
+			if op == code.OpArray {
+				vm.push(&object.Array{Elements: elements})
+			} else {
+				vm.push(&object.Tuple{Elements: elements})
+			}
+
+		case code.OpMap:
+			numPairs := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			err = vm.buildMap(numPairs)
+
+		case code.OpMapUpdate:
+			namesIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			err = vm.executeMapUpdate(vm.constants[namesIndex].(*object.Array))
+
+		case code.OpStruct:
+			namesIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			err = vm.buildStruct(vm.constants[namesIndex].(*object.Array))
+
+		case code.OpInterpolate:
+			numSegments := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			var sb strings.Builder
+			for _, segment := range vm.stack[vm.sp-numSegments : vm.sp] {
+				if str, ok := segment.(*object.String); ok {
+					sb.WriteString(str.Value)
+				} else {
+					sb.WriteString(segment.Inspect())
+				}
+			}
+			vm.sp -= numSegments
+			vm.push(&object.String{Value: sb.String()})
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			vm.syncPos()
+			err = vm.pushResult(evaluator.EvalIndex(vm.ctx, left, index))
+
+		case code.OpSlice:
+			stop := vm.pop()
+			start := vm.pop()
+			if start.Type() != object.INTEGER_OBJ || stop.Type() != object.INTEGER_OBJ {
+				err = vm.newError("slice bounds must be integers")
+				break
+			}
+			vm.push(&object.Slice{Start: start, End: stop})
+
+		case code.OpGetProperty:
+			nameIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			name := vm.constants[nameIndex].(*object.String).Value
+			left := vm.pop()
+			vm.syncPos()
+			err = vm.pushResult(evaluator.GetProperty(vm.ctx, left, name))
+
+		case code.OpCallProperty:
+			nameIndex := code.ReadUint16(ins[ip+1:])
+			numArgs := int(code.ReadUint8(ins[ip+3:]))
+			frame.ip += 3
+			name := vm.constants[nameIndex].(*object.String).Value
+			left := vm.stack[vm.sp-1-numArgs]
+			args := vm.popArgs(numArgs)
+			vm.sp--
+			vm.syncPos()
+			err = vm.pushResult(evaluator.CallProperty(vm.ctx, left, name, args))
+
+		case code.OpCall:
+			numArgs := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip += 1
+			err = vm.executeCall(numArgs)
+
+		case code.OpCallKeywords:
+			namesIndex := code.ReadUint16(ins[ip+1:])
+			numArgs := int(code.ReadUint8(ins[ip+3:]))
+			frame.ip += 3
+			err = vm.executeKeywordCall(vm.constants[namesIndex].(*object.Array), numArgs)
+
+		case code.OpCallSpread:
+			spreadsIndex := code.ReadUint16(ins[ip+1:])
+			numArgs := int(code.ReadUint8(ins[ip+3:]))
+			frame.ip += 3
+			err = vm.executeSpreadCall(vm.constants[spreadsIndex].(*object.Array), numArgs)
+
+		case code.OpPartial:
+			placeholdersIndex := code.ReadUint16(ins[ip+1:])
+			numArgs := int(code.ReadUint8(ins[ip+3:]))
+			frame.ip += 3
+			vm.buildPartial(vm.constants[placeholdersIndex].(*object.Array), numArgs)
+
+		case code.OpTailCall:
+			numArgs := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip += 1
+			err = vm.executeTailCall(numArgs)
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+			if vm.returnFrom(returnValue, depth) {
+				return returnValue
+			}
+
+		case code.OpTry:
+			vm.syncPos()
+			result := evaluator.EvalTry(vm.ctx, vm.pop())
+			if rv, ok := result.(*object.ReturnValue); ok {
+				if vm.returnFrom(rv.Value, depth) {
+					return rv.Value
+				}
+			} else {
+				err = vm.pushResult(result)
+			}
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := int(code.ReadUint8(ins[ip+3:]))
+			frame.ip += 3
+			vm.pushClosure(int(constIndex), numFree)
+
+		case code.OpMatch:
+			patternIndex := code.ReadUint16(ins[ip+1:])
+			mode := code.ReadUint8(ins[ip+3:])
+			frame.ip += 3
+			err = vm.executeMatch(vm.constants[patternIndex].(*compiler.Pattern), mode == 1)
+
+		case code.OpDefault:
+			localIndex := int(code.ReadUint16(ins[ip+1:]))
+			pos := int(code.ReadUint16(ins[ip+3:]))
+			frame.ip += 4
+			if vm.stack[frame.basePointer+localIndex] != nil {
+				frame.ip = pos - 1
+			}
+
+		case code.OpOverload:
+			nameIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			fn := vm.pop().(*object.Closure)
+			existing := vm.pop()
+			vm.push(object.Overload(vm.constants[nameIndex].(*object.String).Value, existing, fn, fn.Fn.NumParameters))
+
+		case code.OpNoMatch:
+			formatIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			err = vm.newError(vm.constants[formatIndex].(*object.String).Value, vm.pop().Inspect())
+
+		default:
+			err = vm.newError("unknown opcode %d", op)
+		}
+
+		if err != nil {
+			if err.Stack == nil {
+				err.Stack = vm.ctx.StackTrace()
+			}
+			return err
+		}
+	}
+}
+
+func (vm *VM) executeInfix(op code.Opcode) *object.Error {
+	right := vm.pop()
+	left := vm.pop()
+
+	if l, ok := left.(*object.Integer); ok {
+		if r, ok := right.(*object.Integer); ok {
+			if result, ok := integerInfix(op, l.Value, r.Value); ok {
+				vm.push(result)
+				return nil
+			}
+		}
+	}
+
+	def, _ := code.Lookup(byte(op))
+	vm.syncPos()
+	return vm.pushResult(evaluator.EvalInfix(vm.ctx, def.Operator, left, right))
+}
+
+// integerInfix handles the common integer operations without going through
+// the evaluator.
+func integerInfix(op code.Opcode, left, right int64) (object.Object, bool) {
+	switch op {
+	case code.OpAdd:
+		return &object.Integer{Value: left + right}, true
+	case code.OpSub:
+		return &object.Integer{Value: left - right}, true
+	case code.OpMul:
+		return &object.Integer{Value: left * right}, true
+	case code.OpEqual:
+		return nativeBoolToBooleanObject(left == right), true
+	case code.OpNotEqual:
+		return nativeBoolToBooleanObject(left != right), true
+	case code.OpLessThan:
+		return nativeBoolToBooleanObject(left < right), true
+	case code.OpGreaterThan:
+		return nativeBoolToBooleanObject(left > right), true
+	case code.OpLessEqual:
+		return nativeBoolToBooleanObject(left <= right), true
+	case code.OpGreaterEqual:
+		return nativeBoolToBooleanObject(left >= right), true
+	default:
+		return nil, false
+	}
+}
+
+func (vm *VM) executeCall(numArgs int) *object.Error {
+	callee := vm.resolveCallee(numArgs)
+
+	if cl, ok := callee.(*object.Closure); ok && cl.Runner == vm {
+		vm.syncPos()
+		return vm.callClosure(cl, numArgs)
+	}
+
+	args := vm.popArgs(numArgs)
+	vm.sp--
+	vm.syncPos()
+
+	switch callee := callee.(type) {
+	case *object.Builtin:
+		return vm.pushResult(callee.Fn(vm.ctx, args...))
+	case *object.Function, *object.Overloads, *object.Partial, object.Callable:
+		return vm.pushResult(evaluator.ApplyFunction(callee, args, vm.ctx))
+	default:
+		return vm.newError("not a function: %s", callee.Type())
+	}
+}
+
+// executeKeywordCall calls the function below numArgs arguments, the last of
+// which are the keyword arguments with the given names, after putting them in
+// the order of its parameters.
+func (vm *VM) executeKeywordCall(names *object.Array, numArgs int) *object.Error {
+	numKeywords := len(names.Elements)
+	callee := vm.stack[vm.sp-1-numArgs]
+	values := vm.popArgs(numArgs)
+	vm.sp--
+	vm.syncPos()
+
+	keywords := make([]string, numKeywords)
+	for i, name := range names.Elements {
+		keywords[i] = name.(*object.String).Value
+	}
+	positional := numArgs - numKeywords
+	fn, args, err := evaluator.BindKeywords(vm.ctx, callee, values[:positional], keywords, values[positional:])
+	if err != nil {
+		return err.(*object.Error)
+	}
+
+	vm.push(fn)
+	for _, arg := range args {
+		vm.push(arg)
+	}
+	return vm.executeCall(len(args))
+}
+
+// executeSpreadCall calls the function below numArgs arguments after
+// replacing the arrays at the listed positions with their elements.
+func (vm *VM) executeSpreadCall(spreads *object.Array, numArgs int) *object.Error {
+	values := vm.popArgs(numArgs)
+	vm.syncPos()
+
+	args := make([]object.Object, 0, numArgs)
+	next := 0
+	for i, value := range values {
+		if next < len(spreads.Elements) && int(spreads.Elements[next].(*object.Integer).Value) == i {
+			next++
+			array, ok := value.(*object.Array)
+			if r, isRange := value.(*object.Range); isRange {
+				array, ok = r.Array()
+			}
+			if !ok {
+				return vm.newError("cannot spread %s, expected an array", value.Type())
+			}
+			args = append(args, array.Elements...)
+			continue
+		}
+		args = append(args, value)
+	}
+
+	vm.ensureStack(vm.sp + len(args))
+	for _, arg := range args {
+		vm.push(arg)
+	}
+	return vm.executeCall(len(args))
+}
+
+// buildPartial makes the function below the arguments given to a call with
+// placeholders at the listed positions into a partial application of it.
+func (vm *VM) buildPartial(placeholders *object.Array, numArgs int) {
+	given := vm.popArgs(numArgs - len(placeholders.Elements))
+	fn := vm.pop()
+
+	args := make([]object.Object, numArgs)
+	next := 0
+	for i := range args {
+		if next < len(placeholders.Elements) && int(placeholders.Elements[next].(*object.Integer).Value) == i {
+			next++
+			continue
+		}
+		args[i], given = given[0], given[1:]
+	}
+	vm.push(&object.Partial{Fn: fn, Args: args})
+}
+
+// executeTailCall replaces the current frame with the call when calling one
+// of this vm's closures, so recursion in tail position does not use up
+// frames. Other calls are made as usual.
+func (vm *VM) executeTailCall(numArgs int) *object.Error {
+	callee := vm.resolveCallee(numArgs)
+
+	cl, ok := callee.(*object.Closure)
+	if !ok || cl.Runner != vm || !cl.Fn.Accepts(numArgs) {
+		return vm.executeCall(numArgs)
+	}
+
+	vm.syncPos()
+	vm.ctx.ReplaceFrame(cl.Fn.Name)
+
+	frame := vm.popFrame()
+	copy(vm.stack[frame.basePointer-1:], vm.stack[vm.sp-1-numArgs:vm.sp])
+	vm.sp = frame.basePointer + numArgs
+	if cl.Fn.Variadic {
+		vm.packRest(cl, frame.basePointer, numArgs)
+	}
+	vm.pushFrame(NewFrame(cl, frame.basePointer))
+	vm.clearLocals(cl, frame.basePointer)
+	return nil
+}
+
+// resolveCallee returns the function called with numArgs arguments, picking
+// the one of that arity from an Overloads. An Overloads with none is left to
+// report the error.
+func (vm *VM) resolveCallee(numArgs int) object.Object {
+	callee := vm.stack[vm.sp-1-numArgs]
+	if overloads, ok := callee.(*object.Overloads); ok {
+		if fn, ok := overloads.Lookup(numArgs); ok {
+			vm.stack[vm.sp-1-numArgs] = fn
+			return fn
+		}
+	}
+	return callee
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) *object.Error {
+	if !cl.Fn.Accepts(numArgs) {
+		return vm.newError("wrong number of arguments. got=%d, want=%s", numArgs, object.ArityString(object.ArityRange(cl)))
+	}
+
+	if vm.framesIndex >= MaxFrames {
+		return vm.newError("stack overflow")
+	}
+
+	basePointer := vm.sp - numArgs
+	if cl.Fn.Variadic {
+		vm.packRest(cl, basePointer, numArgs)
+	}
+	vm.ctx.PushFrame(cl.Fn.Name)
+	vm.pushFrame(NewFrame(cl, basePointer))
+	vm.clearLocals(cl, basePointer)
+	return nil
+}
+
+// packRest gathers the arguments past the parameters of a variadic closure
+// into the array its rest parameter, in the slot after them, is bound to.
+func (vm *VM) packRest(cl *object.Closure, basePointer int, numArgs int) {
+	numParams := cl.Fn.NumParameters
+	rest := []object.Object{}
+	if numArgs > numParams {
+		rest = append(rest, vm.stack[basePointer+numParams:basePointer+numArgs]...)
+	}
+
+	vm.ensureStack(basePointer + numParams + 1)
+	for i := basePointer + numArgs; i < basePointer+numParams; i++ {
+		vm.stack[i] = nil
+	}
+	vm.stack[basePointer+numParams] = &object.Array{Elements: rest}
+	vm.sp = basePointer + numParams + 1
+}
+
+// clearLocals makes room for the locals of a call, which are not set until
+// their let runs.
+func (vm *VM) clearLocals(cl *object.Closure, basePointer int) {
+	vm.ensureStack(basePointer + cl.Fn.NumLocals)
+	for i := vm.sp; i < basePointer+cl.Fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+	vm.sp = basePointer + cl.Fn.NumLocals
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) {
+	function := vm.constants[constIndex].(*object.CompiledFunction)
+
+	free := make([]object.Object, numFree)
+	copy(free, vm.stack[vm.sp-numFree:vm.sp])
+	vm.sp -= numFree
+
+	vm.push(&object.Closure{Fn: function, Free: free, Runner: vm})
+}
+
+func (vm *VM) buildMap(numPairs int) *object.Error {
+	pairs := vm.stack[vm.sp-2*numPairs : vm.sp]
+
+	mapObject := &object.Map{}
+
+	for i := 0; i < len(pairs); i += 2 {
+		key, value := pairs[i], pairs[i+1]
+		if _, ok := key.(object.Hashable); !ok {
+			return vm.newError("unusable as hash key: %s", key.Type())
+		}
+		mapObject.Put(key, value)
+	}
+
+	vm.sp -= 2 * numPairs
+	vm.push(mapObject)
+	return nil
+}
+
+func (vm *VM) executeMapUpdate(names *object.Array) *object.Error {
+	numPairs := len(names.Elements)
+	pairs := vm.stack[vm.sp-2*numPairs : vm.sp]
+	left := vm.stack[vm.sp-2*numPairs-1]
+	vm.sp -= 2*numPairs + 1
+
+	switch left := left.(type) {
+	case *object.Struct:
+		fields := make([]string, numPairs)
+		values := make([]object.Object, numPairs)
+		for i, name := range names.Elements {
+			fields[i] = name.(*object.String).Value
+			if fields[i] == "" {
+				return vm.newError("invalid struct field: %s", pairs[2*i].Inspect())
+			}
+			values[i] = pairs[2*i+1]
+		}
+		vm.syncPos()
+		return vm.pushResult(evaluator.UpdateStruct(vm.ctx, left, fields, values))
+
+	case *object.Map:
+		result := left
+		for i := 0; i < len(pairs); i += 2 {
+			if _, ok := pairs[i].(object.Hashable); !ok {
+				return vm.newError("unusable as hash key: %s", pairs[i].Type())
+			}
+			result = result.With(pairs[i], pairs[i+1])
+		}
+		vm.push(result)
+		return nil
+
+	default:
+		return vm.newError("not a map: %s", left.Type())
+	}
+}
+
+func (vm *VM) buildStruct(names *object.Array) *object.Error {
+	name := names.Elements[0].(*object.String).Value
+	numFields := len(names.Elements) - 1
+
+	fields := make([]string, numFields)
+	for i, field := range names.Elements[1:] {
+		fields[i] = field.(*object.String).Value
+	}
+
+	values := make([]object.Object, numFields)
+	copy(values, vm.stack[vm.sp-numFields:vm.sp])
+	module := vm.stack[vm.sp-numFields-1]
+	vm.sp -= numFields + 1
+
+	vm.syncPos()
+	return vm.pushResult(evaluator.NewStruct(vm.ctx, module, name, fields, values))
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	vm.frames = append(vm.frames[:vm.framesIndex], f)
+	vm.framesIndex++
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+func (vm *VM) push(o object.Object) {
+	vm.ensureStack(vm.sp + 1)
+	vm.stack[vm.sp] = o
+	vm.sp++
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+// popArgs pops the top n values, keeping them in the order they were pushed.
+func (vm *VM) popArgs(n int) []object.Object {
+	args := make([]object.Object, n)
+	copy(args, vm.stack[vm.sp-n:vm.sp])
+	vm.sp -= n
+	return args
+}
+
+func (vm *VM) ensureStack(size int) {
+	if size <= len(vm.stack) {
+		return
+	}
+	newSize := len(vm.stack) * 2
+	for newSize < size {
+		newSize *= 2
+	}
+	stack := make([]object.Object, newSize)
+	copy(stack, vm.stack)
+	vm.stack = stack
+}
+
+// returnFrom leaves the current frame with value as its result, reporting
+// whether that ends the run begun at depth.
+func (vm *VM) returnFrom(value object.Object, depth int) bool {
+	frame := vm.popFrame()
+	vm.sp = frame.basePointer - 1
+	vm.push(value)
+	if vm.framesIndex > 0 {
+		vm.ctx.PopFrame()
+	}
+	return vm.framesIndex == depth
+}
+
+// pushResult pushes the result of a call out of the vm, or returns it if it
+// is an error.
+func (vm *VM) pushResult(result object.Object) *object.Error {
+	if err, ok := result.(*object.Error); ok {
+		return err
+	}
+	if result == nil {
+		result = constants.NIL
+	}
+	vm.push(result)
+	return nil
+}
+
+// syncPos points the eval context at the instruction being run, so errors
+// raised outside the vm report where they happened.
+func (vm *VM) syncPos() {
+	frame := vm.currentFrame()
+	line, column := frame.cl.Fn.Position(frame.ip)
+	vm.ctx.Line = line
+	vm.ctx.Column = column
+	vm.ctx.FileName = frame.cl.Fn.FileName
+}
+
+func (vm *VM) newError(format string, a ...interface{}) *object.Error {
+	vm.syncPos()
+	return &object.Error{
+		Message:  fmt.Sprintf(format, a...),
+		Line:     vm.ctx.Line,
+		Column:   vm.ctx.Column,
+		FileName: vm.ctx.FileName,
+	}
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return constants.TRUE
+	}
+	return constants.FALSE
+}
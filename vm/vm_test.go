@@ -0,0 +1,152 @@
+// vm/vm_test.go
+
+package vm
+
+import (
+	"renelle/compiler"
+	"renelle/lexer"
+	"renelle/object"
+	"renelle/parser"
+	"testing"
+)
+
+func testEval(input string) object.Object {
+	return testRun(input, object.NewEnvironment(), object.NewEvalContext())
+}
+
+// testRun compiles input and runs it on a new vm.
+func testRun(input string, env *object.Environment, ctx *object.EvalContext) object.Object {
+	l := lexer.New(input, "test")
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return &object.Error{Message: err.(*compiler.Error).Message}
+	}
+
+	return New(comp.Bytecode(), env, ctx).Run()
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	result, ok := obj.(*object.Integer)
+
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+func TestFunctionObject(t *testing.T) {
+	input := "\\x => x + 2"
+
+	evaluated := testEval(input)
+	cl, ok := evaluated.(*object.Closure)
+	if !ok {
+		t.Fatalf("object is not Closure. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if cl.Fn.NumParameters != 1 {
+		t.Fatalf("function has wrong number of parameters. got=%d", cl.Fn.NumParameters)
+	}
+
+	if cl.Type() != object.FUNCTION_OBJ {
+		t.Fatalf("closure has wrong type. got=%q", cl.Type())
+	}
+}
+
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let adder = \\x => \\y => x + y let add2 = adder(2) add2(3)", 5},
+		{"fn counter(n) { \\x => \\y => n + x + y } let f = counter(1) let g = f(2) g(3)", 6},
+		{"fn fact(n) { if n < 2 { 1 } else { n * fact(n - 1) } } fact(10)", 3628800},
+		{"fn run() { let f = \\n => if n == 0 { 0 } else { f(n - 1) + 1 } f(5) } run()", 5},
+		{"fn main() { 42 } 1", 42},
+		{"let x = 1 fn f() { x } let x = 2 f()", 2},
+		{"let n = 10 case 5 { n => n } + n", 15},
+		{"Array.filter([1 2 3] \\x => x > 1) |> len()", 2},
+		{"let k = 10 Array.map([1 2 3] \\x => x + k) |> Array.sum()", 36},
+		{"y", "identifier not found: y"},
+		{"fn f(x) { x } f(1 2)", "wrong number of arguments. got=2, want=1"},
+		{"let (a 2) = (1 3)", "cannot destructure tuple: value mismatch"},
+		{"case 3 { 1 => 1 }", "no matching case for 3"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestStackTraces(t *testing.T) {
+	input := `fn area(w h) {
+    check(w) * h
+}
+
+fn check(x) {
+    x + "px"
+}
+
+let f = \x => area(x 2) + 0
+f(1) + 1`
+
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := []object.StackFrame{
+		{Function: "f", FileName: "test", Line: 10, Column: 1},
+		{Function: "area", FileName: "test", Line: 9, Column: 15},
+		{Function: "check", FileName: "test", Line: 2, Column: 5},
+	}
+
+	if len(errObj.Stack) != len(expected) {
+		t.Fatalf("wrong stack depth. want=%d, got=%d (%+v)", len(expected), len(errObj.Stack), errObj.Stack)
+	}
+
+	for i, frame := range expected {
+		if errObj.Stack[i] != frame {
+			t.Errorf("wrong frame %d. want=%+v, got=%+v", i, frame, errObj.Stack[i])
+		}
+	}
+
+	if errObj.Line != 6 || errObj.Column != 7 {
+		t.Errorf("wrong error position. got=%d:%d", errObj.Line, errObj.Column)
+	}
+}
+
+// TestStackOverflow is vm only, as the evaluator recurses on the Go stack.
+func TestStackOverflow(t *testing.T) {
+	evaluated := testEval("fn loop_forever(n) { loop_forever(n) + 1 } loop_forever(1)")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "stack overflow" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}